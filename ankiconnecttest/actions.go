@@ -0,0 +1,534 @@
+package ankiconnecttest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/atselvan/ankiconnect"
+)
+
+const (
+	actionCanAddNotes = "canAddNotes"
+
+	deleteDecksErrMsg = "Since Anki 2.1.28 it's not possible to delete decks without deleting cards as well"
+)
+
+type (
+	// paramsNotes are the params of the actions that take a list of note ids.
+	paramsNotes struct {
+		Notes []int64 `json:"notes"`
+	}
+
+	// paramsCards are the params of the actions that take a list of card ids.
+	paramsCards struct {
+		Cards []int64 `json:"cards"`
+	}
+
+	// paramsAddNotes are the params of the addNotes and canAddNotes actions.
+	paramsAddNotes struct {
+		Notes []ankiconnect.Note `json:"notes"`
+	}
+
+	// paramsDecks are the params of the getDeckStats action.
+	paramsDecks struct {
+		Decks []string `json:"decks"`
+	}
+
+	// paramsStoreMediaFile are the params of the storeMediaFile action.
+	paramsStoreMediaFile struct {
+		Filename       string `json:"filename"`
+		Data           string `json:"data"`
+		Path           string `json:"path"`
+		URL            string `json:"url"`
+		DeleteExisting *bool  `json:"deleteExisting"`
+	}
+)
+
+func handleVersion(s *Server, _ json.RawMessage) (interface{}, error) {
+	return Version, nil
+}
+
+func handleAPIReflect(s *Server, params json.RawMessage) (interface{}, error) {
+	var p ankiconnect.ParamsAPIReflect
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	result := map[string]interface{}{"scopes": []string{}, "actions": nil}
+	for _, scope := range p.Scopes {
+		if scope != "actions" {
+			continue
+		}
+		var actions []string
+		for action := range handlers {
+			if p.Actions == nil || indexOf(*p.Actions, action) >= 0 {
+				actions = append(actions, action)
+			}
+		}
+		sort.Strings(actions)
+		result["scopes"] = []string{"actions"}
+		result["actions"] = actions
+	}
+	return result, nil
+}
+
+func handleRequestPermission(s *Server, _ json.RawMessage) (interface{}, error) {
+	return map[string]interface{}{
+		"permission":    ankiconnect.PermissionGranted,
+		"requireApikey": s.apiKey != "",
+		"version":       Version,
+	}, nil
+}
+
+func handleMulti(s *Server, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Actions []request `json:"actions"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	results := make([]response, len(p.Actions))
+	for i, a := range p.Actions {
+		result, err := s.execute(a)
+		if err != nil {
+			results[i].Error = err.Error()
+		} else {
+			results[i].Result = result
+		}
+	}
+	return results, nil
+}
+
+func handleSync(s *Server, _ json.RawMessage) (interface{}, error) {
+	return nil, nil
+}
+
+func handleDeckNames(s *Server, _ json.RawMessage) (interface{}, error) {
+	return s.col.deckNames(), nil
+}
+
+func handleCreateDeck(s *Server, params json.RawMessage) (interface{}, error) {
+	var p ankiconnect.ParamsCreateDeck
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(p.Deck) == "" {
+		return nil, errors.New("deck name must not be empty")
+	}
+	return s.col.createDeck(p.Deck), nil
+}
+
+func handleDeleteDecks(s *Server, params json.RawMessage) (interface{}, error) {
+	var p ankiconnect.ParamsDeleteDecks
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if !p.CardsToo {
+		return nil, errors.New(deleteDecksErrMsg)
+	}
+	if p.Decks != nil {
+		for _, name := range *p.Decks {
+			s.col.deleteDeck(name)
+		}
+	}
+	return nil, nil
+}
+
+func handleGetDeckStats(s *Server, params json.RawMessage) (interface{}, error) {
+	var p paramsDecks
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	stats := map[string]interface{}{}
+	for _, name := range p.Decks {
+		d, ok := s.col.decks[name]
+		if !ok {
+			continue
+		}
+		var newCount, learnCount, reviewCount, total int
+		for _, c := range s.col.cards {
+			if c.Deck != d.Name {
+				continue
+			}
+			total++
+			switch c.Queue {
+			case QueueNew:
+				newCount++
+			case QueueLearning:
+				learnCount++
+			case QueueReview:
+				if c.Due <= 0 {
+					reviewCount++
+				}
+			}
+		}
+		stats[strconv.FormatInt(d.Id, 10)] = map[string]interface{}{
+			"deck_id":       d.Id,
+			"name":          d.Name,
+			"new_count":     newCount,
+			"learn_count":   learnCount,
+			"review_count":  reviewCount,
+			"total_in_deck": total,
+		}
+	}
+	return stats, nil
+}
+
+func handleFindNotes(s *Server, params json.RawMessage) (interface{}, error) {
+	var p ankiconnect.ParamsFindNotes
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	cards, err := s.col.search(p.Query)
+	if err != nil {
+		return nil, err
+	}
+	ids := []int64{}
+	seen := map[int64]bool{}
+	for _, c := range cards {
+		if !seen[c.NoteId] {
+			seen[c.NoteId] = true
+			ids = append(ids, c.NoteId)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+func handleNotesInfo(s *Server, params json.RawMessage) (interface{}, error) {
+	var p paramsNotes
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	infos := make([]interface{}, len(p.Notes))
+	for i, id := range p.Notes {
+		n, ok := s.col.notes[id]
+		if !ok {
+			infos[i] = map[string]interface{}{}
+			continue
+		}
+		infos[i] = s.col.noteInfo(n)
+	}
+	return infos, nil
+}
+
+func handleAddNote(s *Server, params json.RawMessage) (interface{}, error) {
+	var p ankiconnect.ParamsCreateNote
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Note == nil {
+		return nil, errors.New("note is required")
+	}
+	if msg := s.col.checkNote(p.Note); msg != "" {
+		return nil, errors.New(msg)
+	}
+	return s.col.addNote(p.Note), nil
+}
+
+func handleAddNotes(s *Server, params json.RawMessage) (interface{}, error) {
+	var p paramsAddNotes
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	ids := make([]*int64, len(p.Notes))
+	for i := range p.Notes {
+		if s.col.checkNote(&p.Notes[i]) != "" {
+			continue
+		}
+		id := s.col.addNote(&p.Notes[i])
+		ids[i] = &id
+	}
+	return ids, nil
+}
+
+func handleCanAddNotes(s *Server, params json.RawMessage) (interface{}, error) {
+	var p paramsAddNotes
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	result := make([]bool, len(p.Notes))
+	for i := range p.Notes {
+		result[i] = s.col.checkNote(&p.Notes[i]) == ""
+	}
+	return result, nil
+}
+
+func handleDeleteNotes(s *Server, params json.RawMessage) (interface{}, error) {
+	var p paramsNotes
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	for _, id := range p.Notes {
+		s.col.deleteNote(id)
+	}
+	return nil, nil
+}
+
+func handleUpdateNoteFields(s *Server, params json.RawMessage) (interface{}, error) {
+	var p ankiconnect.ParamsUpdateNote
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Note == nil {
+		return nil, errors.New("note is required")
+	}
+	n, ok := s.col.notes[p.Note.Id]
+	if !ok {
+		return nil, fmt.Errorf("Note was not found: %d", p.Note.Id)
+	}
+	for name, value := range p.Note.Fields {
+		if _, ok := n.Fields[name]; ok {
+			n.Fields[name] = value
+		}
+	}
+	for _, media := range p.Note.Audio {
+		s.col.attachMedia(n, media.Filename, media.Data, media.Fields, "[sound:%s]")
+	}
+	for _, media := range p.Note.Video {
+		s.col.attachMedia(n, media.Filename, media.Data, media.Fields, "[sound:%s]")
+	}
+	for _, media := range p.Note.Picture {
+		s.col.attachMedia(n, media.Filename, media.Data, media.Fields, `<img src="%s">`)
+	}
+	n.Mod++
+	return nil, nil
+}
+
+func handleAddTags(s *Server, params json.RawMessage) (interface{}, error) {
+	var p ankiconnect.ParamsAddTags
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Notes == nil {
+		return nil, errors.New("notes is required")
+	}
+	for _, id := range *p.Notes {
+		n, ok := s.col.notes[id]
+		if !ok {
+			continue
+		}
+		for _, tag := range strings.Fields(p.Tags) {
+			if !hasTag(n.Tags, tag) {
+				n.Tags = append(n.Tags, tag)
+			}
+		}
+		n.Mod++
+	}
+	return nil, nil
+}
+
+// hasTag reports whether the tag is in tags, tags are compared case-insensitively like in Anki.
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func handleFindCards(s *Server, params json.RawMessage) (interface{}, error) {
+	var p ankiconnect.ParamsFindCards
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	cards, err := s.col.search(p.Query)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, len(cards))
+	for i, c := range cards {
+		ids[i] = c.Id
+	}
+	return ids, nil
+}
+
+func handleCardsInfo(s *Server, params json.RawMessage) (interface{}, error) {
+	var p paramsCards
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	infos := make([]interface{}, len(p.Cards))
+	for i, id := range p.Cards {
+		c, ok := s.col.cards[id]
+		if !ok {
+			infos[i] = map[string]interface{}{}
+			continue
+		}
+		infos[i] = s.col.cardInfo(c)
+	}
+	return infos, nil
+}
+
+func handleModelNames(s *Server, _ json.RawMessage) (interface{}, error) {
+	return s.col.modelNames(), nil
+}
+
+func handleModelFieldNames(s *Server, params json.RawMessage) (interface{}, error) {
+	var p ankiconnect.ParamsModelNames
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	m, ok := s.col.models[p.ModelName]
+	if !ok {
+		return nil, errors.New("model was not found: " + p.ModelName)
+	}
+	return m.Fields, nil
+}
+
+func handleCreateModel(s *Server, params json.RawMessage) (interface{}, error) {
+	var p ankiconnect.Model
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if _, ok := s.col.models[p.ModelName]; ok {
+		return nil, errors.New("Model name already exists")
+	}
+	if len(p.InOrderFields) == 0 {
+		return nil, errors.New("Must provide at least one field for inOrderFields")
+	}
+	if len(p.CardTemplates) == 0 {
+		return nil, errors.New("Must provide at least one card for cardTemplates")
+	}
+	m := s.col.addModel(Model{
+		Name:      p.ModelName,
+		Fields:    p.InOrderFields,
+		Css:       p.Css,
+		IsCloze:   p.IsCloze,
+		Templates: p.CardTemplates,
+	})
+
+	return modelJSON(m), nil
+}
+
+func handleFindModelsByName(s *Server, params json.RawMessage) (interface{}, error) {
+	var p ankiconnect.ParamsFindModelsByName
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	var names []string
+	if p.ModelNames != nil {
+		names = *p.ModelNames
+	}
+	models := make([]map[string]interface{}, 0, len(names))
+	for _, name := range names {
+		m, ok := s.col.models[name]
+		if !ok {
+			return nil, errors.New("model was not found: " + name)
+		}
+		models = append(models, modelJSON(m))
+	}
+	return models, nil
+}
+
+// modelJSON returns the model in the format of the models returned by Anki.
+func modelJSON(m *Model) map[string]interface{} {
+	flds := make([]map[string]interface{}, len(m.Fields))
+	for i, f := range m.Fields {
+		flds[i] = map[string]interface{}{"name": f, "ord": i, "sticky": false, "rtl": false, "font": "Arial", "size": 20, "media": []interface{}{}}
+	}
+	tmpls := make([]map[string]interface{}, len(m.Templates))
+	for i, t := range m.Templates {
+		tmpls[i] = map[string]interface{}{"name": t.Name, "ord": i, "qfmt": t.Front, "afmt": t.Back, "did": nil, "bqfmt": "", "bafmt": ""}
+	}
+	modelType := 0
+	if m.IsCloze {
+		modelType = 1
+	}
+	return map[string]interface{}{
+		"sortf": m.SortField, "did": 1, "latexPre": "", "latexPost": "", "mod": 0, "usn": -1, "vers": []interface{}{},
+		"type": modelType, "css": m.Css, "name": m.Name, "flds": flds, "tmpls": tmpls,
+		"tags": []interface{}{}, "id": m.Id, "req": [][]interface{}{},
+	}
+}
+
+func handleRetrieveMediaFile(s *Server, params json.RawMessage) (interface{}, error) {
+	var p ankiconnect.ParamsRetrieveMediaFile
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	content, ok := s.col.media[p.Filename]
+	if !ok {
+		// ankiconnect returns false instead of an error for missing files
+		return false, nil
+	}
+	return base64.StdEncoding.EncodeToString(content), nil
+}
+
+func handleStoreMediaFile(s *Server, params json.RawMessage) (interface{}, error) {
+	var p paramsStoreMediaFile
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Filename == "" {
+		return nil, errors.New("filename is required")
+	}
+	var content []byte
+	var err error
+	switch {
+	case p.Data != "":
+		content, err = base64.StdEncoding.DecodeString(p.Data)
+	case p.Path != "":
+		content, err = os.ReadFile(p.Path)
+	case p.URL != "":
+		return nil, errors.New("downloading media from a url is not supported by the fake server")
+	default:
+		return nil, errors.New("You must provide a \"data\", \"path\", or \"url\" field.")
+	}
+	if err != nil {
+		return nil, err
+	}
+	filename := p.Filename
+	if _, exists := s.col.media[filename]; exists && p.DeleteExisting != nil && !*p.DeleteExisting {
+		filename = uniqueMediaName(s.col.media, filename)
+	}
+	s.col.media[filename] = content
+	return filename, nil
+}
+
+func handleGetMediaFileNames(s *Server, params json.RawMessage) (interface{}, error) {
+	var p ankiconnect.ParamsGetMediaFileNames
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	pattern := p.Pattern
+	if pattern == "" {
+		pattern = "*"
+	}
+	names := []string{}
+	for name := range s.col.media {
+		if ok, _ := path.Match(pattern, name); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func handleDeleteMediaFile(s *Server, params json.RawMessage) (interface{}, error) {
+	var p ankiconnect.ParamsDeleteMediaFile
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	delete(s.col.media, p.Filename)
+	return nil, nil
+}
+
+// uniqueMediaName appends a number to the filename like Anki does when a file with the name exists.
+func uniqueMediaName(media map[string][]byte, filename string) string {
+	ext := path.Ext(filename)
+	base := strings.TrimSuffix(filename, ext)
+	for i := 1; ; i++ {
+		name := fmt.Sprintf("%s-%d%s", base, i, ext)
+		if _, exists := media[name]; !exists {
+			return name
+		}
+	}
+}
@@ -0,0 +1,485 @@
+package ankiconnecttest
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/atselvan/ankiconnect"
+)
+
+const (
+	defaultDeckName = "Default"
+
+	// firstId is the first id handed out by the collection. Anki uses epoch milliseconds as ids,
+	// the fake collection counts up from a fixed value to keep tests deterministic.
+	firstId int64 = 1600000000000
+
+	// Card types and queues as used by Anki.
+	CardTypeNew      = 0
+	CardTypeLearning = 1
+	CardTypeReview   = 2
+	QueueSuspended   = -1
+	QueueNew         = 0
+	QueueLearning    = 1
+	QueueReview      = 2
+)
+
+var clozePattern = regexp.MustCompile(`{{c(\d+)::`)
+
+type (
+	// Deck is a deck of the fake collection.
+	Deck struct {
+		Id   int64
+		Name string
+	}
+
+	// Model is a model (note type) of the fake collection.
+	Model struct {
+		Id        int64
+		Name      string
+		Fields    []string
+		Css       string
+		IsCloze   bool
+		Templates []ankiconnect.CardTemplate
+		// SortField is the index of the sort field in Fields.
+		SortField int
+	}
+
+	// Note is a note of the fake collection.
+	Note struct {
+		Id     int64
+		Model  string
+		Fields map[string]string
+		Tags   []string
+		Mod    int64
+		Cards  []int64
+	}
+
+	// Card is a card of the fake collection.
+	// The scheduling values can be changed with Server.UpdateCard to set up tests.
+	Card struct {
+		Id       int64
+		NoteId   int64
+		Deck     string
+		Ord      int64
+		Type     int64
+		Queue    int64
+		Due      int64
+		Interval int64
+		Reps     int64
+		Lapses   int64
+		Left     int64
+		Flags    int64
+		Mod      int64
+		// Added is the number of days since the card was added, 0 means today.
+		Added int64
+		// Rated is the number of days since the card was last answered, -1 means never.
+		Rated int64
+	}
+
+	// collection is the in memory Anki collection served by the fake server.
+	collection struct {
+		nextId int64
+		decks  map[string]*Deck
+		models map[string]*Model
+		notes  map[int64]*Note
+		cards  map[int64]*Card
+		media  map[string][]byte
+	}
+)
+
+// newCollection returns a collection with the Default deck and the standard models.
+func newCollection() *collection {
+	col := &collection{
+		nextId: firstId,
+		decks:  map[string]*Deck{},
+		models: map[string]*Model{},
+		notes:  map[int64]*Note{},
+		cards:  map[int64]*Card{},
+		media:  map[string][]byte{},
+	}
+	col.createDeck(defaultDeckName)
+	col.addModel(Model{
+		Name:   "Basic",
+		Fields: []string{"Front", "Back"},
+		Templates: []ankiconnect.CardTemplate{
+			{Name: "Card 1", Front: "{{Front}}", Back: "{{FrontSide}}<hr id=answer>{{Back}}"},
+		},
+	})
+	col.addModel(Model{
+		Name:   "Basic (and reversed card)",
+		Fields: []string{"Front", "Back"},
+		Templates: []ankiconnect.CardTemplate{
+			{Name: "Card 1", Front: "{{Front}}", Back: "{{FrontSide}}<hr id=answer>{{Back}}"},
+			{Name: "Card 2", Front: "{{Back}}", Back: "{{FrontSide}}<hr id=answer>{{Front}}"},
+		},
+	})
+	col.addModel(Model{
+		Name:    "Cloze",
+		Fields:  []string{"Text", "Back Extra"},
+		IsCloze: true,
+		Templates: []ankiconnect.CardTemplate{
+			{Name: "Cloze", Front: "{{cloze:Text}}", Back: "{{cloze:Text}}<br>{{Back Extra}}"},
+		},
+	})
+	return col
+}
+
+func (col *collection) id() int64 {
+	id := col.nextId
+	col.nextId++
+	return id
+}
+
+// createDeck creates the deck and its parents if they do not exist and returns the id of the deck.
+func (col *collection) createDeck(name string) int64 {
+	parts := strings.Split(name, "::")
+	for i := 1; i < len(parts); i++ {
+		col.createDeck(strings.Join(parts[:i], "::"))
+	}
+	if d, ok := col.decks[name]; ok {
+		return d.Id
+	}
+	d := &Deck{Id: col.id(), Name: name}
+	col.decks[name] = d
+	return d.Id
+}
+
+// deleteDeck deletes the deck, its children and all of their cards.
+func (col *collection) deleteDeck(name string) {
+	for deckName := range col.decks {
+		if deckName != name && !strings.HasPrefix(deckName, name+"::") {
+			continue
+		}
+		for _, card := range col.cards {
+			if card.Deck == deckName {
+				col.deleteCard(card.Id)
+			}
+		}
+		delete(col.decks, deckName)
+	}
+	if len(col.decks) == 0 {
+		col.createDeck(defaultDeckName)
+	}
+}
+
+func (col *collection) deckNames() []string {
+	names := make([]string, 0, len(col.decks))
+	for name := range col.decks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (col *collection) addModel(m Model) *Model {
+	m.Id = col.id()
+	col.models[m.Name] = &m
+	return &m
+}
+
+func (col *collection) modelNames() []string {
+	names := make([]string, 0, len(col.models))
+	for name := range col.models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkNote validates a note that is about to be added and returns the ankiconnect error message if it is invalid.
+func (col *collection) checkNote(note *ankiconnect.Note) string {
+	m, ok := col.models[note.ModelName]
+	if !ok {
+		return "model was not found: " + note.ModelName
+	}
+	if _, ok := col.decks[note.DeckName]; !ok {
+		return "deck was not found: " + note.DeckName
+	}
+	for name := range note.Fields {
+		if indexOf(m.Fields, name) < 0 {
+			return fmt.Sprintf("field was not found in model %s: %s", m.Name, name)
+		}
+	}
+	first := strings.TrimSpace(note.Fields[m.Fields[0]])
+	if first == "" {
+		return "cannot create note because it is empty"
+	}
+	if m.IsCloze && !clozePattern.MatchString(note.Fields[m.Fields[0]]) {
+		return "cannot create note because it is empty"
+	}
+	if col.isDuplicate(note, m) {
+		return "cannot create note because it is a duplicate"
+	}
+	return ""
+}
+
+// isDuplicate reports whether the note is a duplicate according to its duplicate options.
+func (col *collection) isDuplicate(note *ankiconnect.Note, m *Model) bool {
+	opts := note.Options
+	if opts == nil {
+		opts = &ankiconnect.Options{}
+	}
+	if opts.AllowDuplicate {
+		return false
+	}
+	deckName, checkChildren, checkAllModels := "", false, false
+	if opts.DuplicateScope == "deck" {
+		deckName = note.DeckName
+		if opts.DuplicateScopeOptions != nil {
+			if opts.DuplicateScopeOptions.DeckName != "" {
+				deckName = opts.DuplicateScopeOptions.DeckName
+			}
+			checkChildren = opts.DuplicateScopeOptions.CheckChildren
+		}
+	}
+	if opts.DuplicateScopeOptions != nil {
+		checkAllModels = opts.DuplicateScopeOptions.CheckAllModels
+	}
+
+	key := strings.TrimSpace(note.Fields[m.Fields[0]])
+	for _, other := range col.notes {
+		om := col.models[other.Model]
+		if !checkAllModels && om.Name != m.Name {
+			continue
+		}
+		if strings.TrimSpace(other.Fields[om.Fields[0]]) != key {
+			continue
+		}
+		if deckName == "" {
+			return true
+		}
+		for _, cid := range other.Cards {
+			d := col.cards[cid].Deck
+			if d == deckName || (checkChildren && strings.HasPrefix(d, deckName+"::")) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// addNote adds a valid note and creates its cards.
+func (col *collection) addNote(note *ankiconnect.Note) int64 {
+	m := col.models[note.ModelName]
+	n := &Note{
+		Id:     col.id(),
+		Model:  m.Name,
+		Fields: map[string]string{},
+		Tags:   normalizeTags(note.Tags),
+	}
+	for _, f := range m.Fields {
+		n.Fields[f] = note.Fields[f]
+	}
+	for _, media := range note.Audio {
+		col.attachMedia(n, media.Filename, media.Data, media.Fields, "[sound:%s]")
+	}
+	for _, media := range note.Video {
+		col.attachMedia(n, media.Filename, media.Data, media.Fields, "[sound:%s]")
+	}
+	for _, media := range note.Picture {
+		col.attachMedia(n, media.Filename, media.Data, media.Fields, `<img src="%s">`)
+	}
+	col.notes[n.Id] = n
+	col.generateCards(n, note.DeckName)
+	return n.Id
+}
+
+// attachMedia stores the media file and appends a reference to it to the fields.
+func (col *collection) attachMedia(n *Note, filename, data string, fields []string, format string) {
+	if filename == "" {
+		return
+	}
+	content, _ := decodeBase64(data)
+	col.media[filename] = content
+	for _, f := range fields {
+		if _, ok := n.Fields[f]; ok {
+			n.Fields[f] += fmt.Sprintf(format, filename)
+		}
+	}
+}
+
+// generateCards creates one card per template, or one card per cloze number for cloze models.
+func (col *collection) generateCards(n *Note, deck string) {
+	m := col.models[n.Model]
+	var ords []int64
+	if m.IsCloze {
+		seen := map[int64]bool{}
+		for _, f := range m.Fields {
+			for _, match := range clozePattern.FindAllStringSubmatch(n.Fields[f], -1) {
+				ord, _ := strconv.ParseInt(match[1], 10, 64)
+				if ord > 0 && !seen[ord-1] {
+					seen[ord-1] = true
+					ords = append(ords, ord-1)
+				}
+			}
+		}
+		sort.Slice(ords, func(i, j int) bool { return ords[i] < ords[j] })
+	} else {
+		for i := range m.Templates {
+			ords = append(ords, int64(i))
+		}
+	}
+	for _, ord := range ords {
+		c := &Card{
+			Id:     col.id(),
+			NoteId: n.Id,
+			Deck:   deck,
+			Ord:    ord,
+			Type:   CardTypeNew,
+			Queue:  QueueNew,
+			Due:    int64(len(col.cards) + 1),
+			Rated:  -1,
+		}
+		col.cards[c.Id] = c
+		n.Cards = append(n.Cards, c.Id)
+	}
+}
+
+func (col *collection) deleteNote(id int64) {
+	n, ok := col.notes[id]
+	if !ok {
+		return
+	}
+	for _, cid := range n.Cards {
+		delete(col.cards, cid)
+	}
+	delete(col.notes, id)
+}
+
+func (col *collection) deleteCard(id int64) {
+	c, ok := col.cards[id]
+	if !ok {
+		return
+	}
+	delete(col.cards, id)
+	n := col.notes[c.NoteId]
+	for i, cid := range n.Cards {
+		if cid == id {
+			n.Cards = append(n.Cards[:i], n.Cards[i+1:]...)
+			break
+		}
+	}
+	if len(n.Cards) == 0 {
+		delete(col.notes, n.Id)
+	}
+}
+
+// noteInfo returns the note in the format of the notesInfo action.
+func (col *collection) noteInfo(n *Note) map[string]interface{} {
+	m := col.models[n.Model]
+	return map[string]interface{}{
+		"noteId":    n.Id,
+		"modelName": n.Model,
+		"tags":      n.Tags,
+		"fields":    fieldData(m, n),
+		"cards":     n.Cards,
+		"mod":       n.Mod,
+	}
+}
+
+// cardInfo returns the card in the format of the cardsInfo action.
+func (col *collection) cardInfo(c *Card) map[string]interface{} {
+	n := col.notes[c.NoteId]
+	m := col.models[n.Model]
+	question, answer := render(m, n, c.Ord)
+	return map[string]interface{}{
+		"answer":     answer,
+		"question":   question,
+		"deckName":   c.Deck,
+		"modelName":  m.Name,
+		"fieldOrder": c.Ord,
+		"fields":     fieldData(m, n),
+		"css":        m.Css,
+		"cardId":     c.Id,
+		"interval":   c.Interval,
+		"note":       n.Id,
+		"ord":        c.Ord,
+		"type":       c.Type,
+		"queue":      c.Queue,
+		"due":        c.Due,
+		"reps":       c.Reps,
+		"lapses":     c.Lapses,
+		"left":       c.Left,
+		"mod":        c.Mod,
+	}
+}
+
+func fieldData(m *Model, n *Note) map[string]ankiconnect.FieldData {
+	fields := map[string]ankiconnect.FieldData{}
+	for i, f := range m.Fields {
+		fields[f] = ankiconnect.FieldData{Value: n.Fields[f], Order: int64(i)}
+	}
+	return fields
+}
+
+// render renders the question and the answer of a card by substituting the fields in the templates.
+// Only plain field references, FrontSide and cloze deletions are supported.
+func render(m *Model, n *Note, ord int64) (string, string) {
+	tmpl := m.Templates[0]
+	if !m.IsCloze && int(ord) < len(m.Templates) {
+		tmpl = m.Templates[ord]
+	}
+	question := substitute(tmpl.Front, n.Fields, "", ord, true)
+	answer := substitute(tmpl.Back, n.Fields, question, ord, false)
+	return question, answer
+}
+
+var (
+	templateFieldPattern = regexp.MustCompile(`{{(?:cloze:)?([^}]+)}}`)
+	clozeSpanPattern     = regexp.MustCompile(`{{c(\d+)::(.*?)(?:::(.*?))?}}`)
+)
+
+func substitute(tmpl string, fields map[string]string, frontSide string, ord int64, question bool) string {
+	return templateFieldPattern.ReplaceAllStringFunc(tmpl, func(ref string) string {
+		name := templateFieldPattern.FindStringSubmatch(ref)[1]
+		if name == "FrontSide" {
+			return frontSide
+		}
+		value := fields[name]
+		if !strings.HasPrefix(ref, "{{cloze:") {
+			return value
+		}
+		return clozeSpanPattern.ReplaceAllStringFunc(value, func(span string) string {
+			parts := clozeSpanPattern.FindStringSubmatch(span)
+			n, _ := strconv.ParseInt(parts[1], 10, 64)
+			if n-1 != ord {
+				return parts[2]
+			}
+			if !question {
+				return `<span class=cloze>` + parts[2] + `</span>`
+			}
+			if parts[3] != "" {
+				return `<span class=cloze>[` + parts[3] + `]</span>`
+			}
+			return `<span class=cloze>[...]</span>`
+		})
+	})
+}
+
+func normalizeTags(tags []string) []string {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, t := range tags {
+		for _, tag := range strings.Fields(t) {
+			if !seen[strings.ToLower(tag)] {
+				seen[strings.ToLower(tag)] = true
+				normalized = append(normalized, tag)
+			}
+		}
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,386 @@
+package ankiconnecttest
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// matcher reports whether a card of the collection matches a search term.
+type matcher func(col *collection, c *Card) bool
+
+var propPattern = regexp.MustCompile(`^(ivl|due|reps|lapses|pos)(<=|>=|!=|=|<|>)(-?\d+)$`)
+
+// search returns the cards matching an Anki search query.
+// The supported syntax is a subset of the Anki search syntax: plain text, quoted text,
+// negation with "-", "or", grouping with parentheses and the deck:, note:, tag:, card:,
+// field:, nid:, cid:, is:, flag:, added:, rated:, prop: and re: searches.
+func (col *collection) search(query string) ([]*Card, error) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	m, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("invalid search: unexpected %q", p.tokens[p.pos])
+	}
+
+	var cards []*Card
+	for _, c := range col.cards {
+		if m == nil || m(col, c) {
+			cards = append(cards, c)
+		}
+	}
+	sortCards(cards)
+	return cards, nil
+}
+
+// tokenize splits a search query into terms, parentheses and negations.
+// Quotes group text containing spaces, they can start in the middle of a term (deck:"a b").
+func tokenize(query string) ([]string, error) {
+	var tokens []string
+	var current strings.Builder
+	inQuotes, escaped := false, false
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+	for _, r := range query {
+		switch {
+		case escaped:
+			current.WriteRune('\\')
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+			current.WriteRune(r)
+		case unicode.IsSpace(r):
+			flush()
+		case r == '(' || r == ')':
+			flush()
+			tokens = append(tokens, string(r))
+		case r == '-' && current.Len() == 0:
+			tokens = append(tokens, "-")
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("invalid search: unterminated quote")
+	}
+	flush()
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *parser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *parser) parseOr() (matcher, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for strings.EqualFold(p.peek(), "or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if left == nil || right == nil {
+			return nil, fmt.Errorf("invalid search: or without terms")
+		}
+		l, r := left, right
+		left = func(col *collection, c *Card) bool { return l(col, c) || r(col, c) }
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (matcher, error) {
+	var terms []matcher
+	for {
+		tok := p.peek()
+		if tok == "" || tok == ")" || strings.EqualFold(tok, "or") {
+			break
+		}
+		if strings.EqualFold(tok, "and") {
+			p.pos++
+			continue
+		}
+		m, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, m)
+	}
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	return func(col *collection, c *Card) bool {
+		for _, t := range terms {
+			if !t(col, c) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func (p *parser) parseUnary() (matcher, error) {
+	tok := p.peek()
+	p.pos++
+	switch tok {
+	case "-":
+		m, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(col *collection, c *Card) bool { return !m(col, c) }, nil
+	case "(":
+		m, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("invalid search: missing )")
+		}
+		p.pos++
+		if m == nil {
+			return func(*collection, *Card) bool { return true }, nil
+		}
+		return m, nil
+	}
+	return parseTerm(tok)
+}
+
+// parseTerm returns the matcher for a single search term.
+func parseTerm(term string) (matcher, error) {
+	key, value, ok := strings.Cut(term, ":")
+	if !ok || strings.Contains(key, "\\") {
+		return textMatcher(term), nil
+	}
+	switch strings.ToLower(key) {
+	case "deck":
+		if value == "current" {
+			value = defaultDeckName
+		}
+		pattern := wildcard(value, true)
+		return func(col *collection, c *Card) bool {
+			return pattern.MatchString(c.Deck) || pattern.MatchString(parentOf(c.Deck, value))
+		}, nil
+	case "note":
+		pattern := wildcard(value, true)
+		return func(col *collection, c *Card) bool {
+			return pattern.MatchString(col.notes[c.NoteId].Model)
+		}, nil
+	case "tag":
+		pattern := wildcard(value, true)
+		return func(col *collection, c *Card) bool {
+			for _, t := range col.notes[c.NoteId].Tags {
+				if pattern.MatchString(t) || pattern.MatchString(parentOf(t, value)) {
+					return true
+				}
+			}
+			return value == "none" && len(col.notes[c.NoteId].Tags) == 0
+		}, nil
+	case "card":
+		return cardMatcher(value), nil
+	case "nid", "cid":
+		ids := map[int64]bool{}
+		for _, s := range strings.Split(value, ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid search: %s", term)
+			}
+			ids[id] = true
+		}
+		if strings.ToLower(key) == "nid" {
+			return func(col *collection, c *Card) bool { return ids[c.NoteId] }, nil
+		}
+		return func(col *collection, c *Card) bool { return ids[c.Id] }, nil
+	case "is":
+		return isMatcher(value)
+	case "flag":
+		flag, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid search: %s", term)
+		}
+		return func(col *collection, c *Card) bool { return c.Flags == flag }, nil
+	case "added", "rated":
+		days, err := strconv.ParseInt(strings.Split(value, ":")[0], 10, 64)
+		if err != nil || days < 1 {
+			return nil, fmt.Errorf("invalid search: %s", term)
+		}
+		if strings.ToLower(key) == "added" {
+			return func(col *collection, c *Card) bool { return c.Added < days }, nil
+		}
+		return func(col *collection, c *Card) bool { return c.Rated >= 0 && c.Rated < days }, nil
+	case "prop":
+		return propMatcher(value, term)
+	case "re":
+		re, err := regexp.Compile("(?i)" + value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid search: %s", term)
+		}
+		return func(col *collection, c *Card) bool {
+			for _, v := range col.notes[c.NoteId].Fields {
+				if re.MatchString(v) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	case "mid", "did", "dupe", "preset", "resched", "introduced", "nc", "w":
+		return nil, fmt.Errorf("invalid search: %s is not supported by the fake server", key)
+	}
+
+	// field:value searches match the whole field content
+	field, pattern := key, wildcard(value, true)
+	return func(col *collection, c *Card) bool {
+		for name, v := range col.notes[c.NoteId].Fields {
+			if strings.EqualFold(name, field) && pattern.MatchString(v) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// textMatcher matches cards with a field containing the text.
+func textMatcher(text string) matcher {
+	pattern := wildcard(text, false)
+	return func(col *collection, c *Card) bool {
+		for _, v := range col.notes[c.NoteId].Fields {
+			if pattern.MatchString(v) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func cardMatcher(value string) matcher {
+	if ord, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return func(col *collection, c *Card) bool { return c.Ord == ord-1 }
+	}
+	pattern := wildcard(value, true)
+	return func(col *collection, c *Card) bool {
+		m := col.models[col.notes[c.NoteId].Model]
+		if m.IsCloze || int(c.Ord) >= len(m.Templates) {
+			return false
+		}
+		return pattern.MatchString(m.Templates[c.Ord].Name)
+	}
+}
+
+func isMatcher(value string) (matcher, error) {
+	switch strings.ToLower(value) {
+	case "new":
+		return func(col *collection, c *Card) bool { return c.Type == CardTypeNew }, nil
+	case "learn":
+		return func(col *collection, c *Card) bool { return c.Type == CardTypeLearning }, nil
+	case "review":
+		return func(col *collection, c *Card) bool { return c.Type == CardTypeReview }, nil
+	case "due":
+		return func(col *collection, c *Card) bool {
+			return (c.Queue == QueueReview || c.Queue == QueueLearning) && c.Due <= 0
+		}, nil
+	case "suspended":
+		return func(col *collection, c *Card) bool { return c.Queue == QueueSuspended }, nil
+	}
+	return nil, fmt.Errorf("invalid search: is:%s is not supported by the fake server", value)
+}
+
+func propMatcher(value, term string) (matcher, error) {
+	m := propPattern.FindStringSubmatch(strings.ToLower(value))
+	if m == nil {
+		return nil, fmt.Errorf("invalid search: %s", term)
+	}
+	n, _ := strconv.ParseInt(m[3], 10, 64)
+	get := map[string]func(c *Card) int64{
+		"ivl":    func(c *Card) int64 { return c.Interval },
+		"due":    func(c *Card) int64 { return c.Due },
+		"reps":   func(c *Card) int64 { return c.Reps },
+		"lapses": func(c *Card) int64 { return c.Lapses },
+		"pos":    func(c *Card) int64 { return c.Due },
+	}[m[1]]
+	op := m[2]
+	return func(col *collection, c *Card) bool {
+		v := get(c)
+		switch op {
+		case "<":
+			return v < n
+		case "<=":
+			return v <= n
+		case ">":
+			return v > n
+		case ">=":
+			return v >= n
+		case "!=":
+			return v != n
+		default:
+			return v == n
+		}
+	}, nil
+}
+
+// wildcard converts a Anki search pattern into a case-insensitive regular expression.
+// "*" matches any sequence of characters and "_" a single character, both can be escaped with "\".
+// If whole is false the pattern matches anywhere in the value.
+func wildcard(pattern string, whole bool) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("(?is)")
+	if whole {
+		b.WriteString("^")
+	}
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '*':
+			b.WriteString(".*")
+		case r == '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	if whole {
+		b.WriteString("$")
+	}
+	return regexp.MustCompile(b.String())
+}
+
+// parentOf returns the ancestor of a hierarchical name ("a::b::c") with as many components as pattern,
+// so that searching for a deck or tag also matches its children.
+func parentOf(name, pattern string) string {
+	parts := strings.Split(name, "::")
+	n := len(strings.Split(pattern, "::"))
+	if n >= len(parts) {
+		return name
+	}
+	return strings.Join(parts[:n], "::")
+}
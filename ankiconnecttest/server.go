@@ -0,0 +1,288 @@
+// Package ankiconnecttest provides an in-process stand-in for the ankiconnect add-on to be used in tests.
+//
+// The Server keeps an in-memory collection of decks, models, notes, cards, tags and media and implements
+// the actions used by the ankiconnect client with the semantics and the error messages of ankiconnect,
+// so that behaviour can be tested without Anki running.
+//
+//	server := ankiconnecttest.NewServer()
+//	defer server.Close()
+//
+//	client := server.Client()
+//	err := client.Decks.Create("Japanese")
+package ankiconnecttest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+
+	"github.com/atselvan/ankiconnect"
+)
+
+const (
+	// Version is the ankiconnect api version reported by the server.
+	Version = 6
+
+	unsupportedActionErrMsg = "unsupported action"
+	invalidAPIKeyErrMsg     = "valid api key must be provided"
+)
+
+type (
+	// Server is a fake ankiconnect api backed by an in-memory collection.
+	Server struct {
+		*httptest.Server
+
+		mu       sync.Mutex
+		col      *collection
+		apiKey   string
+		failures map[string]string
+		actions  []string
+	}
+
+	// handler executes an action with the json encoded params and returns the result or an error message.
+	handler func(s *Server, params json.RawMessage) (interface{}, error)
+
+	// request is the request payload sent by ankiconnect clients.
+	request struct {
+		Action  string          `json:"action"`
+		Version int             `json:"version"`
+		Params  json.RawMessage `json:"params"`
+		Key     string          `json:"key"`
+	}
+
+	// response is the response payload returned to ankiconnect clients.
+	response struct {
+		Result interface{} `json:"result"`
+		Error  interface{} `json:"error"`
+	}
+)
+
+// handlers maps the supported actions to their implementation.
+// It is populated in init because the handlers refer to it for the multi and apiReflect actions.
+var handlers map[string]handler
+
+func init() {
+	handlers = map[string]handler{
+		ankiconnect.ActionVersion:           handleVersion,
+		ankiconnect.ActionAPIReflect:        handleAPIReflect,
+		ankiconnect.ActionRequestPermission: handleRequestPermission,
+		ankiconnect.ActionMulti:             handleMulti,
+		ankiconnect.ActionSync:              handleSync,
+		ankiconnect.ActionDeckNames:         handleDeckNames,
+		ankiconnect.ActionCreateDeck:        handleCreateDeck,
+		ankiconnect.ActionDeleteDecks:       handleDeleteDecks,
+		ankiconnect.ActionGetDeckStats:      handleGetDeckStats,
+		ankiconnect.ActionFindNotes:         handleFindNotes,
+		ankiconnect.ActionNotesInfo:         handleNotesInfo,
+		ankiconnect.ActionAddNote:           handleAddNote,
+		ankiconnect.ActionAddNotes:          handleAddNotes,
+		actionCanAddNotes:                   handleCanAddNotes,
+		ankiconnect.ActionDeleteNotes:       handleDeleteNotes,
+		ankiconnect.ActionUpdateNoteFields:  handleUpdateNoteFields,
+		ankiconnect.ActionAddTags:           handleAddTags,
+		ankiconnect.ActionFindCards:         handleFindCards,
+		ankiconnect.ActionCardsInfo:         handleCardsInfo,
+		ankiconnect.ActionModelNames:        handleModelNames,
+		ankiconnect.ActionModelFieldNames:   handleModelFieldNames,
+		ankiconnect.ActionCreateModel:       handleCreateModel,
+		ankiconnect.ActionFindModelsByName:  handleFindModelsByName,
+		ankiconnect.ActionRetrieveMedia:     handleRetrieveMediaFile,
+		ankiconnect.ActionStoreMedia:        handleStoreMediaFile,
+		ankiconnect.ActionGetMediaNames:     handleGetMediaFileNames,
+		ankiconnect.ActionDeleteMedia:       handleDeleteMediaFile,
+	}
+}
+
+// NewServer starts and returns a Server with a collection containing the Default deck and the
+// Basic, Basic (and reversed card) and Cloze models. The caller should call Close when finished.
+func NewServer() *Server {
+	s := &Server{
+		col:      newCollection(),
+		failures: map[string]string{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Client returns a ankiconnect client that sends its requests to the server.
+func (s *Server) Client() *ankiconnect.Client {
+	return ankiconnect.NewClient().SetURL(s.URL)
+}
+
+// SetAPIKey configures the server to reject requests without the api key, like ankiconnect
+// configured with an apiKey. An empty key disables the check.
+func (s *Server) SetAPIKey(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apiKey = key
+}
+
+// FailAction makes every following request for the action fail with the error message.
+// An empty message removes the failure.
+func (s *Server) FailAction(action, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if message == "" {
+		delete(s.failures, action)
+		return
+	}
+	s.failures[action] = message
+}
+
+// Actions returns the actions of all requests received by the server in the order they were received.
+// The actions of a multi request are listed after the multi action itself.
+func (s *Server) Actions() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.actions...)
+}
+
+// AddDeck creates a deck and returns its id.
+func (s *Server) AddDeck(name string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.col.createDeck(name)
+}
+
+// AddModel adds a model to the collection.
+func (s *Server) AddModel(model ankiconnect.Model) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.col.addModel(Model{
+		Name:      model.ModelName,
+		Fields:    model.InOrderFields,
+		Css:       model.Css,
+		IsCloze:   model.IsCloze,
+		Templates: model.CardTemplates,
+	})
+}
+
+// SetSortField makes the field with the name the sort field of the model.
+func (s *Server) SetSortField(model, field string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.col.models[model]
+	if !ok {
+		return fmt.Errorf("model was not found: %s", model)
+	}
+	for i, f := range m.Fields {
+		if f == field {
+			m.SortField = i
+			return nil
+		}
+	}
+	return fmt.Errorf("field was not found: %s", field)
+}
+
+// AddNote adds a note to the collection and returns its id.
+// The note is validated in the same way as notes added through the api.
+func (s *Server) AddNote(note ankiconnect.Note) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if msg := s.col.checkNote(&note); msg != "" {
+		return 0, errors.New(msg)
+	}
+	return s.col.addNote(&note), nil
+}
+
+// Note returns a copy of the note with the id.
+func (s *Server) Note(id int64) (Note, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.col.notes[id]
+	if !ok {
+		return Note{}, false
+	}
+	cp := *n
+	cp.Fields = map[string]string{}
+	for k, v := range n.Fields {
+		cp.Fields[k] = v
+	}
+	cp.Tags = append([]string(nil), n.Tags...)
+	cp.Cards = append([]int64(nil), n.Cards...)
+	return cp, true
+}
+
+// UpdateCard calls update with the card with the id, e.g. to change its scheduling values.
+func (s *Server) UpdateCard(id int64, update func(card *Card)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.col.cards[id]
+	if !ok {
+		return fmt.Errorf("card was not found: %d", id)
+	}
+	update(c)
+	return nil
+}
+
+// Media returns the content of the media file with the filename.
+func (s *Server) Media(filename string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	content, ok := s.col.media[filename]
+	return content, ok
+}
+
+// serveHTTP answers GET requests like ankiconnect and executes the action of POST requests.
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "AnkiConnect v.%d", Version)
+		return
+	}
+
+	var req request
+	var resp response
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.Error = err.Error()
+	} else {
+		s.mu.Lock()
+		result, err := s.execute(req)
+		s.mu.Unlock()
+		if err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.Result = result
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// execute runs the action of the request. The caller must hold the lock.
+func (s *Server) execute(req request) (interface{}, error) {
+	s.actions = append(s.actions, req.Action)
+	if s.apiKey != "" && req.Key != s.apiKey && req.Action != ankiconnect.ActionRequestPermission {
+		return nil, errors.New(invalidAPIKeyErrMsg)
+	}
+	if msg, ok := s.failures[req.Action]; ok {
+		return nil, errors.New(msg)
+	}
+	h, ok := handlers[req.Action]
+	if !ok {
+		return nil, errors.New(unsupportedActionErrMsg)
+	}
+	return h(s, req.Params)
+}
+
+// decodeParams decodes the json params of a request into v.
+func decodeParams(params json.RawMessage, v interface{}) error {
+	if len(params) == 0 {
+		return nil
+	}
+	return json.Unmarshal(params, v)
+}
+
+func decodeBase64(data string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(data)
+}
+
+func sortCards(cards []*Card) {
+	sort.Slice(cards, func(i, j int) bool { return cards[i].Id < cards[j].Id })
+}
@@ -0,0 +1,286 @@
+package ankiconnecttest
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/atselvan/ankiconnect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func basicNote(deck, front, back string, tags ...string) ankiconnect.Note {
+	return ankiconnect.Note{
+		DeckName:  deck,
+		ModelName: "Basic",
+		Fields:    ankiconnect.Fields{"Front": front, "Back": back},
+		Tags:      tags,
+	}
+}
+
+func TestServer_Decks(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.Client()
+
+	require.NoError(t, client.Decks.Create("Japanese::Tokyo"))
+	decks, err := client.Decks.GetAll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Default", "Japanese", "Japanese::Tokyo"}, *decks)
+
+	require.NoError(t, client.Notes.Add(basicNote("Japanese::Tokyo", "猫", "cat")))
+	require.NoError(t, client.Decks.Delete("Japanese"))
+	decks, err = client.Decks.GetAll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Default"}, *decks)
+
+	notes, err := client.Notes.Search("")
+	require.NoError(t, err)
+	assert.Empty(t, *notes)
+}
+
+func TestServer_Notes(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.Client()
+
+	server.AddDeck("Japanese")
+	require.NoError(t, client.Notes.Add(basicNote("Japanese", "猫", "cat", "animal")))
+	require.NoError(t, client.Notes.Add(basicNote("Default", "dog", "犬", "animal::mammal")))
+	require.NoError(t, client.Notes.Add(basicNote("Default", "tree", "木")))
+
+	t.Run("duplicate", func(t *testing.T) {
+		err := client.Notes.Add(basicNote("Default", "猫", "cat"))
+		assert.ErrorIs(t, err, ankiconnect.ErrDuplicateNote)
+
+		note := basicNote("Default", "猫", "cat")
+		note.Options = &ankiconnect.Options{DuplicateScope: "deck"}
+		assert.NoError(t, client.Notes.Add(note))
+	})
+
+	t.Run("missing deck and model", func(t *testing.T) {
+		err := client.Notes.Add(basicNote("Unknown", "a", "b"))
+		assert.ErrorIs(t, err, ankiconnect.ErrDeckNotFound)
+
+		note := basicNote("Default", "a", "b")
+		note.ModelName = "Unknown"
+		err = client.Notes.Add(note)
+		assert.ErrorIs(t, err, ankiconnect.ErrModelNotFound)
+	})
+
+	t.Run("search", func(t *testing.T) {
+		tests := map[string]int{
+			"":                          4,
+			"deck:Japanese":             1,
+			`deck:"Default"`:            3,
+			"-deck:Default":             1,
+			"tag:animal":                2,
+			"tag:animal::mammal":        1,
+			"tag:none":                  2,
+			"front:猫":                   2,
+			"front:d*":                  1,
+			"back:_":                    2,
+			"cat":                       2,
+			"(front:dog or front:tree)": 2,
+			"tag:animal -deck:Japanese": 1,
+			"note:Basic is:new":         4,
+		}
+		for query, count := range tests {
+			ids, err := client.Notes.Search(query)
+			require.NoError(t, err, query)
+			assert.Len(t, *ids, count, query)
+		}
+	})
+
+	t.Run("get and update", func(t *testing.T) {
+		notes, err := client.Notes.Get("front:dog")
+		require.NoError(t, err)
+		require.Len(t, *notes, 1)
+		note := (*notes)[0]
+		assert.Equal(t, "Basic", note.ModelName)
+		assert.Equal(t, "犬", note.Fields["Back"].Value)
+		assert.Equal(t, []string{"animal::mammal"}, note.Tags)
+
+		err = client.Notes.Update(ankiconnect.UpdateNote{Id: note.NoteId, Fields: ankiconnect.Fields{"Back": "いぬ"}})
+		require.NoError(t, err)
+		updated, ok := server.Note(note.NoteId)
+		require.True(t, ok)
+		assert.Equal(t, "いぬ", updated.Fields["Back"])
+
+		err = client.Notes.Update(ankiconnect.UpdateNote{Id: 1, Fields: ankiconnect.Fields{"Back": "いぬ"}})
+		assert.Error(t, err)
+	})
+
+	t.Run("add tags", func(t *testing.T) {
+		ids, err := client.Notes.Search("front:dog")
+		require.NoError(t, err)
+		require.NoError(t, client.Notes.AddTags(*ids, "ANIMAL::Mammal", "pet"))
+		note, ok := server.Note((*ids)[0])
+		require.True(t, ok)
+		assert.Equal(t, []string{"animal::mammal", "pet"}, note.Tags)
+	})
+}
+
+func TestServer_Cards(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.Client()
+
+	note := ankiconnect.Note{
+		DeckName:  "Default",
+		ModelName: "Basic (and reversed card)",
+		Fields:    ankiconnect.Fields{"Front": "猫", "Back": "cat"},
+	}
+	require.NoError(t, client.Notes.Add(note))
+	cloze := ankiconnect.Note{
+		DeckName:  "Default",
+		ModelName: "Cloze",
+		Fields:    ankiconnect.Fields{"Text": "{{c1::Tokyo}} is the capital of {{c2::Japan::country}}"},
+	}
+	require.NoError(t, client.Notes.Add(cloze))
+
+	cards, err := client.Cards.Get("note:Cloze")
+	require.NoError(t, err)
+	require.Len(t, *cards, 2)
+	assert.Equal(t, "Tokyo is the capital of <span class=cloze>[country]</span>", (*cards)[1].Question)
+
+	ids, err := client.Cards.Search("card:2")
+	require.NoError(t, err)
+	require.Len(t, *ids, 2)
+
+	require.NoError(t, server.UpdateCard((*ids)[0], func(card *Card) {
+		card.Type, card.Queue, card.Interval, card.Due = CardTypeReview, QueueReview, 21, 0
+	}))
+	ids, err = client.Cards.Search("is:due prop:ivl>=21")
+	require.NoError(t, err)
+	assert.Len(t, *ids, 1)
+
+	reversed, err := client.Cards.Get("card:2 note:Basic*")
+	require.NoError(t, err)
+	require.Len(t, *reversed, 1)
+	assert.Equal(t, "cat", (*reversed)[0].Question)
+	assert.Equal(t, "cat<hr id=answer>猫", (*reversed)[0].Answer)
+}
+
+func TestServer_Models(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.Client()
+
+	model := ankiconnect.Model{
+		ModelName:     "Vocabulary",
+		InOrderFields: []string{"Word", "Meaning"},
+		CardTemplates: []ankiconnect.CardTemplate{{Name: "Card 1", Front: "{{Word}}", Back: "{{Meaning}}"}},
+	}
+	require.NoError(t, client.Models.Create(model))
+	assert.ErrorIs(t, client.Models.Create(model), ankiconnect.ErrModelExists)
+
+	models, err := client.Models.GetAll()
+	require.NoError(t, err)
+	assert.Contains(t, *models, "Vocabulary")
+
+	fields, err := client.Models.GetFields("Vocabulary")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Word", "Meaning"}, *fields)
+
+	_, err = client.Models.GetFields("Unknown")
+	assert.ErrorIs(t, err, ankiconnect.ErrModelNotFound)
+
+	field, err := client.Models.GetSortField("Vocabulary")
+	require.NoError(t, err)
+	assert.Equal(t, "Word", *field)
+	require.NoError(t, server.SetSortField("Vocabulary", "Meaning"))
+	field, err = client.Models.GetSortField("Vocabulary")
+	require.NoError(t, err)
+	assert.Equal(t, "Meaning", *field)
+	assert.Error(t, server.SetSortField("Vocabulary", "Unknown"))
+
+	_, err = client.Models.GetSortField("Unknown")
+	assert.ErrorIs(t, err, ankiconnect.ErrModelNotFound)
+}
+
+func TestServer_Media(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.Client()
+
+	data := base64.StdEncoding.EncodeToString([]byte("hello"))
+	name, err := client.Media.StoreMediaFile("hello.txt", data)
+	require.NoError(t, err)
+	assert.Equal(t, "hello.txt", *name)
+
+	content, ok := server.Media("hello.txt")
+	assert.True(t, ok)
+	assert.Equal(t, "hello", string(content))
+
+	retrieved, err := client.Media.RetrieveMediaFile("hello.txt")
+	require.NoError(t, err)
+	assert.Equal(t, data, *retrieved)
+
+	names, err := client.Media.GetMediaFileNames("*.txt")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hello.txt"}, *names)
+
+	_, err = client.Media.DeleteMediaFile("hello.txt")
+	require.NoError(t, err)
+	_, ok = server.Media("hello.txt")
+	assert.False(t, ok)
+}
+
+func TestServer_Client(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.Client()
+
+	t.Run("ping and connect", func(t *testing.T) {
+		assert.NoError(t, client.Ping())
+		capabilities, err := client.Connect()
+		require.NoError(t, err)
+		assert.Equal(t, Version, capabilities.Version)
+		assert.True(t, capabilities.Supports(ankiconnect.ActionAddNote))
+		assert.False(t, capabilities.Supports("guiBrowse"))
+	})
+
+	t.Run("api key", func(t *testing.T) {
+		server.SetAPIKey("secret")
+		defer server.SetAPIKey("")
+
+		_, err := client.Decks.GetAll()
+		assert.ErrorIs(t, err, ankiconnect.ErrPermissionDenied)
+
+		permission, err := client.RequestPermission()
+		require.NoError(t, err)
+		assert.True(t, permission.RequireApiKey)
+
+		_, err = client.SetAPIKey("secret").Decks.GetAll()
+		assert.NoError(t, err)
+	})
+
+	t.Run("multi", func(t *testing.T) {
+		// a client that did not connect does not know about unsupported actions
+		results, err := server.Client().Multi(
+			ankiconnect.MultiAction{Action: ankiconnect.ActionDeckNames},
+			ankiconnect.MultiAction{Action: "unknown"},
+		)
+		require.NoError(t, err)
+		assert.JSONEq(t, `["Default"]`, string((*results)[0].Result))
+		assert.Equal(t, "unsupported action", (*results)[1].Error)
+	})
+
+	t.Run("fail action", func(t *testing.T) {
+		server.FailAction(ankiconnect.ActionSync, "sync: auth not configured")
+		defer server.FailAction(ankiconnect.ActionSync, "")
+
+		assert.ErrorIs(t, client.Sync.Trigger(), ankiconnect.ErrSyncLoginRequired)
+		assert.Contains(t, server.Actions(), ankiconnect.ActionSync)
+	})
+}
+
+func TestTokenize(t *testing.T) {
+	tokens, err := tokenize(`deck:"My Deck" -(tag:a or "front:b c")`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"deck:My Deck", "-", "(", "tag:a", "or", "front:b c", ")"}, tokens)
+
+	_, err = tokenize(`deck:"My Deck`)
+	assert.Error(t, err)
+}
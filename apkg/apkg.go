@@ -0,0 +1,432 @@
+// Package apkg writes and reads Anki packages (.apkg and .colpkg files) without a running Anki.
+//
+// A package is built from the same Model and Note values that are sent to ankiconnect, so the
+// definitions used to update a collection through the Client can also be published as a shared deck:
+//
+//	pkg := apkg.New()
+//	err := pkg.AddModel(vocabModel)
+//	err = pkg.AddNote(ankiconnect.Note{
+//		DeckName:  "Japanese::Verbs",
+//		ModelName: vocabModel.ModelName,
+//		Fields:    ankiconnect.Fields{"Front": "taberu", "Back": "to eat"},
+//		Tags:      []string{"verb"},
+//	})
+//	err = pkg.WriteFile("japanese.apkg")
+//
+// The ids of the models and decks and the guids of the notes are derived from their names and the
+// first field of the notes, so that importing a new version of a package updates the notes that
+// were imported before instead of duplicating them.
+//
+// Open reads the decks, models, notes, cards and media of a package, and Diff compares its notes
+// with the notes of a collection:
+//
+//	col, err := apkg.Open("japanese.apkg")
+//	defer col.Close()
+//	infos, err := client.Notes.Get("deck:Japanese")
+//	for _, d := range apkg.Diff(*infos, col.NotesInfo()) {
+//		fmt.Println(d.Kind, d.Model, d.Key, d.Fields)
+//	}
+package apkg
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atselvan/ankiconnect"
+	"github.com/atselvan/ankiconnect/internal/htmltext"
+)
+
+const (
+	// DefaultDeck is the deck of the notes without deck name.
+	DefaultDeck = "Default"
+
+	noModelNameErrMsg   = "apkg: the model has no name"
+	noFieldsErrMsg      = "apkg: model %s has no fields"
+	noTemplatesErrMsg   = "apkg: model %s has no card templates"
+	duplicateModelMsg   = "apkg: model %s was already added"
+	unknownModelErrMsg  = "apkg: model %s was not added to the package"
+	unknownFieldErrMsg  = "apkg: model %s has no field %s"
+	noCardsErrMsg       = "apkg: the note of model %s has no cards, its fields are empty"
+	emptyDeckErrMsg     = "apkg: empty deck name"
+	mediaNameErrMsg     = "apkg: invalid media file name %q"
+	remoteMediaErrMsg   = "apkg: media file %s has a url, only local files and data are supported"
+	missingMediaErrMsg  = "apkg: media file without filename"
+	conflictMediaErrMsg = "apkg: media file %s was already added with a different content"
+	duplicateNoteErrMsg = "apkg: a note of model %s with the first field %q was already added: %w"
+)
+
+var (
+	templateRefPattern = regexp.MustCompile(`{{\s*([#^/]?)\s*([^}]+?)\s*}}`)
+	clozeNumberPattern = regexp.MustCompile(`{{c(\d+)::`)
+)
+
+type (
+	// Package is an Anki package that is built in memory and written with WriteTo or WriteFile.
+	// A Package is not safe for concurrent use.
+	Package struct {
+		// Time is the creation and modification time written to the package, the time New was
+		// called by default. The note and card ids are derived from it.
+		Time time.Time
+
+		models     map[string]*model
+		modelOrder []string
+		decks      map[string]int64
+		deckOrder  []string
+		notes      []*note
+		guids      map[string]bool
+		media      map[string][]byte
+		mediaOrder []string
+	}
+
+	model struct {
+		id     int64
+		def    ankiconnect.Model
+		fields map[string]int
+		// templateFields are the indexes of the fields referenced by the front of each template.
+		templateFields [][]int
+	}
+
+	note struct {
+		guid   string
+		model  *model
+		deck   int64
+		fields []string
+		tags   []string
+		ords   []int
+	}
+)
+
+// New returns an empty package.
+func New() *Package {
+	return &Package{
+		Time:   time.Now(),
+		models: map[string]*model{},
+		decks:  map[string]int64{},
+		guids:  map[string]bool{},
+		media:  map[string][]byte{},
+	}
+}
+
+// BasicModel returns the definition of the Basic model of Anki.
+func BasicModel() ankiconnect.Model {
+	return ankiconnect.Model{
+		ModelName:     "Basic",
+		InOrderFields: []string{"Front", "Back"},
+		CardTemplates: []ankiconnect.CardTemplate{
+			{Name: "Card 1", Front: "{{Front}}", Back: "{{FrontSide}}\n\n<hr id=answer>\n\n{{Back}}"},
+		},
+	}
+}
+
+// ClozeModel returns the definition of the Cloze model of Anki.
+func ClozeModel() ankiconnect.Model {
+	return ankiconnect.Model{
+		ModelName:     "Cloze",
+		InOrderFields: []string{"Text", "Back Extra"},
+		IsCloze:       true,
+		CardTemplates: []ankiconnect.CardTemplate{
+			{Name: "Cloze", Front: "{{cloze:Text}}", Back: "{{cloze:Text}}<br>\n{{Back Extra}}"},
+		},
+	}
+}
+
+// AddModel adds a model to the package. The model is written to the package even if it has no notes.
+// The method returns an error if:
+//   - the model has no name, no fields or no card templates.
+//   - a model with the same name was already added.
+func (p *Package) AddModel(m ankiconnect.Model) error {
+	switch {
+	case m.ModelName == "":
+		return errors.New(noModelNameErrMsg)
+	case len(m.InOrderFields) == 0:
+		return fmt.Errorf(noFieldsErrMsg, m.ModelName)
+	case len(m.CardTemplates) == 0:
+		return fmt.Errorf(noTemplatesErrMsg, m.ModelName)
+	}
+	if _, ok := p.models[m.ModelName]; ok {
+		return fmt.Errorf(duplicateModelMsg, m.ModelName)
+	}
+	md := &model{
+		id:     stableID("model", m.ModelName),
+		def:    m,
+		fields: map[string]int{},
+	}
+	for i, f := range m.InOrderFields {
+		md.fields[f] = i
+	}
+	for _, t := range m.CardTemplates {
+		md.templateFields = append(md.templateFields, md.referencedFields(t.Front))
+	}
+	p.models[m.ModelName] = md
+	p.modelOrder = append(p.modelOrder, m.ModelName)
+	return nil
+}
+
+// AddDeck adds a deck and its parent decks to the package. The deck is written to the package even
+// if it has no cards, the decks of the notes are added by AddNote.
+// The method returns an error if the name or the name of a parent is empty.
+func (p *Package) AddDeck(name string) error {
+	parts := strings.Split(name, "::")
+	for i := range parts {
+		if strings.TrimSpace(parts[i]) == "" {
+			return errors.New(emptyDeckErrMsg)
+		}
+	}
+	for i := range parts {
+		deck := strings.Join(parts[:i+1], "::")
+		if _, ok := p.decks[deck]; ok {
+			continue
+		}
+		id := int64(defaultDeckID)
+		if deck != DefaultDeck {
+			id = stableID("deck", deck)
+		}
+		p.decks[deck] = id
+		p.deckOrder = append(p.deckOrder, deck)
+	}
+	return nil
+}
+
+// AddMedia adds a media file with the content to the package. Adding the same file twice is allowed.
+// The method returns an error if:
+//   - the file name is empty or contains a path separator.
+//   - a file with the same name and a different content was already added.
+func (p *Package) AddMedia(filename string, content []byte) error {
+	if filename == "" || strings.ContainsAny(filename, `/\`) || filename == "." || filename == ".." {
+		return fmt.Errorf(mediaNameErrMsg, filename)
+	}
+	if existing, ok := p.media[filename]; ok {
+		if string(existing) != string(content) {
+			return fmt.Errorf(conflictMediaErrMsg, filename)
+		}
+		return nil
+	}
+	p.media[filename] = content
+	p.mediaOrder = append(p.mediaOrder, filename)
+	return nil
+}
+
+// AddMediaFile adds the file at path to the package and returns its name in the media folder,
+// which is the base name of the path.
+// The method returns an error if the file cannot be read or AddMedia fails.
+func (p *Package) AddMediaFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	name := filepath.Base(path)
+	return name, p.AddMedia(name, content)
+}
+
+// AddNote adds a note and its cards to the package. The note is added to the deck DefaultDeck if it
+// has no deck name. The Audio, Video and Picture files of the note are added to the media of the
+// package and referenced in their fields, like ankiconnect does; they must have a Path or Data.
+// The cards are created for the templates whose front references a non empty field, or for the
+// cloze numbers of a cloze note. The Options of the note are ignored.
+// The method returns an error if:
+//   - the model of the note was not added.
+//   - the note has a field that is not a field of the model.
+//   - a note of the same model with the same first field was already added, the error wraps
+//     ankiconnect.ErrDuplicateNote. Both notes would get the same guid and Anki would import only one.
+//   - the note has no cards.
+//   - a media file cannot be added.
+func (p *Package) AddNote(n ankiconnect.Note) error {
+	m, ok := p.models[n.ModelName]
+	if !ok {
+		return fmt.Errorf(unknownModelErrMsg, n.ModelName)
+	}
+	fields := make([]string, len(m.def.InOrderFields))
+	for name, value := range n.Fields {
+		i, ok := m.fields[name]
+		if !ok {
+			return fmt.Errorf(unknownFieldErrMsg, n.ModelName, name)
+		}
+		fields[i] = value
+	}
+	// the guid is derived from the first field before media references are appended to it
+	id := guid(m.id, htmltext.StripPreservingMedia(fields[0]))
+	if p.guids[id] {
+		return fmt.Errorf(duplicateNoteErrMsg, n.ModelName, fields[0], ankiconnect.ErrDuplicateNote)
+	}
+	if err := p.attachMedia(m, fields, n); err != nil {
+		return err
+	}
+
+	ords := m.cards(fields)
+	if len(ords) == 0 {
+		return fmt.Errorf(noCardsErrMsg, n.ModelName)
+	}
+	deck := n.DeckName
+	if deck == "" {
+		deck = DefaultDeck
+	}
+	if err := p.AddDeck(deck); err != nil {
+		return err
+	}
+	p.guids[id] = true
+	p.notes = append(p.notes, &note{
+		guid:   id,
+		model:  m,
+		deck:   p.decks[deck],
+		fields: fields,
+		tags:   n.Tags,
+		ords:   ords,
+	})
+	return nil
+}
+
+// attachMedia adds the media files of the note and appends their references to the fields.
+func (p *Package) attachMedia(m *model, fields []string, n ankiconnect.Note) error {
+	type media struct {
+		url, data, path, filename string
+		fields                    []string
+		format                    string
+	}
+	var files []media
+	for _, a := range n.Audio {
+		files = append(files, media{a.URL, a.Data, a.Path, a.Filename, a.Fields, "[sound:%s]"})
+	}
+	for _, v := range n.Video {
+		files = append(files, media{v.URL, v.Data, v.Path, v.Filename, v.Fields, "[sound:%s]"})
+	}
+	for _, pic := range n.Picture {
+		files = append(files, media{pic.URL, pic.Data, pic.Path, pic.Filename, pic.Fields, `<img src="%s">`})
+	}
+	for _, f := range files {
+		var content []byte
+		var err error
+		switch {
+		case f.data != "":
+			content, err = base64.StdEncoding.DecodeString(f.data)
+		case f.path != "":
+			content, err = os.ReadFile(f.path)
+		case f.url != "":
+			return fmt.Errorf(remoteMediaErrMsg, f.filename)
+		}
+		if err != nil {
+			return err
+		}
+		name := f.filename
+		if name == "" && f.path != "" {
+			name = filepath.Base(f.path)
+		}
+		if name == "" {
+			return errors.New(missingMediaErrMsg)
+		}
+		if err := p.AddMedia(name, content); err != nil {
+			return err
+		}
+		for _, field := range f.fields {
+			i, ok := m.fields[field]
+			if !ok {
+				return fmt.Errorf(unknownFieldErrMsg, m.def.ModelName, field)
+			}
+			fields[i] += fmt.Sprintf(f.format, name)
+		}
+	}
+	return nil
+}
+
+// referencedFields returns the indexes of the fields of the model referenced by a template.
+func (m *model) referencedFields(tmpl string) []int {
+	seen := map[int]bool{}
+	indexes := []int{}
+	for _, ref := range templateRefPattern.FindAllStringSubmatch(tmpl, -1) {
+		name := ref[2]
+		if ref[1] == "" {
+			// remove the filters, e.g. {{text:Front}} or {{cloze:Text}}
+			name = name[strings.LastIndex(name, ":")+1:]
+		}
+		if i, ok := m.fields[strings.TrimSpace(name)]; ok && !seen[i] {
+			seen[i] = true
+			indexes = append(indexes, i)
+		}
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// cards returns the ordinals of the cards of a note with the fields.
+func (m *model) cards(fields []string) []int {
+	var ords []int
+	if m.def.IsCloze {
+		seen := map[int]bool{}
+		for _, i := range m.templateFields[0] {
+			for _, match := range clozeNumberPattern.FindAllStringSubmatch(fields[i], -1) {
+				n, _ := strconv.Atoi(match[1])
+				if n > 0 && !seen[n-1] {
+					seen[n-1] = true
+					ords = append(ords, n-1)
+				}
+			}
+		}
+		sort.Ints(ords)
+		return ords
+	}
+	for ord, t := range m.def.CardTemplates {
+		if m.nonEmpty(t.Front, fields) {
+			ords = append(ords, ord)
+		}
+	}
+	return ords
+}
+
+// nonEmpty reports whether the template renders a non empty field with the fields. The conditional
+// sections {{#Field}} and {{^Field}} are evaluated, filters are ignored.
+func (m *model) nonEmpty(tmpl string, fields []string) bool {
+	value := func(name string) string {
+		if i, ok := m.fields[strings.TrimSpace(name)]; ok {
+			return strings.TrimSpace(htmltext.StripPreservingMedia(fields[i]))
+		}
+		return ""
+	}
+	// hidden counts the enclosing sections whose condition is false
+	hidden := 0
+	var sections []bool
+	for _, ref := range templateRefPattern.FindAllStringSubmatch(tmpl, -1) {
+		switch ref[1] {
+		case "#", "^":
+			show := (value(ref[2]) != "") == (ref[1] == "#")
+			sections = append(sections, show)
+			if !show {
+				hidden++
+			}
+		case "/":
+			if len(sections) > 0 {
+				if !sections[len(sections)-1] {
+					hidden--
+				}
+				sections = sections[:len(sections)-1]
+			}
+		default:
+			name := ref[2][strings.LastIndex(ref[2], ":")+1:]
+			if hidden == 0 && value(name) != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stableID derives a positive id from the kind and the name of an object.
+// The ids are below 2^52 so that they are exact in json parsers using doubles.
+func stableID(kind, name string) int64 {
+	sum := sha256.Sum256([]byte(kind + "\x00" + name))
+	return int64(binary.BigEndian.Uint64(sum[:8])>>12) + 1<<40
+}
+
+// guid returns the guid of a note derived from its model and its first field.
+func guid(modelID int64, sortField string) string {
+	sum := sha256.Sum256([]byte(strconv.FormatInt(modelID, 10) + "\x1f" + sortField))
+	return base64.RawStdEncoding.EncodeToString(sum[:9])
+}
@@ -0,0 +1,206 @@
+package apkg
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/atselvan/ankiconnect"
+	"github.com/stretchr/testify/assert"
+)
+
+// openPackage extracts the collection of the package and returns the database and the zip entries.
+func openPackage(t *testing.T, content []byte) (*sql.DB, map[string][]byte) {
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	entries := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		assert.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		assert.NoError(t, err)
+		rc.Close()
+		entries[f.Name] = data
+	}
+	path := filepath.Join(t.TempDir(), collectionFile)
+	assert.NoError(t, os.WriteFile(path, entries[collectionFile], 0o600))
+	db, err := sql.Open("sqlite", path)
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db, entries
+}
+
+func TestPackage(t *testing.T) {
+	pkg := New()
+	pkg.Time = time.Unix(1700000000, 0)
+	assert.NoError(t, pkg.AddModel(BasicModel()))
+	assert.NoError(t, pkg.AddModel(ClozeModel()))
+	assert.NoError(t, pkg.AddModel(ankiconnect.Model{
+		ModelName:     "Reversible",
+		InOrderFields: []string{"Front", "Back", "Add Reverse"},
+		CardTemplates: []ankiconnect.CardTemplate{
+			{Name: "Card 1", Front: "{{Front}}", Back: "{{Back}}"},
+			{Name: "Card 2", Front: "{{#Add Reverse}}{{Back}}{{/Add Reverse}}", Back: "{{Front}}"},
+		},
+	}))
+	assert.NoError(t, pkg.AddDeck("Empty"))
+
+	assert.NoError(t, pkg.AddNote(ankiconnect.Note{
+		DeckName:  "Japanese::Verbs",
+		ModelName: "Basic",
+		Fields:    ankiconnect.Fields{"Front": "<b>taberu</b>", "Back": "to eat"},
+		Tags:      []string{"verb", "jlpt5"},
+		Picture: []ankiconnect.Picture{{
+			Filename: "taberu.png",
+			Data:     base64.StdEncoding.EncodeToString([]byte("png")),
+			Fields:   []string{"Back"},
+		}},
+	}))
+	assert.NoError(t, pkg.AddNote(ankiconnect.Note{
+		ModelName: "Cloze",
+		Fields:    ankiconnect.Fields{"Text": "{{c1::Tokyo}} is the capital of {{c2::Japan}}, {{c1::Tokyo}}"},
+	}))
+	assert.NoError(t, pkg.AddNote(ankiconnect.Note{
+		DeckName:  "Japanese",
+		ModelName: "Reversible",
+		Fields:    ankiconnect.Fields{"Front": "nomu", "Back": "to drink"},
+	}))
+	assert.NoError(t, pkg.AddMedia("taberu.png", []byte("png")))
+
+	var buf bytes.Buffer
+	n, err := pkg.WriteTo(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+	db, entries := openPackage(t, buf.Bytes())
+
+	var manifest map[string]string
+	assert.NoError(t, json.Unmarshal(entries[mediaFile], &manifest))
+	assert.Equal(t, map[string]string{"0": "taberu.png"}, manifest)
+	assert.Equal(t, "png", string(entries["0"]))
+
+	var modelsJSON, decksJSON string
+	var ver int
+	assert.NoError(t, db.QueryRow(`SELECT ver, models, decks FROM col`).Scan(&ver, &modelsJSON, &decksJSON))
+	assert.Equal(t, schemaVersion, ver)
+	var models map[string]modelJSON
+	assert.NoError(t, json.Unmarshal([]byte(modelsJSON), &models))
+	assert.Len(t, models, 3)
+	basicID := stableID("model", "Basic")
+	assert.Equal(t, "Basic", models[itoa(basicID)].Name)
+	assert.Equal(t, modelTypeCloze, models[itoa(stableID("model", "Cloze"))].Type)
+	var decks map[string]deckJSON
+	assert.NoError(t, json.Unmarshal([]byte(decksJSON), &decks))
+	names := []string{}
+	for _, d := range decks {
+		names = append(names, d.Name)
+	}
+	assert.ElementsMatch(t, []string{"Default", "Empty", "Japanese", "Japanese::Verbs"}, names)
+
+	rows, err := db.Query(`SELECT n.mid, n.tags, n.flds, n.sfld, c.ord, c.did, c.due FROM notes n JOIN cards c ON c.nid = n.id ORDER BY c.id`)
+	assert.NoError(t, err)
+	defer rows.Close()
+	type card struct {
+		mid           int64
+		tags, flds    string
+		sfld          string
+		ord, did, due int64
+	}
+	var cards []card
+	for rows.Next() {
+		var c card
+		assert.NoError(t, rows.Scan(&c.mid, &c.tags, &c.flds, &c.sfld, &c.ord, &c.did, &c.due))
+		cards = append(cards, c)
+	}
+	assert.NoError(t, rows.Err())
+	if assert.Len(t, cards, 4) {
+		assert.Equal(t, card{basicID, " verb jlpt5 ", "<b>taberu</b>\x1fto eat<img src=\"taberu.png\">", "taberu", 0, stableID("deck", "Japanese::Verbs"), 1}, cards[0])
+		assert.Equal(t, []int64{0, 1}, []int64{cards[1].ord, cards[2].ord})
+		assert.Equal(t, int64(defaultDeckID), cards[1].did)
+		// the reverse card is not generated, the Add Reverse field is empty
+		assert.Equal(t, int64(0), cards[3].ord)
+		assert.Equal(t, int64(3), cards[3].due)
+	}
+}
+
+func TestPackageErrors(t *testing.T) {
+	pkg := New()
+	assert.EqualError(t, pkg.AddModel(ankiconnect.Model{}), noModelNameErrMsg)
+	assert.EqualError(t, pkg.AddModel(ankiconnect.Model{ModelName: "X"}), "apkg: model X has no fields")
+	assert.EqualError(t, pkg.AddModel(ankiconnect.Model{ModelName: "X", InOrderFields: []string{"A"}}), "apkg: model X has no card templates")
+	assert.NoError(t, pkg.AddModel(BasicModel()))
+	assert.EqualError(t, pkg.AddModel(BasicModel()), "apkg: model Basic was already added")
+
+	assert.EqualError(t, pkg.AddNote(ankiconnect.Note{ModelName: "Cloze"}), "apkg: model Cloze was not added to the package")
+	assert.EqualError(t, pkg.AddNote(ankiconnect.Note{ModelName: "Basic", Fields: ankiconnect.Fields{"Word": "x"}}), "apkg: model Basic has no field Word")
+	assert.EqualError(t, pkg.AddNote(ankiconnect.Note{ModelName: "Basic", Fields: ankiconnect.Fields{"Back": "x"}}), "apkg: the note of model Basic has no cards, its fields are empty")
+	assert.EqualError(t, pkg.AddNote(ankiconnect.Note{
+		ModelName: "Basic",
+		Fields:    ankiconnect.Fields{"Front": "x"},
+		Audio:     []ankiconnect.Audio{{URL: "https://example.com/x.mp3", Filename: "x.mp3"}},
+	}), "apkg: media file x.mp3 has a url, only local files and data are supported")
+	assert.NoError(t, pkg.AddNote(ankiconnect.Note{ModelName: "Basic", Fields: ankiconnect.Fields{"Front": "<b>taberu</b>", "Back": "to eat"}}))
+	err := pkg.AddNote(ankiconnect.Note{ModelName: "Basic", Fields: ankiconnect.Fields{"Front": "taberu", "Back": "to eat (v)"}})
+	assert.ErrorIs(t, err, ankiconnect.ErrDuplicateNote)
+	assert.EqualError(t, err, `apkg: a note of model Basic with the first field "taberu" was already added: duplicate note`)
+	assert.Len(t, pkg.notes, 1)
+	assert.EqualError(t, pkg.AddDeck("A::::B"), emptyDeckErrMsg)
+	assert.EqualError(t, pkg.AddMedia("../x.png", nil), `apkg: invalid media file name "../x.png"`)
+	assert.NoError(t, pkg.AddMedia("x.png", []byte("a")))
+	assert.EqualError(t, pkg.AddMedia("x.png", []byte("b")), "apkg: media file x.png was already added with a different content")
+}
+
+func TestStableIDs(t *testing.T) {
+	assert.Equal(t, stableID("deck", "Japanese"), stableID("deck", "Japanese"))
+	assert.NotEqual(t, stableID("deck", "Japanese"), stableID("model", "Japanese"))
+	assert.Less(t, stableID("deck", "Japanese"), int64(1)<<53)
+	assert.Equal(t, guid(1, "taberu"), guid(1, "taberu"))
+	assert.NotEqual(t, guid(1, "taberu"), guid(2, "taberu"))
+}
+
+func TestWriteFile(t *testing.T) {
+	pkg := New()
+	assert.NoError(t, pkg.AddModel(BasicModel()))
+	assert.NoError(t, pkg.AddNote(ankiconnect.Note{ModelName: "Basic", Fields: ankiconnect.Fields{"Front": "a"}}))
+	path := filepath.Join(t.TempDir(), "deck.apkg")
+	assert.NoError(t, pkg.WriteFile(path))
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	db, _ := openPackage(t, content)
+	var count int
+	assert.NoError(t, db.QueryRow(`SELECT count(*) FROM cards`).Scan(&count))
+	assert.Equal(t, 1, count)
+}
+
+func TestChecksum(t *testing.T) {
+	pkg := New()
+	assert.NoError(t, pkg.AddModel(BasicModel()))
+	assert.NoError(t, pkg.AddNote(ankiconnect.Note{ModelName: "Basic", Fields: ankiconnect.Fields{"Front": "<b>Tom</b> &amp; Jerry"}}))
+	// Anki unescapes the entities of the sort field, so both notes get the same guid
+	err := pkg.AddNote(ankiconnect.Note{ModelName: "Basic", Fields: ankiconnect.Fields{"Front": "Tom & Jerry"}})
+	assert.ErrorIs(t, err, ankiconnect.ErrDuplicateNote)
+
+	var buf bytes.Buffer
+	_, err = pkg.WriteTo(&buf)
+	assert.NoError(t, err)
+	db, _ := openPackage(t, buf.Bytes())
+	var sfld string
+	var csum int64
+	assert.NoError(t, db.QueryRow(`SELECT sfld, csum FROM notes`).Scan(&sfld, &csum))
+	assert.Equal(t, "Tom & Jerry", sfld)
+	// the first 8 hex digits of the sha1 of "Tom & Jerry"
+	assert.Equal(t, int64(495331847), csum)
+}
+
+func itoa(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
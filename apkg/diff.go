@@ -0,0 +1,127 @@
+package apkg
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/atselvan/ankiconnect"
+)
+
+// The kinds of differences between notes.
+const (
+	NoteAdded   ChangeKind = "added"
+	NoteRemoved ChangeKind = "removed"
+	NoteChanged ChangeKind = "changed"
+)
+
+type (
+	// ChangeKind describes how a note differs between two versions.
+	ChangeKind string
+
+	// NoteDiff is the difference of a note between two versions.
+	NoteDiff struct {
+		Kind ChangeKind `json:"kind"`
+		// Model and Key identify the note, the key is the value of its first field.
+		Model string `json:"model"`
+		Key   string `json:"key"`
+		// Old is the note of the old version, nil if the note was added.
+		Old *ankiconnect.ResultNotesInfo `json:"old,omitempty"`
+		// New is the note of the new version, nil if the note was removed.
+		New *ankiconnect.ResultNotesInfo `json:"new,omitempty"`
+		// Fields are the names of the changed fields, sorted.
+		Fields      []string `json:"fields,omitempty"`
+		AddedTags   []string `json:"addedTags,omitempty"`
+		RemovedTags []string `json:"removedTags,omitempty"`
+	}
+)
+
+// Diff compares two versions of notes, e.g. the notes of a package and the notes returned by
+// ankiconnect for the deck the package is imported into:
+//
+//	infos, err := client.Notes.Get("deck:Japanese")
+//	diffs := apkg.Diff(*infos, pkg.NotesInfo())
+//
+// The notes are matched by their model and the value of their first field, as the note ids and
+// guids are not available through ankiconnect. If several notes have the same key, the note with
+// the lowest id is used. The differences are sorted by model and key, equal notes are omitted.
+func Diff(old, new []ankiconnect.ResultNotesInfo) []NoteDiff {
+	type key struct{ model, key string }
+	index := func(notes []ankiconnect.ResultNotesInfo) map[key]*ankiconnect.ResultNotesInfo {
+		m := map[key]*ankiconnect.ResultNotesInfo{}
+		for i := range notes {
+			n := &notes[i]
+			k := key{n.ModelName, firstField(n)}
+			if existing, ok := m[k]; !ok || n.NoteId < existing.NoteId {
+				m[k] = n
+			}
+		}
+		return m
+	}
+	oldNotes, newNotes := index(old), index(new)
+
+	var diffs []NoteDiff
+	for k, o := range oldNotes {
+		if _, ok := newNotes[k]; !ok {
+			diffs = append(diffs, NoteDiff{Kind: NoteRemoved, Model: k.model, Key: k.key, Old: o})
+		}
+	}
+	for k, n := range newNotes {
+		o, ok := oldNotes[k]
+		if !ok {
+			diffs = append(diffs, NoteDiff{Kind: NoteAdded, Model: k.model, Key: k.key, New: n})
+			continue
+		}
+		d := NoteDiff{Kind: NoteChanged, Model: k.model, Key: k.key, Old: o, New: n}
+		for name, f := range n.Fields {
+			if of, ok := o.Fields[name]; !ok || of.Value != f.Value {
+				d.Fields = append(d.Fields, name)
+			}
+		}
+		for name := range o.Fields {
+			if _, ok := n.Fields[name]; !ok {
+				d.Fields = append(d.Fields, name)
+			}
+		}
+		sort.Strings(d.Fields)
+		d.AddedTags, d.RemovedTags = difference(n.Tags, o.Tags), difference(o.Tags, n.Tags)
+		if len(d.Fields) > 0 || len(d.AddedTags) > 0 || len(d.RemovedTags) > 0 {
+			diffs = append(diffs, d)
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].Model != diffs[j].Model {
+			return diffs[i].Model < diffs[j].Model
+		}
+		return diffs[i].Key < diffs[j].Key
+	})
+	return diffs
+}
+
+// firstField returns the value of the field with the lowest order.
+func firstField(n *ankiconnect.ResultNotesInfo) string {
+	var value string
+	order := int64(-1)
+	for _, f := range n.Fields {
+		if order < 0 || f.Order < order {
+			value, order = f.Value, f.Order
+		}
+	}
+	return value
+}
+
+// difference returns the tags of a that are not in b, sorted. Tags are compared case insensitively
+// like in Anki.
+func difference(a, b []string) []string {
+	in := map[string]bool{}
+	for _, t := range b {
+		in[strings.ToLower(t)] = true
+	}
+	var out []string
+	for _, t := range a {
+		if !in[strings.ToLower(t)] {
+			out = append(out, t)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,100 @@
+package apkg
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// protobuf wire types, see https://protobuf.dev/programming-guides/encoding/
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errInvalidProto = errors.New("apkg: invalid protobuf message")
+
+type (
+	// protoMessage is a decoded protobuf message of the schema 18 collection. It maps the field
+	// numbers to the values of the fields, in the order they were encoded.
+	protoMessage map[uint64][]protoValue
+
+	// protoValue is the value of a field: varints and fixed size values are stored in n,
+	// length-delimited values in b.
+	protoValue struct {
+		n uint64
+		b []byte
+	}
+)
+
+// decodeProto decodes the fields of a protobuf message without a schema.
+func decodeProto(data []byte) (protoMessage, error) {
+	m := protoMessage{}
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errInvalidProto
+		}
+		data = data[n:]
+		var v protoValue
+		switch key & 7 {
+		case wireVarint:
+			if v.n, n = binary.Uvarint(data); n <= 0 {
+				return nil, errInvalidProto
+			}
+			data = data[n:]
+		case wireFixed64:
+			if len(data) < 8 {
+				return nil, errInvalidProto
+			}
+			v.n, data = binary.LittleEndian.Uint64(data), data[8:]
+		case wireFixed32:
+			if len(data) < 4 {
+				return nil, errInvalidProto
+			}
+			v.n, data = uint64(binary.LittleEndian.Uint32(data)), data[4:]
+		case wireBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || size > uint64(len(data)-n) {
+				return nil, errInvalidProto
+			}
+			v.b, data = data[n:n+int(size)], data[n+int(size):]
+		default:
+			return nil, errInvalidProto
+		}
+		m[key>>3] = append(m[key>>3], v)
+	}
+	return m, nil
+}
+
+// uint returns the last value of the varint field, or 0 if the message has no such field.
+func (m protoMessage) uint(field uint64) uint64 {
+	if values := m[field]; len(values) > 0 {
+		return values[len(values)-1].n
+	}
+	return 0
+}
+
+// has reports whether the message has the field.
+func (m protoMessage) has(field uint64) bool {
+	return len(m[field]) > 0
+}
+
+// string returns the last value of the string field, or "" if the message has no such field.
+func (m protoMessage) string(field uint64) string {
+	if values := m[field]; len(values) > 0 {
+		return string(values[len(values)-1].b)
+	}
+	return ""
+}
+
+// message decodes the last value of the embedded message field.
+// An empty message is returned if the message has no such field.
+func (m protoMessage) message(field uint64) (protoMessage, error) {
+	values := m[field]
+	if len(values) == 0 {
+		return protoMessage{}, nil
+	}
+	return decodeProto(values[len(values)-1].b)
+}
@@ -0,0 +1,450 @@
+package apkg
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/atselvan/ankiconnect"
+)
+
+const (
+	// collectionFile21 is the name of the collection database of the packages written by Anki 2.1
+	// with support for older versions, it has the same schema as collectionFile.
+	collectionFile21 = "collection.anki21"
+	// collectionFile21b is the name of the zstd compressed collection database in the schema 18 of
+	// the packages written by Anki 2.1.50 and later. The collection.anki2 of such packages only
+	// contains a note asking to update Anki.
+	collectionFile21b = "collection.anki21b"
+
+	noCollectionErrMsg = "apkg: the package contains no collection"
+	mediaNotFoundMsg   = "apkg: the package contains no media file %s"
+)
+
+type (
+	// Collection is the content of an .apkg or .colpkg file, in the legacy format of Anki 2.1.49 and
+	// earlier or in the format of Anki 2.1.50 and later. Collections opened with Open must be closed
+	// to release the file.
+	Collection struct {
+		Decks  []Deck
+		Models []Model
+		Notes  []Note
+		Cards  []ankiconnect.ResultCardsInfo
+		// Media are the names of the media files, sorted.
+		Media []string
+
+		media  map[string]*zip.File
+		closer io.Closer
+		// compressed is set if the media files are zstd compressed.
+		compressed bool
+	}
+
+	// Deck is a deck of a collection.
+	Deck struct {
+		ID          int64  `json:"id"`
+		Name        string `json:"name"`
+		Description string `json:"description,omitempty"`
+	}
+
+	// Model is a model (note type) of a collection.
+	Model struct {
+		ID int64 `json:"id"`
+		ankiconnect.Model
+		// SortField is the index of the field that is shown in the browser and checked for duplicates.
+		SortField int `json:"sortField"`
+	}
+
+	// Note is a note of a collection, it has the fields of the notes returned by ankiconnect.
+	Note struct {
+		ankiconnect.ResultNotesInfo
+		// GUID identifies the note across collections, Anki updates the note with the same guid on import.
+		GUID string `json:"guid"`
+		Mod  int64  `json:"mod"`
+	}
+)
+
+// Open opens the package at path and reads its collection.
+// The method returns an error if:
+//   - the file is not a zip archive or cannot be read.
+//   - the package contains no collection.
+//   - the collection cannot be read.
+func Open(path string) (*Collection, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := read(&zr.Reader)
+	if err != nil {
+		zr.Close()
+		return nil, err
+	}
+	c.closer = zr
+	return c, nil
+}
+
+// Read reads the collection of the package of the size read from r.
+// See Open for the errors returned.
+func Read(r io.ReaderAt, size int64) (*Collection, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return read(zr)
+}
+
+// Close closes the file of a collection opened with Open.
+func (c *Collection) Close() error {
+	if c.closer == nil {
+		return nil
+	}
+	return c.closer.Close()
+}
+
+// MediaFile returns the content of the media file with the name.
+// The method returns an error if the package contains no such file or it cannot be read.
+func (c *Collection) MediaFile(name string) ([]byte, error) {
+	f, ok := c.media[name]
+	if !ok {
+		return nil, fmt.Errorf(mediaNotFoundMsg, name)
+	}
+	rc, err := c.open(f)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// open opens the zip entry of a media file or the media manifest, decompressing it if required.
+func (c *Collection) open(f *zip.File) (io.ReadCloser, error) {
+	rc, err := f.Open()
+	if err != nil || !c.compressed {
+		return rc, err
+	}
+	zr, err := zstdReader(rc)
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	return readCloser{zr, rc}, nil
+}
+
+// readCloser reads from a decompressing reader and closes it and the underlying reader.
+type readCloser struct {
+	io.ReadCloser
+	underlying io.Closer
+}
+
+func (rc readCloser) Close() error {
+	rc.ReadCloser.Close()
+	return rc.underlying.Close()
+}
+
+// NotesInfo returns the notes as returned by ankiconnect, e.g. to compare them with Diff.
+func (c *Collection) NotesInfo() []ankiconnect.ResultNotesInfo {
+	infos := make([]ankiconnect.ResultNotesInfo, len(c.Notes))
+	for i, n := range c.Notes {
+		infos[i] = n.ResultNotesInfo
+	}
+	return infos
+}
+
+func read(zr *zip.Reader) (*Collection, error) {
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	version, err := packageVersion(files)
+	if err != nil {
+		return nil, err
+	}
+	var db *zip.File
+	switch {
+	case version >= packageVersionLatest, version == 0 && files[collectionFile21b] != nil:
+		db = files[collectionFile21b]
+	case files[collectionFile21] != nil:
+		db = files[collectionFile21]
+	default:
+		db = files[collectionFile]
+	}
+	if db == nil {
+		return nil, errors.New(noCollectionErrMsg)
+	}
+
+	c := &Collection{media: map[string]*zip.File{}, compressed: db.Name == collectionFile21b}
+	if err := c.readMedia(files); err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "apkg")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, collectionFile)
+	if err := c.extract(db, path); err != nil {
+		return nil, err
+	}
+	if err := c.readCollection(path); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// packageVersion returns the version of the PackageMetadata of the package, or 0 if the package
+// has no metadata, as packages written before Anki 2.1.50.
+func packageVersion(files map[string]*zip.File) (uint64, error) {
+	f, ok := files[metaFile]
+	if !ok {
+		return 0, nil
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return 0, err
+	}
+	meta, err := decodeProto(data)
+	if err != nil {
+		return 0, fmt.Errorf("apkg: invalid package metadata: %w", err)
+	}
+	return meta.uint(packageMetadataVersion), nil
+}
+
+// readMedia reads the manifest mapping the zip entries to the media file names. The manifest is a
+// json object in the legacy format and a zstd compressed protobuf MediaEntries message otherwise,
+// in which the zip entries are named after the index of the media file.
+func (c *Collection) readMedia(files map[string]*zip.File) error {
+	f, ok := files[mediaFile]
+	if !ok {
+		return nil
+	}
+	rc, err := c.open(f)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	manifest := map[string]string{}
+	if c.compressed {
+		data, err := io.ReadAll(rc)
+		if err != nil {
+			return err
+		}
+		entries, err := decodeProto(data)
+		if err != nil {
+			return fmt.Errorf("apkg: invalid media manifest: %w", err)
+		}
+		for i, v := range entries[mediaEntriesEntries] {
+			entry, err := decodeProto(v.b)
+			if err != nil {
+				return fmt.Errorf("apkg: invalid media manifest: %w", err)
+			}
+			index := uint64(i)
+			if entry.has(mediaEntryLegacyZipName) {
+				index = entry.uint(mediaEntryLegacyZipName)
+			}
+			manifest[strconv.FormatUint(index, 10)] = entry.string(mediaEntryName)
+		}
+	} else if err := json.NewDecoder(rc).Decode(&manifest); err != nil {
+		return fmt.Errorf("apkg: invalid media manifest: %w", err)
+	}
+	for entry, name := range manifest {
+		if zf, ok := files[entry]; ok {
+			c.media[name] = zf
+			c.Media = append(c.Media, name)
+		}
+	}
+	sort.Strings(c.Media)
+	return nil
+}
+
+// extract writes the collection database to path, decompressing it if required.
+func (c *Collection) extract(f *zip.File, path string) error {
+	rc, err := c.open(f)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, rc); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// readCollection reads the decks, models, notes and cards of the collection database at path.
+func (c *Collection) readCollection(path string) error {
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	var ver int
+	if err := db.QueryRowContext(ctx, `SELECT ver FROM col`).Scan(&ver); err != nil {
+		return fmt.Errorf("apkg: invalid collection: %w", err)
+	}
+	readSchema := readSchema11
+	if ver >= schemaNotetypes {
+		readSchema = readSchema18
+	}
+	models, decks, err := readSchema(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	byID := map[int64]*modelJSON{}
+	for _, m := range models {
+		byID[m.ID] = &m
+		c.Models = append(c.Models, m.model())
+	}
+	sort.Slice(c.Models, func(i, j int) bool { return c.Models[i].ModelName < c.Models[j].ModelName })
+	deckNames := map[int64]string{}
+	for _, d := range decks {
+		deckNames[d.ID] = d.Name
+		c.Decks = append(c.Decks, Deck{ID: d.ID, Name: d.Name, Description: d.Desc})
+	}
+	sort.Slice(c.Decks, func(i, j int) bool { return c.Decks[i].Name < c.Decks[j].Name })
+
+	if err := c.readNotes(ctx, db, byID); err != nil {
+		return err
+	}
+	return c.readCards(ctx, db, byID, deckNames)
+}
+
+// readSchema11 reads the models and decks of a collection in the schema 11 from the json columns
+// of the col table.
+func readSchema11(ctx context.Context, db *sql.DB) ([]modelJSON, []deckJSON, error) {
+	var modelsJSON, decksJSON string
+	if err := db.QueryRowContext(ctx, `SELECT models, decks FROM col`).Scan(&modelsJSON, &decksJSON); err != nil {
+		return nil, nil, fmt.Errorf("apkg: invalid collection: %w", err)
+	}
+	models := map[string]modelJSON{}
+	if err := json.Unmarshal([]byte(modelsJSON), &models); err != nil {
+		return nil, nil, fmt.Errorf("apkg: invalid models: %w", err)
+	}
+	decks := map[string]deckJSON{}
+	if err := json.Unmarshal([]byte(decksJSON), &decks); err != nil {
+		return nil, nil, fmt.Errorf("apkg: invalid decks: %w", err)
+	}
+	var ms []modelJSON
+	for _, m := range models {
+		ms = append(ms, m)
+	}
+	var ds []deckJSON
+	for _, d := range decks {
+		ds = append(ds, d)
+	}
+	return ms, ds, nil
+}
+
+func (c *Collection) readNotes(ctx context.Context, db *sql.DB, models map[int64]*modelJSON) error {
+	rows, err := db.QueryContext(ctx, `SELECT id, guid, mid, mod, tags, flds FROM notes ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var n Note
+		var mid int64
+		var tags, flds string
+		if err := rows.Scan(&n.NoteId, &n.GUID, &mid, &n.Mod, &tags, &flds); err != nil {
+			return err
+		}
+		m, ok := models[mid]
+		if !ok {
+			return fmt.Errorf("apkg: note %d has the unknown model %d", n.NoteId, mid)
+		}
+		n.ModelName = m.Name
+		n.Fields = m.fieldData(flds)
+		n.Tags = strings.Fields(tags)
+		c.Notes = append(c.Notes, n)
+	}
+	return rows.Err()
+}
+
+func (c *Collection) readCards(ctx context.Context, db *sql.DB, models map[int64]*modelJSON, decks map[int64]string) error {
+	rows, err := db.QueryContext(ctx, `
+		SELECT c.id, c.nid, c.did, c.ord, c.mod, c.type, c.queue, c.due, c.ivl, c.reps, c.lapses, c.left, n.mid, n.flds
+		FROM cards c JOIN notes n ON n.id = c.nid
+		ORDER BY c.id`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var card ankiconnect.ResultCardsInfo
+		var did, mid int64
+		var flds string
+		err := rows.Scan(&card.CardId, &card.Note, &did, &card.Ord, &card.Mod, &card.Type, &card.Queue,
+			&card.Due, &card.Interval, &card.Reps, &card.Lapses, &card.Left, &mid, &flds)
+		if err != nil {
+			return err
+		}
+		if m, ok := models[mid]; ok {
+			card.ModelName, card.Css, card.FieldOrder = m.Name, m.Css, int64(m.Sortf)
+			card.Fields = m.fieldData(flds)
+		}
+		card.DeckName = decks[did]
+		c.Cards = append(c.Cards, card)
+	}
+	return rows.Err()
+}
+
+// model converts the stored model to a Model.
+func (m *modelJSON) model() Model {
+	model := Model{
+		ID: m.ID,
+		Model: ankiconnect.Model{
+			ModelName: m.Name,
+			Css:       m.Css,
+			IsCloze:   m.Type == modelTypeCloze,
+		},
+		SortField: m.Sortf,
+	}
+	for _, f := range m.sortedFields() {
+		model.InOrderFields = append(model.InOrderFields, f.Name)
+	}
+	tmpls := append([]templateJSON(nil), m.Tmpls...)
+	sort.SliceStable(tmpls, func(i, j int) bool { return tmpls[i].Ord < tmpls[j].Ord })
+	for _, t := range tmpls {
+		model.CardTemplates = append(model.CardTemplates, ankiconnect.CardTemplate{Name: t.Name, Front: t.Qfmt, Back: t.Afmt})
+	}
+	return model
+}
+
+func (m *modelJSON) sortedFields() []fieldJSON {
+	fields := append([]fieldJSON(nil), m.Flds...)
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].Ord < fields[j].Ord })
+	return fields
+}
+
+// fieldData splits the fields of a note as returned by ankiconnect.
+func (m *modelJSON) fieldData(flds string) map[string]ankiconnect.FieldData {
+	values := strings.Split(flds, "\x1f")
+	fields := map[string]ankiconnect.FieldData{}
+	for _, f := range m.sortedFields() {
+		var value string
+		if f.Ord < len(values) {
+			value = values[f.Ord]
+		}
+		fields[f.Name] = ankiconnect.FieldData{Value: value, Order: int64(f.Ord)}
+	}
+	return fields
+}
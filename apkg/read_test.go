@@ -0,0 +1,292 @@
+package apkg
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/atselvan/ankiconnect"
+	"github.com/atselvan/ankiconnect/ankiconnecttest"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"modernc.org/sqlite"
+)
+
+func TestOpen(t *testing.T) {
+	pkg := New()
+	assert.NoError(t, pkg.AddModel(BasicModel()))
+	assert.NoError(t, pkg.AddModel(ClozeModel()))
+	assert.NoError(t, pkg.AddNote(ankiconnect.Note{
+		DeckName:  "Japanese::Verbs",
+		ModelName: "Basic",
+		Fields:    ankiconnect.Fields{"Front": "taberu", "Back": `to eat <img src="eat.png">`},
+		Tags:      []string{"verb"},
+	}))
+	assert.NoError(t, pkg.AddNote(ankiconnect.Note{
+		DeckName:  "Japanese",
+		ModelName: "Cloze",
+		Fields:    ankiconnect.Fields{"Text": "{{c1::Tokyo}} is in {{c2::Japan}}"},
+	}))
+	assert.NoError(t, pkg.AddMedia("eat.png", []byte("png")))
+	path := filepath.Join(t.TempDir(), "japanese.apkg")
+	assert.NoError(t, pkg.WriteFile(path))
+
+	col, err := Open(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer col.Close()
+
+	assert.Equal(t, []Deck{
+		{ID: defaultDeckID, Name: "Default"},
+		{ID: stableID("deck", "Japanese"), Name: "Japanese"},
+		{ID: stableID("deck", "Japanese::Verbs"), Name: "Japanese::Verbs"},
+	}, col.Decks)
+	if assert.Len(t, col.Models, 2) {
+		basic := BasicModel()
+		basic.Css = defaultCSS
+		assert.Equal(t, Model{ID: stableID("model", "Basic"), Model: basic}, col.Models[0])
+		assert.True(t, col.Models[1].IsCloze)
+	}
+
+	if assert.Len(t, col.Notes, 2) {
+		n := col.Notes[0]
+		assert.Equal(t, "Basic", n.ModelName)
+		assert.Equal(t, map[string]ankiconnect.FieldData{
+			"Front": {Value: "taberu", Order: 0},
+			"Back":  {Value: `to eat <img src="eat.png">`, Order: 1},
+		}, n.Fields)
+		assert.Equal(t, []string{"verb"}, n.Tags)
+		assert.Equal(t, guid(stableID("model", "Basic"), "taberu"), n.GUID)
+	}
+	if assert.Len(t, col.Cards, 3) {
+		assert.Equal(t, "Japanese::Verbs", col.Cards[0].DeckName)
+		assert.Equal(t, col.Notes[0].NoteId, col.Cards[0].Note)
+		assert.Equal(t, []int64{0, 1}, []int64{col.Cards[1].Ord, col.Cards[2].Ord})
+		assert.Equal(t, "Cloze", col.Cards[2].ModelName)
+	}
+
+	assert.Equal(t, []string{"eat.png"}, col.Media)
+	content, err := col.MediaFile("eat.png")
+	assert.NoError(t, err)
+	assert.Equal(t, "png", string(content))
+	_, err = col.MediaFile("missing.png")
+	assert.EqualError(t, err, "apkg: the package contains no media file missing.png")
+}
+
+// schema18 creates the tables of a schema 18 collection that differ from the schema 11.
+const schema18 = `
+CREATE TABLE notetypes (id integer NOT NULL PRIMARY KEY, name text NOT NULL COLLATE unicase,
+	mtime_secs integer NOT NULL, usn integer NOT NULL, config blob NOT NULL);
+CREATE UNIQUE INDEX idx_notetypes_name ON notetypes (name);
+CREATE TABLE fields (ntid integer NOT NULL, ord integer NOT NULL, name text NOT NULL COLLATE unicase,
+	config blob NOT NULL, PRIMARY KEY (ntid, ord)) without rowid;
+CREATE TABLE templates (ntid integer NOT NULL, ord integer NOT NULL, name text NOT NULL COLLATE unicase,
+	mtime_secs integer NOT NULL, usn integer NOT NULL, config blob NOT NULL, PRIMARY KEY (ntid, ord)) without rowid;
+CREATE TABLE decks (id integer PRIMARY KEY NOT NULL, name text NOT NULL COLLATE unicase,
+	mtime_secs integer NOT NULL, usn integer NOT NULL, common blob NOT NULL, kind blob NOT NULL);
+CREATE UNIQUE INDEX idx_decks_name ON decks (name);
+`
+
+func init() {
+	// the collation of the name columns of the schema 18, it is not required to read a collection
+	sqlite.MustRegisterCollationUtf8("unicase", func(a, b string) int {
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	})
+}
+
+// protoField encodes a protobuf field, strings and byte slices as length-delimited values and
+// integers as varints.
+func protoField(num uint64, v interface{}) []byte {
+	switch v := v.(type) {
+	case string:
+		return protoField(num, []byte(v))
+	case []byte:
+		b := binary.AppendUvarint(nil, num<<3|wireBytes)
+		b = binary.AppendUvarint(b, uint64(len(v)))
+		return append(b, v...)
+	default:
+		b := binary.AppendUvarint(nil, num<<3|wireVarint)
+		return binary.AppendUvarint(b, v.(uint64))
+	}
+}
+
+func zstdCompress(t *testing.T, data []byte) []byte {
+	enc, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	defer enc.Close()
+	return enc.EncodeAll(data, nil)
+}
+
+// latestPackage returns a package in the format written by Anki 2.1.50 and later: a zstd compressed
+// schema 18 collection, a dummy legacy collection, the package metadata and a zstd compressed
+// protobuf media manifest and media files.
+func latestPackage(t *testing.T) []byte {
+	path := filepath.Join(t.TempDir(), collectionFile21b)
+	db, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec(schema + schema18)
+	require.NoError(t, err)
+
+	const mid, did, nid = 1700000000001, 1700000000002, 1700000000003
+	notetype := append(protoField(notetypeConfigSortField, uint64(0)), protoField(notetypeConfigCSS, ".card {}")...)
+	template := append(protoField(templateConfigQFormat, "{{Front}}"), protoField(templateConfigAFormat, "{{Back}}")...)
+	verbs := protoField(deckKindNormal, append(protoField(1, uint64(1)), protoField(deckNormalDescription, "Verbs to learn")...))
+	for _, stmt := range []struct {
+		query string
+		args  []interface{}
+	}{
+		{`INSERT INTO col VALUES (1, 0, 0, 0, 18, 0, 0, 0, '', '', '', '', '')`, nil},
+		{`INSERT INTO notetypes VALUES (?, 'Basic', 0, 0, ?)`, []interface{}{mid, notetype}},
+		{`INSERT INTO fields VALUES (?, 0, 'Front', x''), (?, 1, 'Back', x'')`, []interface{}{mid, mid}},
+		{`INSERT INTO templates VALUES (?, 0, 'Card 1', 0, 0, ?)`, []interface{}{mid, template}},
+		{`INSERT INTO decks VALUES (1, 'Default', 0, 0, x'', ?), (?, 'Japanese' || char(31) || 'Verbs', 0, 0, x'', ?)`,
+			[]interface{}{protoField(deckKindNormal, protoField(1, uint64(1))), did, verbs}},
+		{`INSERT INTO notes VALUES (?, 'guid', ?, 0, 0, ' verb ', ?, 'Tom & Jerry', 0, 0, '')`,
+			[]interface{}{nid, mid, "Tom &amp; Jerry\x1fto eat <img src=\"eat.png\">"}},
+		{`INSERT INTO cards VALUES (?, ?, ?, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, '')`, []interface{}{nid + 1, nid, did}},
+	} {
+		_, err := db.Exec(stmt.query, stmt.args...)
+		require.NoError(t, err, stmt.query)
+	}
+	require.NoError(t, db.Close())
+	collection, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	media := protoField(mediaEntriesEntries, append(protoField(mediaEntryName, "eat.png"), protoField(2, uint64(3))...))
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string][]byte{
+		collectionFile:    []byte("a collection asking to update Anki"),
+		collectionFile21b: zstdCompress(t, collection),
+		metaFile:          protoField(packageMetadataVersion, uint64(packageVersionLatest)),
+		mediaFile:         zstdCompress(t, media),
+		"0":               zstdCompress(t, []byte("png")),
+	} {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestRead_Latest(t *testing.T) {
+	content := latestPackage(t)
+	col, err := Read(bytes.NewReader(content), int64(len(content)))
+	require.NoError(t, err)
+
+	assert.Equal(t, []Deck{
+		{ID: 1, Name: "Default"},
+		{ID: 1700000000002, Name: "Japanese::Verbs", Description: "Verbs to learn"},
+	}, col.Decks)
+	assert.Equal(t, []Model{{
+		ID: 1700000000001,
+		Model: ankiconnect.Model{
+			ModelName:     "Basic",
+			InOrderFields: []string{"Front", "Back"},
+			Css:           ".card {}",
+			CardTemplates: []ankiconnect.CardTemplate{{Name: "Card 1", Front: "{{Front}}", Back: "{{Back}}"}},
+		},
+	}}, col.Models)
+	if assert.Len(t, col.Notes, 1) {
+		assert.Equal(t, "Tom &amp; Jerry", col.Notes[0].Fields["Front"].Value)
+		assert.Equal(t, []string{"verb"}, col.Notes[0].Tags)
+	}
+	if assert.Len(t, col.Cards, 1) {
+		assert.Equal(t, "Japanese::Verbs", col.Cards[0].DeckName)
+		assert.Equal(t, int64(1), col.Cards[0].Due)
+	}
+	assert.Equal(t, []string{"eat.png"}, col.Media)
+	media, err := col.MediaFile("eat.png")
+	assert.NoError(t, err)
+	assert.Equal(t, "png", string(media))
+}
+
+func TestReadErrors(t *testing.T) {
+	archive := func(names ...string) *bytes.Reader {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		for _, name := range names {
+			_, err := zw.Create(name)
+			assert.NoError(t, err)
+		}
+		assert.NoError(t, zw.Close())
+		return bytes.NewReader(buf.Bytes())
+	}
+
+	r := archive(mediaFile)
+	_, err := Read(r, r.Size())
+	assert.EqualError(t, err, noCollectionErrMsg)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create(metaFile)
+	assert.NoError(t, err)
+	_, err = w.Write(protoField(packageMetadataVersion, uint64(packageVersionLatest)))
+	assert.NoError(t, err)
+	_, err = zw.Create(collectionFile)
+	assert.NoError(t, err)
+	assert.NoError(t, zw.Close())
+	r = bytes.NewReader(buf.Bytes())
+	_, err = Read(r, r.Size())
+	assert.EqualError(t, err, noCollectionErrMsg)
+
+	r = bytes.NewReader([]byte("not a zip"))
+	_, err = Read(r, r.Size())
+	assert.Error(t, err)
+}
+
+func TestDiff(t *testing.T) {
+	server := ankiconnecttest.NewServer()
+	defer server.Close()
+	server.AddDeck("Japanese")
+	for _, n := range []ankiconnect.Note{
+		{DeckName: "Japanese", ModelName: "Basic", Fields: ankiconnect.Fields{"Front": "taberu", "Back": "eat"}, Tags: []string{"verb"}},
+		{DeckName: "Japanese", ModelName: "Basic", Fields: ankiconnect.Fields{"Front": "nomu", "Back": "to drink"}},
+		{DeckName: "Japanese", ModelName: "Basic", Fields: ankiconnect.Fields{"Front": "miru", "Back": "to see"}},
+	} {
+		_, err := server.AddNote(n)
+		assert.NoError(t, err)
+	}
+	live, err := server.Client().Notes.Get("deck:Japanese")
+	assert.NoError(t, err)
+
+	pkg := New()
+	assert.NoError(t, pkg.AddModel(BasicModel()))
+	for _, n := range []ankiconnect.Note{
+		{DeckName: "Japanese", ModelName: "Basic", Fields: ankiconnect.Fields{"Front": "taberu", "Back": "to eat"}, Tags: []string{"verb", "jlpt5"}},
+		{DeckName: "Japanese", ModelName: "Basic", Fields: ankiconnect.Fields{"Front": "nomu", "Back": "to drink"}},
+		{DeckName: "Japanese", ModelName: "Basic", Fields: ankiconnect.Fields{"Front": "kiku", "Back": "to listen"}},
+	} {
+		assert.NoError(t, pkg.AddNote(n))
+	}
+	var buf bytes.Buffer
+	_, err = pkg.WriteTo(&buf)
+	assert.NoError(t, err)
+	col, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+
+	diffs := Diff(*live, col.NotesInfo())
+	if !assert.Len(t, diffs, 3) {
+		return
+	}
+	assert.Equal(t, NoteAdded, diffs[0].Kind)
+	assert.Equal(t, "kiku", diffs[0].Key)
+	assert.Nil(t, diffs[0].Old)
+	assert.Equal(t, NoteRemoved, diffs[1].Kind)
+	assert.Equal(t, "miru", diffs[1].Key)
+	assert.Equal(t, NoteChanged, diffs[2].Kind)
+	assert.Equal(t, "taberu", diffs[2].Key)
+	assert.Equal(t, []string{"Back"}, diffs[2].Fields)
+	assert.Equal(t, []string{"jlpt5"}, diffs[2].AddedTags)
+	assert.Empty(t, diffs[2].RemovedTags)
+}
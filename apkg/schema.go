@@ -0,0 +1,174 @@
+package apkg
+
+import "encoding/json"
+
+const (
+	// collectionFile is the name of the collection database in a package, in the legacy schema 11
+	// that every Anki version since 2.1 imports.
+	collectionFile = "collection.anki2"
+	// mediaFile is the name of the json manifest mapping the media entries to their file names.
+	mediaFile = "media"
+
+	schemaVersion = 11
+	defaultDeckID = 1
+	defaultConfID = 1
+
+	modelTypeStandard = 0
+	modelTypeCloze    = 1
+)
+
+// schema creates the tables of a schema 11 collection.
+const schema = `
+CREATE TABLE col (
+	id integer primary key,
+	crt integer not null,
+	mod integer not null,
+	scm integer not null,
+	ver integer not null,
+	dty integer not null,
+	usn integer not null,
+	ls integer not null,
+	conf text not null,
+	models text not null,
+	decks text not null,
+	dconf text not null,
+	tags text not null
+);
+CREATE TABLE notes (
+	id integer primary key,
+	guid text not null,
+	mid integer not null,
+	mod integer not null,
+	usn integer not null,
+	tags text not null,
+	flds text not null,
+	sfld integer not null,
+	csum integer not null,
+	flags integer not null,
+	data text not null
+);
+CREATE TABLE cards (
+	id integer primary key,
+	nid integer not null,
+	did integer not null,
+	ord integer not null,
+	mod integer not null,
+	usn integer not null,
+	type integer not null,
+	queue integer not null,
+	due integer not null,
+	ivl integer not null,
+	factor integer not null,
+	reps integer not null,
+	lapses integer not null,
+	left integer not null,
+	odue integer not null,
+	odid integer not null,
+	flags integer not null,
+	data text not null
+);
+CREATE TABLE revlog (
+	id integer primary key,
+	cid integer not null,
+	usn integer not null,
+	ease integer not null,
+	ivl integer not null,
+	lastIvl integer not null,
+	factor integer not null,
+	time integer not null,
+	type integer not null
+);
+CREATE TABLE graves (
+	usn integer not null,
+	oid integer not null,
+	type integer not null
+);
+CREATE INDEX ix_notes_usn ON notes (usn);
+CREATE INDEX ix_cards_usn ON cards (usn);
+CREATE INDEX ix_revlog_usn ON revlog (usn);
+CREATE INDEX ix_cards_nid ON cards (nid);
+CREATE INDEX ix_cards_sched ON cards (did, queue, due);
+CREATE INDEX ix_revlog_cid ON revlog (cid);
+CREATE INDEX ix_notes_csum ON notes (csum);
+`
+
+// defaultConf is the collection configuration of a new collection.
+const defaultConf = `{"activeDecks":[1],"curDeck":1,"newSpread":0,"collapseTime":1200,"timeLim":0,` +
+	`"estTimes":true,"dueCounts":true,"curModel":null,"nextPos":1,"sortType":"noteFld",` +
+	`"sortBackwards":false,"addToCur":true}`
+
+// defaultDeckConf are the options of the decks of the package, the defaults of Anki.
+const defaultDeckConf = `{"1":{"id":1,"mod":0,"name":"Default","usn":0,"maxTaken":60,"autoplay":true,` +
+	`"timer":0,"replayq":true,"dyn":false,` +
+	`"new":{"bury":false,"delays":[1.0,10.0],"initialFactor":2500,"ints":[1,4,0],"order":1,"perDay":20},` +
+	`"lapse":{"delays":[10.0],"leechAction":1,"leechFails":8,"minInt":1,"mult":0.0},` +
+	`"rev":{"bury":false,"ease4":1.3,"ivlFct":1.0,"maxIvl":36500,"perDay":200,"hardFactor":1.2}}}`
+
+const (
+	defaultLatexPre = "\\documentclass[12pt]{article}\n\\special{papersize=3in,5in}\n" +
+		"\\usepackage[utf8]{inputenc}\n\\usepackage{amssymb,amsmath}\n\\pagestyle{empty}\n" +
+		"\\setlength{\\parindent}{0in}\n\\begin{document}\n"
+	defaultLatexPost = "\\end{document}"
+	defaultCSS       = ".card {\n font-family: arial;\n font-size: 20px;\n text-align: center;\n" +
+		" color: black;\n background-color: white;\n}\n"
+)
+
+type (
+	// modelJSON is a model as stored in the models column of the col table.
+	modelJSON struct {
+		ID        int64           `json:"id"`
+		Name      string          `json:"name"`
+		Type      int             `json:"type"`
+		Mod       int64           `json:"mod"`
+		Usn       int             `json:"usn"`
+		Sortf     int             `json:"sortf"`
+		Did       int64           `json:"did"`
+		Tmpls     []templateJSON  `json:"tmpls"`
+		Flds      []fieldJSON     `json:"flds"`
+		Css       string          `json:"css"`
+		LatexPre  string          `json:"latexPre"`
+		LatexPost string          `json:"latexPost"`
+		Req       [][]interface{} `json:"req"`
+		Tags      []string        `json:"tags"`
+		Vers      []interface{}   `json:"vers"`
+	}
+
+	templateJSON struct {
+		Name  string           `json:"name"`
+		Ord   int              `json:"ord"`
+		Qfmt  string           `json:"qfmt"`
+		Afmt  string           `json:"afmt"`
+		Bqfmt string           `json:"bqfmt"`
+		Bafmt string           `json:"bafmt"`
+		Did   *json.RawMessage `json:"did"`
+	}
+
+	fieldJSON struct {
+		Name   string        `json:"name"`
+		Ord    int           `json:"ord"`
+		Sticky bool          `json:"sticky"`
+		Rtl    bool          `json:"rtl"`
+		Font   string        `json:"font"`
+		Size   int           `json:"size"`
+		Media  []interface{} `json:"media"`
+	}
+
+	// deckJSON is a deck as stored in the decks column of the col table.
+	deckJSON struct {
+		ID               int64  `json:"id"`
+		Name             string `json:"name"`
+		Mod              int64  `json:"mod"`
+		Usn              int    `json:"usn"`
+		Desc             string `json:"desc"`
+		Dyn              int    `json:"dyn"`
+		Conf             int64  `json:"conf"`
+		Collapsed        bool   `json:"collapsed"`
+		BrowserCollapsed bool   `json:"browserCollapsed"`
+		ExtendNew        int    `json:"extendNew"`
+		ExtendRev        int    `json:"extendRev"`
+		NewToday         [2]int `json:"newToday"`
+		RevToday         [2]int `json:"revToday"`
+		LrnToday         [2]int `json:"lrnToday"`
+		TimeToday        [2]int `json:"timeToday"`
+	}
+)
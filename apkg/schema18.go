@@ -0,0 +1,177 @@
+package apkg
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// metaFile is the name of the protobuf PackageMetadata of the packages written by Anki 2.1.50 and later.
+	metaFile = "meta"
+	// packageVersionLatest is the package version with a zstd compressed schema 18 collection, a zstd
+	// compressed protobuf media manifest and zstd compressed media files.
+	packageVersionLatest = 3
+	// schemaNotetypes is the first schema version that stores the models and decks in their own tables
+	// instead of the json columns of the col table.
+	schemaNotetypes = 15
+
+	// deckNameSeparator separates the components of the deck names stored in the decks table.
+	deckNameSeparator = "\x1f"
+)
+
+// The field numbers of the protobuf messages of packages and schema 18 collections, as defined by
+// the import_export.proto, notetypes.proto and decks.proto files of Anki.
+const (
+	packageMetadataVersion = 1
+
+	mediaEntriesEntries     = 1
+	mediaEntryName          = 1
+	mediaEntryLegacyZipName = 255
+
+	notetypeConfigKind      = 1
+	notetypeConfigSortField = 2
+	notetypeConfigCSS       = 3
+
+	templateConfigQFormat = 1
+	templateConfigAFormat = 2
+
+	deckKindNormal        = 1
+	deckNormalDescription = 4
+)
+
+// zstdReader returns a reader decompressing the zstd stream of r.
+func zstdReader(r io.Reader) (io.ReadCloser, error) {
+	d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return d.IOReadCloser(), nil
+}
+
+// readSchema18 reads the models and decks of a collection in the schema 15 or later from the
+// notetypes, fields, templates and decks tables.
+func readSchema18(ctx context.Context, db *sql.DB) ([]modelJSON, []deckJSON, error) {
+	models, err := readNotetypes(ctx, db)
+	if err != nil {
+		return nil, nil, err
+	}
+	byID := map[int64]*modelJSON{}
+	for i := range models {
+		byID[models[i].ID] = &models[i]
+	}
+	if err := readFields(ctx, db, byID); err != nil {
+		return nil, nil, err
+	}
+	if err := readTemplates(ctx, db, byID); err != nil {
+		return nil, nil, err
+	}
+	decks, err := readDecks(ctx, db)
+	if err != nil {
+		return nil, nil, err
+	}
+	return models, decks, nil
+}
+
+func readNotetypes(ctx context.Context, db *sql.DB) ([]modelJSON, error) {
+	rows, err := db.QueryContext(ctx, `SELECT id, name, mtime_secs, config FROM notetypes ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("apkg: invalid collection: %w", err)
+	}
+	defer rows.Close()
+	var models []modelJSON
+	for rows.Next() {
+		var m modelJSON
+		var config []byte
+		if err := rows.Scan(&m.ID, &m.Name, &m.Mod, &config); err != nil {
+			return nil, err
+		}
+		cfg, err := decodeProto(config)
+		if err != nil {
+			return nil, fmt.Errorf("apkg: invalid config of model %s: %w", m.Name, err)
+		}
+		m.Type = int(cfg.uint(notetypeConfigKind))
+		m.Sortf = int(cfg.uint(notetypeConfigSortField))
+		m.Css = cfg.string(notetypeConfigCSS)
+		models = append(models, m)
+	}
+	return models, rows.Err()
+}
+
+func readFields(ctx context.Context, db *sql.DB, models map[int64]*modelJSON) error {
+	rows, err := db.QueryContext(ctx, `SELECT ntid, ord, name FROM fields ORDER BY ntid, ord`)
+	if err != nil {
+		return fmt.Errorf("apkg: invalid collection: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ntid int64
+		var f fieldJSON
+		if err := rows.Scan(&ntid, &f.Ord, &f.Name); err != nil {
+			return err
+		}
+		if m, ok := models[ntid]; ok {
+			m.Flds = append(m.Flds, f)
+		}
+	}
+	return rows.Err()
+}
+
+func readTemplates(ctx context.Context, db *sql.DB, models map[int64]*modelJSON) error {
+	rows, err := db.QueryContext(ctx, `SELECT ntid, ord, name, config FROM templates ORDER BY ntid, ord`)
+	if err != nil {
+		return fmt.Errorf("apkg: invalid collection: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ntid int64
+		var t templateJSON
+		var config []byte
+		if err := rows.Scan(&ntid, &t.Ord, &t.Name, &config); err != nil {
+			return err
+		}
+		cfg, err := decodeProto(config)
+		if err != nil {
+			return fmt.Errorf("apkg: invalid config of template %s: %w", t.Name, err)
+		}
+		t.Qfmt, t.Afmt = cfg.string(templateConfigQFormat), cfg.string(templateConfigAFormat)
+		if m, ok := models[ntid]; ok {
+			m.Tmpls = append(m.Tmpls, t)
+		}
+	}
+	return rows.Err()
+}
+
+func readDecks(ctx context.Context, db *sql.DB) ([]deckJSON, error) {
+	rows, err := db.QueryContext(ctx, `SELECT id, name, mtime_secs, kind FROM decks ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("apkg: invalid collection: %w", err)
+	}
+	defer rows.Close()
+	var decks []deckJSON
+	for rows.Next() {
+		var d deckJSON
+		var kind []byte
+		if err := rows.Scan(&d.ID, &d.Name, &d.Mod, &kind); err != nil {
+			return nil, err
+		}
+		d.Name = strings.ReplaceAll(d.Name, deckNameSeparator, "::")
+		container, err := decodeProto(kind)
+		if err != nil {
+			return nil, fmt.Errorf("apkg: invalid kind of deck %s: %w", d.Name, err)
+		}
+		if container.has(deckKindNormal) {
+			normal, err := container.message(deckKindNormal)
+			if err != nil {
+				return nil, fmt.Errorf("apkg: invalid kind of deck %s: %w", d.Name, err)
+			}
+			d.Desc = normal.string(deckNormalDescription)
+		}
+		decks = append(decks, d)
+	}
+	return decks, rows.Err()
+}
@@ -0,0 +1,246 @@
+package apkg
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/atselvan/ankiconnect/internal/htmltext"
+
+	// registers the pure Go sqlite driver, no cgo is required to write packages.
+	_ "modernc.org/sqlite"
+)
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// WriteFile writes the package to the file at path, which is replaced if it exists.
+func (p *Package) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := p.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WriteTo writes the package as a zip archive to w and returns the number of bytes written.
+// The collection database is created in a temporary directory.
+func (p *Package) WriteTo(w io.Writer) (int64, error) {
+	dir, err := os.MkdirTemp("", "apkg")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(dir)
+	dbPath := filepath.Join(dir, collectionFile)
+	if err := p.writeCollection(dbPath); err != nil {
+		return 0, err
+	}
+
+	cw := &countingWriter{w: w}
+	zw := zip.NewWriter(cw)
+	if err := addZipFile(zw, collectionFile, dbPath); err != nil {
+		return cw.n, err
+	}
+	manifest := make(map[string]string, len(p.mediaOrder))
+	for i, name := range p.mediaOrder {
+		entry := strconv.Itoa(i)
+		manifest[entry] = name
+		fw, err := zw.Create(entry)
+		if err != nil {
+			return cw.n, err
+		}
+		if _, err := fw.Write(p.media[name]); err != nil {
+			return cw.n, err
+		}
+	}
+	fw, err := zw.Create(mediaFile)
+	if err != nil {
+		return cw.n, err
+	}
+	if err := json.NewEncoder(fw).Encode(manifest); err != nil {
+		return cw.n, err
+	}
+	err = zw.Close()
+	return cw.n, err
+}
+
+func addZipFile(zw *zip.Writer, name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fw, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, f)
+	return err
+}
+
+// writeCollection creates the collection database at path.
+func (p *Package) writeCollection(path string) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, schema); err != nil {
+		return err
+	}
+	if err := p.insertCol(ctx, tx); err != nil {
+		return err
+	}
+	if err := p.insertNotes(ctx, tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (p *Package) insertCol(ctx context.Context, tx *sql.Tx) error {
+	now := p.Time.Unix()
+	models := map[string]modelJSON{}
+	for _, name := range p.modelOrder {
+		m := p.models[name]
+		models[strconv.FormatInt(m.id, 10)] = m.json(now)
+	}
+	decks := map[string]deckJSON{}
+	if _, ok := p.decks[DefaultDeck]; !ok {
+		decks[strconv.Itoa(defaultDeckID)] = newDeck(defaultDeckID, DefaultDeck, now)
+	}
+	for _, name := range p.deckOrder {
+		id := p.decks[name]
+		decks[strconv.FormatInt(id, 10)] = newDeck(id, name, now)
+	}
+	modelsJSON, err := json.Marshal(models)
+	if err != nil {
+		return err
+	}
+	decksJSON, err := json.Marshal(decks)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO col VALUES (1, ?, ?, ?, ?, 0, 0, 0, ?, ?, ?, ?, '{}')`,
+		now, now*1000, now*1000, schemaVersion, defaultConf, string(modelsJSON), string(decksJSON), defaultDeckConf,
+	)
+	return err
+}
+
+func (p *Package) insertNotes(ctx context.Context, tx *sql.Tx) error {
+	insertNote, err := tx.PrepareContext(ctx, `INSERT INTO notes VALUES (?, ?, ?, ?, -1, ?, ?, ?, ?, 0, '')`)
+	if err != nil {
+		return err
+	}
+	defer insertNote.Close()
+	insertCard, err := tx.PrepareContext(ctx,
+		`INSERT INTO cards VALUES (?, ?, ?, ?, ?, -1, 0, 0, ?, 0, 0, 0, 0, 0, 0, 0, 0, '')`)
+	if err != nil {
+		return err
+	}
+	defer insertCard.Close()
+
+	now := p.Time.Unix()
+	// the ids are consecutive milliseconds starting at the time of the package, as in Anki
+	nextID := p.Time.UnixMilli()
+	for i, n := range p.notes {
+		noteID := nextID
+		nextID++
+		sortField := htmltext.StripPreservingMedia(n.fields[0])
+		tags := ""
+		if len(n.tags) > 0 {
+			tags = " " + strings.Join(n.tags, " ") + " "
+		}
+		_, err := insertNote.ExecContext(ctx, noteID, n.guid, n.model.id, now, tags,
+			strings.Join(n.fields, "\x1f"), sortField, checksum(sortField))
+		if err != nil {
+			return err
+		}
+		for _, ord := range n.ords {
+			if _, err := insertCard.ExecContext(ctx, nextID, noteID, n.deck, ord, now, i+1); err != nil {
+				return err
+			}
+			nextID++
+		}
+	}
+	return nil
+}
+
+// json returns the model as stored in the collection.
+func (m *model) json(mod int64) modelJSON {
+	mj := modelJSON{
+		ID:        m.id,
+		Name:      m.def.ModelName,
+		Type:      modelTypeStandard,
+		Mod:       mod,
+		Usn:       -1,
+		Did:       defaultDeckID,
+		Css:       m.def.Css,
+		LatexPre:  defaultLatexPre,
+		LatexPost: defaultLatexPost,
+		Req:       [][]interface{}{},
+		Tags:      []string{},
+		Vers:      []interface{}{},
+	}
+	if mj.Css == "" {
+		mj.Css = defaultCSS
+	}
+	if m.def.IsCloze {
+		mj.Type = modelTypeCloze
+	}
+	for i, f := range m.def.InOrderFields {
+		mj.Flds = append(mj.Flds, fieldJSON{Name: f, Ord: i, Font: "Arial", Size: 20, Media: []interface{}{}})
+	}
+	for i, t := range m.def.CardTemplates {
+		mj.Tmpls = append(mj.Tmpls, templateJSON{Name: t.Name, Ord: i, Qfmt: t.Front, Afmt: t.Back})
+		if !m.def.IsCloze {
+			// legacy Anki versions require the fields that generate the card
+			mj.Req = append(mj.Req, []interface{}{i, "any", m.templateFields[i]})
+		}
+	}
+	return mj
+}
+
+func newDeck(id int64, name string, mod int64) deckJSON {
+	return deckJSON{
+		ID:   id,
+		Name: name,
+		Mod:  mod,
+		Usn:  -1,
+		Conf: defaultConfID,
+	}
+}
+
+// checksum returns the first 8 hex digits of the sha1 of the first field as a number.
+func checksum(s string) int64 {
+	sum := sha1.Sum([]byte(s))
+	v, _ := strconv.ParseInt(hex.EncodeToString(sum[:4]), 16, 64)
+	return v
+}
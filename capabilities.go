@@ -0,0 +1,152 @@
+package ankiconnect
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+const (
+	ActionVersion    = "version"
+	ActionAPIReflect = "apiReflect"
+
+	// minAnkiConnectVersion is the oldest ankiconnect api version that returns results in the
+	// {"result": ..., "error": ...} format expected by the client.
+	minAnkiConnectVersion = 5
+
+	unsupportedVersionErrMsg = "ankiconnect api version %d is not supported, version %d or later is required"
+	unsupportedActionErrMsg  = "action is not supported by the installed ankiconnect version"
+
+	apiReflectScopeActions = "actions"
+)
+
+type (
+	// Capabilities describes the api version and the actions supported by the installed ankiconnect add-on.
+	Capabilities struct {
+		// Version is the api version reported by ankiconnect.
+		Version int
+		// Actions are the names of the actions supported by ankiconnect, sorted alphabetically.
+		// Actions is empty if the add-on is too old to report its actions.
+		Actions []string
+
+		actions map[string]struct{}
+	}
+
+	// ParamsAPIReflect represents the ankiconnect API params for reflecting on the available api.
+	ParamsAPIReflect struct {
+		Scopes  []string  `json:"scopes"`
+		Actions *[]string `json:"actions"`
+	}
+
+	// ResultAPIReflect represents the ankiconnect API result of reflecting on the available api.
+	ResultAPIReflect struct {
+		Scopes  []string `json:"scopes,omitempty"`
+		Actions []string `json:"actions,omitempty"`
+	}
+)
+
+// Supports reports whether the action is supported by ankiconnect.
+// If the supported actions are unknown every action is assumed to be supported.
+func (cp *Capabilities) Supports(action string) bool {
+	if cp == nil || len(cp.actions) == 0 {
+		return true
+	}
+	_, ok := cp.actions[action]
+	return ok
+}
+
+// Connect queries the api version and the supported actions of the installed ankiconnect add-on.
+// The client version is lowered to the version of the add-on if the add-on is older and the
+// supported actions are cached, so that calls to unsupported actions fail with ErrUnsupportedAction
+// without being sent to ankiconnect.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+//   - the ankiconnect version is older than the oldest version supported by the client.
+func (c *Client) Connect() (*Capabilities, error) {
+	c.mu.Lock()
+	c.capabilities = nil
+	c.mu.Unlock()
+
+	version, err := post[int, ParamsDefault](c, ActionVersion, nil)
+	if err != nil {
+		return nil, err
+	}
+	if *version < minAnkiConnectVersion {
+		return nil, &Error{
+			Action:     ActionVersion,
+			Message:    fmt.Sprintf(unsupportedVersionErrMsg, *version, minAnkiConnectVersion),
+			StatusCode: http.StatusBadRequest,
+			Kind:       ErrUnsupportedVersion,
+		}
+	}
+	c.mu.Lock()
+	if *version < c.Version {
+		c.Version = *version
+	}
+	c.mu.Unlock()
+
+	capabilities := &Capabilities{
+		Version: *version,
+	}
+	params := ParamsAPIReflect{
+		Scopes: []string{apiReflectScopeActions},
+	}
+	reflect, err := post[ResultAPIReflect](c, ActionAPIReflect, &params)
+	switch {
+	case err == nil:
+		capabilities.setActions(reflect.Actions)
+	case !isUnsupportedAction(err):
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.capabilities = capabilities
+	return capabilities, nil
+}
+
+// Capabilities returns the capabilities queried by Connect or nil if Connect was not called.
+func (c *Client) Capabilities() *Capabilities {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.capabilities
+}
+
+// checkSupported returns ErrUnsupportedAction if the action or one of the actions of a multi
+// request is not supported by ankiconnect.
+func (c *Client) checkSupported(action string, params interface{}) error {
+	if c.Capabilities().Supports(action) {
+		if m, ok := params.(*ParamsMulti); ok && m != nil {
+			for _, a := range m.Actions {
+				if err := c.checkSupported(a.Action, nil); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return &Error{
+		Action:     action,
+		Message:    unsupportedActionErrMsg,
+		StatusCode: http.StatusBadRequest,
+		Kind:       ErrUnsupportedAction,
+	}
+}
+
+func (cp *Capabilities) setActions(actions []string) {
+	cp.Actions = append([]string(nil), actions...)
+	sort.Strings(cp.Actions)
+	cp.actions = make(map[string]struct{}, len(actions))
+	for _, a := range actions {
+		cp.actions[a] = struct{}{}
+	}
+}
+
+// isUnsupportedAction reports whether ankiconnect rejected the action because it does not know it.
+// Older versions of ankiconnect report unknown actions with a "unsupported action" error.
+func isUnsupportedAction(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Kind == ErrUnsupportedAction
+}
@@ -0,0 +1,99 @@
+package ankiconnect
+
+import (
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Connect(t *testing.T) {
+	versionPayload := []byte(`{
+    "action": "version",
+    "version": 6
+}`)
+	apiReflectPayload := []byte(`{
+    "action": "apiReflect",
+    "version": 6,
+    "params": {
+        "scopes": ["actions"],
+        "actions": null
+    }
+}`)
+	apiReflectResult := []byte(`{
+    "result": {
+        "scopes": ["actions"],
+        "actions": ["version", "deckNames", "apiReflect"]
+    },
+    "error": null
+}`)
+
+	t.Run("success", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		c := NewClient()
+		httpmock.ActivateNonDefault(c.httpClient.GetClient())
+		registerMultipleVerifiedPayloads(t, [][2][]byte{
+			{versionPayload, []byte(`{"result": 6, "error": null}`)},
+			{apiReflectPayload, apiReflectResult},
+			{[]byte(`{"action": "deckNames", "version": 6}`), []byte(`{"result": ["Default"], "error": null}`)},
+		})
+
+		capabilities, err := c.Connect()
+		assert.NoError(t, err)
+		assert.Equal(t, 6, capabilities.Version)
+		assert.Equal(t, []string{"apiReflect", "deckNames", "version"}, capabilities.Actions)
+		assert.True(t, capabilities.Supports(ActionDeckNames))
+		assert.False(t, capabilities.Supports(ActionCreateDeck))
+		assert.Same(t, capabilities, c.Capabilities())
+
+		_, err = c.Decks.GetAll()
+		assert.NoError(t, err)
+
+		// unsupported actions are not sent to ankiconnect
+		err = c.Decks.Create("test")
+		assert.ErrorIs(t, err, ErrUnsupportedAction)
+		_, err = c.Multi(MultiAction{Action: ActionDeckNames}, MultiAction{Action: ActionCreateDeck})
+		assert.ErrorIs(t, err, ErrUnsupportedAction)
+	})
+
+	t.Run("older version", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		c := NewClient()
+		httpmock.ActivateNonDefault(c.httpClient.GetClient())
+		registerMultipleVerifiedPayloads(t, [][2][]byte{
+			{versionPayload, []byte(`{"result": 5, "error": null}`)},
+			{[]byte(`{"action": "apiReflect", "version": 5, "params": {"scopes": ["actions"], "actions": null}}`),
+				[]byte(`{"result": null, "error": "unsupported action"}`)},
+		})
+
+		capabilities, err := c.Connect()
+		assert.NoError(t, err)
+		assert.Equal(t, 5, c.Version)
+		assert.Empty(t, capabilities.Actions)
+		assert.True(t, capabilities.Supports(ActionCreateDeck))
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		c := NewClient()
+		httpmock.ActivateNonDefault(c.httpClient.GetClient())
+		registerVerifiedPayload(t, versionPayload, []byte(`{"result": 4, "error": null}`))
+
+		capabilities, err := c.Connect()
+		assert.Nil(t, capabilities)
+		assert.ErrorIs(t, err, ErrUnsupportedVersion)
+		assert.Nil(t, c.Capabilities())
+	})
+
+	t.Run("error", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerErrorResponse(t)
+
+		_, err := client.Connect()
+		assert.Error(t, err)
+	})
+}
@@ -1,6 +1,6 @@
 package ankiconnect
 
-import "github.com/privatesquare/bkst-go-utils/utils/errors"
+import "iter"
 
 const (
 	ActionFindCards = "findCards"
@@ -10,8 +10,9 @@ const (
 type (
 	// Notes manager describes the interface that can be used to perform operation on the notes in a deck.
 	CardsManager interface {
-		Search(query string) (*[]int64, *errors.RestErr)
-		Get(query string) (*[]ResultCardsInfo, *errors.RestErr)
+		Search(query string) (*[]int64, error)
+		Get(query string) (*[]ResultCardsInfo, error)
+		Iterate(query string, opts *IterateOptions) iter.Seq2[ResultCardsInfo, error]
 	}
 
 	// notesManager implements NotesManager.
@@ -50,20 +51,35 @@ type (
 	}
 )
 
-func (cm *cardsManager) Search(query string) (*[]int64, *errors.RestErr) {
+func (cm *cardsManager) Search(query string) (*[]int64, error) {
 	findParams := ParamsFindCards{
 		Query: query,
 	}
 	return post[[]int64](cm.Client, ActionFindCards, &findParams)
 }
 
-func (cm *cardsManager) Get(query string) (*[]ResultCardsInfo, *errors.RestErr) {
-	cardIds, restErr := cm.Search(query)
-	if restErr != nil {
-		return nil, restErr
+func (cm *cardsManager) Get(query string) (*[]ResultCardsInfo, error) {
+	cardIds, err := cm.Search(query)
+	if err != nil {
+		return nil, err
 	}
 	infoParams := ParamsCardsInfo{
 		Cards: cardIds,
 	}
 	return post[[]ResultCardsInfo](cm.Client, ActionCardsInfo, &infoParams)
 }
+
+// Iterate searches the cards matching the query and yields their info one by one,
+// fetching the info in pages so that large collections can be processed with bounded memory.
+// A nil opts uses the default IterateOptions. Breaking out of the loop stops fetching further pages.
+// The iteration yields an error and stops if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+func (cm *cardsManager) Iterate(query string, opts *IterateOptions) iter.Seq2[ResultCardsInfo, error] {
+	return iterate(opts,
+		func() (*[]int64, error) { return cm.Search(query) },
+		func(ids []int64) (*[]ResultCardsInfo, error) {
+			return post[[]ResultCardsInfo](cm.Client, ActionCardsInfo, &ParamsCardsInfo{Cards: &ids})
+		},
+	)
+}
@@ -43,8 +43,8 @@ func TestCardsManager_Get(t *testing.T) {
 			})
 
 		payload := "deck:current"
-		notes, restErr := client.Cards.Get(payload)
-		assert.Nil(t, restErr)
+		notes, err := client.Cards.Get(payload)
+		assert.Nil(t, err)
 		assert.Equal(t, len(*notes), 2)
 
 	})
@@ -54,10 +54,9 @@ func TestCardsManager_Get(t *testing.T) {
 
 		registerErrorResponse(t)
 
-		_, restErr := client.Cards.Get("deck:current")
-		assert.NotNil(t, restErr)
-		assert.Equal(t, http.StatusBadRequest, restErr.StatusCode)
-		assert.Equal(t, "some error message", restErr.Message)
+		_, err := client.Cards.Get("deck:current")
+		assert.NotNil(t, err)
+		assertRestErr(t, err, http.StatusBadRequest, "some error message")
 	})
 
 }
@@ -0,0 +1,220 @@
+package ankiconnect
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+)
+
+const (
+	// CassetteRecord records every request sent to ankiconnect and its response.
+	CassetteRecord CassetteMode = iota
+	// CassetteReplay serves the responses from the cassette without sending requests to ankiconnect.
+	CassetteReplay
+
+	unexpectedRequestErrMsg = "no recorded interaction for the action with the given params"
+)
+
+type (
+	// CassetteMode describes whether a Cassette records or replays interactions.
+	CassetteMode int
+
+	// Interaction is a single request and response stored in a cassette.
+	Interaction struct {
+		Action string          `json:"action"`
+		Params json.RawMessage `json:"params,omitempty"`
+		Result json.RawMessage `json:"result,omitempty"`
+		Error  string          `json:"error,omitempty"`
+	}
+
+	// Cassette records the interactions of a client with ankiconnect to a file in the JSON lines format,
+	// or replays previously recorded interactions, so that tests can run without Anki.
+	// The api key is never written to the cassette.
+	Cassette struct {
+		mode CassetteMode
+
+		mu           sync.Mutex
+		file         *os.File
+		writer       *bufio.Writer
+		writeErr     error
+		interactions []Interaction
+		used         []bool
+	}
+)
+
+// NewRecorder returns a Cassette that records interactions to the file at path.
+// An existing file is truncated.
+func NewRecorder(path string) (*Cassette, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Cassette{
+		mode:   CassetteRecord,
+		file:   file,
+		writer: bufio.NewWriter(file),
+	}, nil
+}
+
+// NewReplayer returns a Cassette that replays the interactions recorded in the file at path.
+func NewReplayer(path string) (*Cassette, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	c := &Cassette{
+		mode: CassetteReplay,
+	}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var interaction Interaction
+		if err := json.Unmarshal(scanner.Bytes(), &interaction); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		c.interactions = append(c.interactions, interaction)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	c.used = make([]bool, len(c.interactions))
+	return c, nil
+}
+
+// SetCassette can be used to record the interactions with ankiconnect or to replay them.
+// A nil cassette disables recording and replaying.
+func (c *Client) SetCassette(cassette *Cassette) *Client {
+	c.cassette = cassette
+	return c
+}
+
+// Mode returns whether the cassette records or replays interactions.
+func (c *Cassette) Mode() CassetteMode {
+	return c.mode
+}
+
+// Unused returns the recorded interactions that have not been replayed yet.
+// It can be used at the end of a test to check that all expected requests were made.
+func (c *Cassette) Unused() []Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var unused []Interaction
+	for i, interaction := range c.interactions {
+		if !c.used[i] {
+			unused = append(unused, interaction)
+		}
+	}
+	return unused
+}
+
+// Close flushes the recorded interactions to the file.
+// It returns the first error that occurred while writing the cassette.
+func (c *Cassette) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file == nil {
+		return nil
+	}
+	err := c.writer.Flush()
+	if closeErr := c.file.Close(); err == nil {
+		err = closeErr
+	}
+	c.file = nil
+	if c.writeErr != nil {
+		return c.writeErr
+	}
+	return err
+}
+
+// record appends an interaction with the raw response body of ankiconnect to the cassette.
+func (c *Cassette) record(action string, params interface{}, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file == nil || c.writeErr != nil {
+		return
+	}
+
+	interaction := Interaction{Action: action}
+	var response Result[json.RawMessage]
+	if err := json.Unmarshal(body, &response); err != nil {
+		c.writeErr = err
+		return
+	}
+	interaction.Result, interaction.Error = response.Result, response.Error
+	if interaction.Params, c.writeErr = marshalParams(params); c.writeErr != nil {
+		return
+	}
+
+	line, err := json.Marshal(interaction)
+	if err != nil {
+		c.writeErr = err
+		return
+	}
+	if _, err := c.writer.Write(append(line, '\n')); err != nil {
+		c.writeErr = err
+	}
+}
+
+// replay looks up the first unused interaction for the action and params and decodes its result
+// into result. It returns ErrUnexpectedRequest if there is no such interaction.
+func (c *Cassette) replay(action string, params interface{}, result interface{}) error {
+	encoded, err := marshalParams(params)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, interaction := range c.interactions {
+		if c.used[i] || interaction.Action != action || !jsonEqual(interaction.Params, encoded) {
+			continue
+		}
+		c.used[i] = true
+		body, err := json.Marshal(Result[json.RawMessage]{
+			Result: interaction.Result,
+			Error:  interaction.Error,
+		})
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(body, result)
+	}
+	return &Error{
+		Action:     action,
+		Message:    unexpectedRequestErrMsg,
+		StatusCode: http.StatusBadRequest,
+		Kind:       ErrUnexpectedRequest,
+	}
+}
+
+// marshalParams encodes the params of a request, nil params are encoded as nil.
+func marshalParams(params interface{}) (json.RawMessage, error) {
+	encoded, err := json.Marshal(params)
+	if err != nil || string(encoded) == "null" {
+		return nil, err
+	}
+	return encoded, nil
+}
+
+// jsonEqual reports whether two json documents are equal regardless of formatting and key order.
+func jsonEqual(a, b json.RawMessage) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) == len(b)
+	}
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	ea, _ := json.Marshal(va)
+	eb, _ := json.Marshal(vb)
+	return bytes.Equal(ea, eb)
+}
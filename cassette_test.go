@@ -0,0 +1,114 @@
+package ankiconnect
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCassette_Record(t *testing.T) {
+	defer httpmock.Reset()
+	defer client.SetCassette(nil).SetAPIKey("")
+
+	path := filepath.Join(t.TempDir(), "cassette.jsonl")
+	recorder, err := NewRecorder(path)
+	require.NoError(t, err)
+	assert.Equal(t, CassetteRecord, recorder.Mode())
+
+	registerMultipleVerifiedPayloads(t, [][2][]byte{
+		{[]byte(`{"action": "deckNames", "version": 6, "key": "secret"}`), []byte(`{"result": ["Default"], "error": null}`)},
+		{[]byte(`{"action": "createDeck", "version": 6, "key": "secret", "params": {"deck": "test"}}`), genericErrorJson},
+	})
+
+	client.SetCassette(recorder).SetAPIKey("secret")
+	_, err = client.Decks.GetAll()
+	assert.NoError(t, err)
+	err = client.Decks.Create("test")
+	assert.Error(t, err)
+	require.NoError(t, recorder.Close())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `{"action":"deckNames","result":["Default"]}
+{"action":"createDeck","params":{"deck":"test"},"result":null,"error":"some error message"}
+`, string(content))
+}
+
+func TestCassette_RecordMulti(t *testing.T) {
+	defer httpmock.Reset()
+	defer client.SetCassette(nil).SetAPIKey("")
+
+	path := filepath.Join(t.TempDir(), "cassette.jsonl")
+	recorder, err := NewRecorder(path)
+	require.NoError(t, err)
+
+	registerVerifiedPayload(t,
+		[]byte(`{"action": "multi", "version": 6, "key": "secret", "params": {"actions": [{"action": "deckNames", "version": 6, "key": "secret"}]}}`),
+		[]byte(`{"result": [{"result": ["Default"], "error": null}], "error": null}`))
+
+	client.SetCassette(recorder).SetAPIKey("secret")
+	_, err = client.Multi(MultiAction{Action: ActionDeckNames})
+	require.NoError(t, err)
+	require.NoError(t, recorder.Close())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "secret")
+	assert.Equal(t, `{"action":"multi","params":{"actions":[{"action":"deckNames"}]},"result":[{"result":["Default"],"error":null}]}
+`, string(content))
+
+	// the recorded multi request is replayed regardless of the api key of the client
+	replayer, err := NewReplayer(path)
+	require.NoError(t, err)
+	client.SetCassette(replayer).SetAPIKey("other")
+	results, err := client.Multi(MultiAction{Action: ActionDeckNames})
+	require.NoError(t, err)
+	assert.JSONEq(t, `["Default"]`, string((*results)[0].Result))
+	assert.Empty(t, replayer.Unused())
+}
+
+func TestCassette_Replay(t *testing.T) {
+	defer httpmock.Reset()
+	defer client.SetCassette(nil)
+
+	replayer, err := NewReplayer(testDataPath + "getNotesCassette.jsonl")
+	require.NoError(t, err)
+	assert.Equal(t, CassetteReplay, replayer.Mode())
+	assert.Len(t, replayer.Unused(), 3)
+
+	// no responder is registered, all responses have to come from the cassette
+	client.SetCassette(replayer)
+
+	notes, err := client.Notes.Get("deck:current")
+	require.NoError(t, err)
+	require.Len(t, *notes, 1)
+	assert.Equal(t, "front content", (*notes)[0].Fields["Front"].Value)
+
+	_, err = client.Decks.GetAll()
+	assertRestErr(t, err, http.StatusBadRequest, "some error message")
+
+	// interactions are only replayed once
+	_, err = client.Decks.GetAll()
+	assert.ErrorIs(t, err, ErrUnexpectedRequest)
+
+	_, err = client.Notes.Search("deck:other")
+	assert.ErrorIs(t, err, ErrUnexpectedRequest)
+	assert.Empty(t, replayer.Unused())
+}
+
+func TestNewReplayer(t *testing.T) {
+	_, err := NewReplayer(filepath.Join(t.TempDir(), "missing.jsonl"))
+	assert.Error(t, err)
+
+	path := filepath.Join(t.TempDir(), "invalid.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte("{\"action\": \"deckNames\"}\nnot json\n"), 0o600))
+	_, err = NewReplayer(path)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid.jsonl:2")
+	}
+}
@@ -1,35 +1,69 @@
 package ankiconnect
 
 import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/go-resty/resty/v2"
-	"github.com/privatesquare/bkst-go-utils/utils/errors"
 	"github.com/privatesquare/bkst-go-utils/utils/httputils"
-	"github.com/privatesquare/bkst-go-utils/utils/logger"
 )
 
 const (
 	ankiConnectUrl     = "http://localhost:8765"
 	ankiConnectVersion = 6
 
+	ActionRequestPermission = "requestPermission"
+
+	// PermissionGranted is the permission level returned by ankiconnect when the origin is allowed to use the api.
+	PermissionGranted = "granted"
+	// PermissionDenied is the permission level returned by ankiconnect when the origin is not allowed to use the api.
+	PermissionDenied = "denied"
+
 	ankiConnectPingErrMsg = "AnkiConnect api is not accessible. Check if anki is running and the ankiconnect add-on is installed correctly"
+	invalidParamsErrMsg   = "invalid params of type %T"
+	invalidResultErrMsg   = "invalid result of type %T"
 )
 
 type (
 	// Client represents the anki connect api client.
+	// A Client is safe for concurrent use by multiple goroutines once it is configured.
+	// Only SetURL, SetVersion, SetAPIKey, SetHTTPClient, SetRateLimit and SetConcurrencyLimit
+	// can be called while the client is in use, the other setters and the exported fields
+	// must not be changed after the client has been shared.
 	Client struct {
-		Url        string
-		Version    int
-		httpClient *resty.Client
+		Url     string
+		Version int
+		// APIKey is sent with every request when ankiconnect is configured with an apiKey.
+		APIKey         string
+		httpClient     *resty.Client
+		capabilities   *Capabilities
+		retryPolicy    *RetryPolicy
+		circuitBreaker *CircuitBreaker
+		cassette       *Cassette
+		interceptors   []Interceptor
+		logger         *slog.Logger
+		metrics        Metrics
+		tracer         Tracer
+		limiter        *rateLimiter
+		semaphore      chan struct{}
+
+		// mu guards the fields that can be changed while the client is in use.
+		mu sync.RWMutex
 
 		// supported interfaces
-		Decks  DecksManager
-		Notes  NotesManager
-		Sync   SyncManager
-		Cards  CardsManager
-		Media  MediaManager
-		Models ModelsManager
+		Decks      DecksManager
+		Notes      NotesManager
+		Sync       SyncManager
+		Cards      CardsManager
+		Media      MediaManager
+		Models     ModelsManager
+		Packages   PackagesManager
+		Collection CollectionManager
+		GUI        GUIManager
 	}
 
 	// RequestPayload represents the request payload for anki connect api.
@@ -38,6 +72,14 @@ type (
 		Action  string `json:"action,omitempty"`
 		Version int    `json:"version,omitempty"`
 		Params  *P     `json:"params,omitempty"`
+		Key     string `json:"key,omitempty"`
+	}
+
+	// ResultRequestPermission represents the ankiconnect API result of a permission request.
+	ResultRequestPermission struct {
+		Permission    string `json:"permission,omitempty"`
+		RequireApiKey bool   `json:"requireApikey,omitempty"`
+		Version       int    `json:"version,omitempty"`
 	}
 
 	// ParamsDefault represent the default parameters for a action to be executed by the anki connect api.
@@ -57,7 +99,8 @@ func NewClient() *Client {
 	c := &Client{
 		Url:        ankiConnectUrl,
 		Version:    ankiConnectVersion,
-		httpClient: resty.New(),
+		httpClient: resty.New().SetDisableWarn(true),
+		logger:     slog.New(discardHandler{}),
 	}
 
 	c.Decks = &decksManager{Client: c}
@@ -66,28 +109,45 @@ func NewClient() *Client {
 	c.Cards = &cardsManager{Client: c}
 	c.Media = &mediaManager{Client: c}
 	c.Models = &modelsManager{Client: c}
+	c.Packages = &packagesManager{Client: c}
+	c.Collection = &collectionManager{Client: c}
+	c.GUI = &guiManager{Client: c}
 
 	return c
 }
 
 // SetHTTPClient can be used set a custom httpClient.
 func (c *Client) SetHTTPClient(httpClient *resty.Client) *Client {
-	c.httpClient = httpClient
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.httpClient = httpClient.SetDisableWarn(true)
 	return c
 }
 
 // SetURL can be used to set a custom url for the ankiconnect api.
 func (c *Client) SetURL(url string) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.Url = url
 	return c
 }
 
 // SetVersion can be used to set a custom version for the ankiconnect api.
 func (c *Client) SetVersion(version int) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.Version = version
 	return c
 }
 
+// SetAPIKey can be used to set the api key configured in the ankiconnect add-on.
+func (c *Client) SetAPIKey(key string) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.APIKey = key
+	return c
+}
+
 // SetDecksManager can be used to set a custom DecksManager interface.
 // This function is added for testing the DecksManager interface.
 func (c *Client) SetDecksManager(dm DecksManager) *Client {
@@ -110,58 +170,158 @@ func (c *Client) SetSyncManager(sm SyncManager) *Client {
 }
 
 // request formats and returns a base http request that can be extended later.
-// as part of this the baseUrl and the default headers are set in the http client.
-func (c *Client) request() *resty.Request {
-	c.httpClient.SetBaseURL(c.Url)
-	c.httpClient.SetDisableWarn(true)
+// as part of this the default headers are set. The request is sent to the url returned as second value.
+// The shared http client is not modified, so that requests can be created concurrently.
+func (c *Client) request() (*resty.Request, string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
 	return c.httpClient.R().SetHeader(httputils.ContentTypeHeaderKey, httputils.ApplicationJsonMIMEType).
-		SetHeader(httputils.AcceptHeaderKey, httputils.ApplicationJsonMIMEType)
+		SetHeader(httputils.AcceptHeaderKey, httputils.ApplicationJsonMIMEType), c.Url
+}
+
+// settings returns the version and the api key to be sent with a request.
+func (c *Client) settings() (int, string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Version, c.APIKey
 }
 
 // Ping checks if the anki connect api is online and healthy.
-// If there is no response from the anki connect api a error will be returned.
-func (c *Client) Ping() *errors.RestErr {
-	resp, err := c.request().Get("")
-	logger.RestyDebugLogs(resp)
+// If there is no response from the anki connect api a error will be returned
+// that matches ErrAnkiUnreachable.
+func (c *Client) Ping() error {
+	start := time.Now()
+	req, url := c.request()
+	resp, err := req.Get(url)
 	if err != nil {
-		return &errors.RestErr{
+		c.logger.Warn("ankiconnect ping", slog.Duration("duration", time.Since(start)), slog.String("error", err.Error()))
+		return &Error{
 			Message:    ankiConnectPingErrMsg,
 			StatusCode: http.StatusServiceUnavailable,
-			Error:      http.StatusText(http.StatusServiceUnavailable),
+			Kind:       ErrAnkiUnreachable,
+			Err:        err,
 		}
 	}
-	logger.Info("ping: " + string(resp.Body()))
+	c.logger.Debug("ankiconnect ping",
+		slog.Duration("duration", time.Since(start)),
+		slog.Int("status", resp.StatusCode()),
+		slog.String("response", string(resp.Body())))
 	return nil
 }
 
+// RequestPermission requests permission to use the api for the origin of the client.
+// If the origin is not trusted by ankiconnect a dialog is shown in Anki asking the user to grant access.
+// The result contains the permission level and, if the permission was granted, whether an api key
+// is required and the version of ankiconnect.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+func (c *Client) RequestPermission() (*ResultRequestPermission, error) {
+	return post[ResultRequestPermission, ParamsDefault](c, ActionRequestPermission, nil)
+}
+
+// Granted reports whether the permission to use the api was granted.
+func (r *ResultRequestPermission) Granted() bool {
+	return r.Permission == PermissionGranted
+}
+
 // post makes a POST request to the anki connect API with a payload of the action to be executed.
 // The Params field in the RequestPayload struct and the Result filed of the Result struct can be different
 // based on the action that needs to be executed. Hence post is defined with some generic types.
 // R any - represents the type of the result that will be returned by the API.
 // P any - represents the type of the params that will be sent along with the action to be executed to the API.
-func post[R any, P any](c *Client, action string, params *P) (*R, *errors.RestErr) {
-	payload := RequestPayload[P]{
-		Action:  action,
-		Version: c.Version,
-		Params:  params,
+// The call is instrumented and passed through the interceptors of the client before it is sent.
+// The returned error is a *Error carrying the action and the message returned by the API.
+func post[R any, P any](c *Client, action string, params *P) (*R, error) {
+	call := &Call{
+		Action: action,
+		Params: params,
 	}
-	result := new(Result[R])
-	resp, err := c.request().SetBody(payload).SetResult(result).Post("")
-	logger.RestyDebugLogs(resp)
+	err := c.instrument(call, func() error {
+		return c.intercept(call, func(call *Call) error {
+			return send[R, P](c, call)
+		})
+	})
 	if err != nil {
-		return nil, &errors.RestErr{
-			Message:    http.StatusText(http.StatusInternalServerError),
+		return nil, err
+	}
+	result, ok := call.Result.(*R)
+	if !ok {
+		return nil, &Error{
+			Action:     call.Action,
+			Message:    fmt.Sprintf(invalidResultErrMsg, call.Result),
 			StatusCode: http.StatusInternalServerError,
-			Error:      err.Error(),
 		}
 	}
-	if result.Error != "" {
-		return nil, &errors.RestErr{
-			Message:    result.Error,
+	return result, nil
+}
+
+// send sends the call to the anki connect API and sets the payload, the response and the result of the call.
+func send[R any, P any](c *Client, call *Call) error {
+	var params *P
+	if call.Params != nil {
+		var ok bool
+		if params, ok = call.Params.(*P); !ok {
+			return &Error{
+				Action:     call.Action,
+				Message:    fmt.Sprintf(invalidParamsErrMsg, call.Params),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+	}
+	if err := c.checkSupported(call.Action, params); err != nil {
+		return err
+	}
+	version, key := c.settings()
+	payload := RequestPayload[P]{
+		Action:  call.Action,
+		Version: version,
+		Params:  params,
+		Key:     key,
+	}
+	if m, ok := any(params).(*ParamsMulti); ok && m != nil {
+		payload.Params = any(prepareMulti(m, version, key)).(*P)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return &Error{
+			Action:     call.Action,
+			Message:    err.Error(),
 			StatusCode: http.StatusBadRequest,
-			Error:      result.Error,
+			Err:        err,
 		}
 	}
-	return &result.Result, nil
+	call.Payload = body
+
+	start := time.Now()
+	status := 0
+	var result *Result[R]
+	err = c.do(call.Action, params, func() error {
+		result = new(Result[R])
+		if c.cassette != nil && c.cassette.mode == CassetteReplay {
+			return c.cassette.replay(call.Action, params, result)
+		}
+		req, url := c.request()
+		resp, err := req.SetBody(body).SetResult(result).Post(url)
+		if err != nil {
+			return newTransportError(call.Action, err)
+		}
+		status = resp.StatusCode()
+		call.Response = resp.Body()
+		if c.cassette != nil {
+			c.cassette.record(call.Action, params, resp.Body())
+		}
+		return nil
+	})
+	call.Latency = time.Since(start)
+	if err == nil && result.Error != "" {
+		err = newAPIError(call.Action, result.Error)
+	}
+	c.logCall(call, status, err)
+	if err != nil {
+		return err
+	}
+	call.Result = &result.Result
+	return nil
 }
@@ -63,16 +63,83 @@ func TestClient_Ping(t *testing.T) {
 		assert.NoError(t, err)
 		httpmock.RegisterResponder(http.MethodGet, ankiConnectUrl, responder)
 
-		restErr := client.Ping()
-		assert.Nil(t, restErr)
+		err = client.Ping()
+		assert.Nil(t, err)
 	})
 
 	t.Run("failure", func(t *testing.T) {
 		defer httpmock.Reset()
 
-		restErr := client.Ping()
-		assert.NotNil(t, restErr)
-		assert.Equal(t, http.StatusServiceUnavailable, restErr.StatusCode)
-		assert.Equal(t, ankiConnectPingErrMsg, restErr.Message)
+		err := client.Ping()
+		assert.ErrorIs(t, err, ErrAnkiUnreachable)
+		assertRestErr(t, err, http.StatusServiceUnavailable, ankiConnectPingErrMsg)
+	})
+}
+
+func TestClient_SetAPIKey(t *testing.T) {
+	c := NewClient().SetAPIKey("secret")
+	assert.Equal(t, "secret", c.APIKey)
+}
+
+func TestClient_APIKey(t *testing.T) {
+	defer httpmock.Reset()
+	defer client.SetAPIKey("")
+
+	registerVerifiedPayload(t,
+		[]byte(`{"action": "deckNames", "version": 6, "key": "secret"}`),
+		[]byte(`{"result": ["Default"], "error": null}`))
+
+	_, err := client.SetAPIKey("secret").Decks.GetAll()
+	assert.NoError(t, err)
+}
+
+func TestClient_RequestPermission(t *testing.T) {
+	requestPermissionPayload := []byte(`{
+    "action": "requestPermission",
+    "version": 6
+}`)
+
+	t.Run("granted", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t, requestPermissionPayload, []byte(`{
+    "result": {
+        "permission": "granted",
+        "requireApikey": true,
+        "version": 6
+    },
+    "error": null
+}`))
+
+		result, err := client.RequestPermission()
+		assert.NoError(t, err)
+		assert.True(t, result.Granted())
+		assert.True(t, result.RequireApiKey)
+		assert.Equal(t, 6, result.Version)
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t, requestPermissionPayload, []byte(`{
+    "result": {
+        "permission": "denied"
+    },
+    "error": null
+}`))
+
+		result, err := client.RequestPermission()
+		assert.NoError(t, err)
+		assert.False(t, result.Granted())
+		assert.Equal(t, PermissionDenied, result.Permission)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerErrorResponse(t)
+
+		_, err := client.RequestPermission()
+		assertRestErr(t, err, http.StatusBadRequest, "some error message")
 	})
 }
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/atselvan/ankiconnect"
+	"github.com/atselvan/ankiconnect/exporter"
+)
+
+// runExport exports the notes or cards matching a query to stdout or a file.
+// The output file is written to a temporary file in the same directory that replaces it only when
+// the export succeeded, so that a failed export does not truncate an existing file.
+func runExport(client *ankiconnect.Client, args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("export", "[flags] <query>", stderr)
+	var opts exporter.Options
+	cards := fs.Bool("cards", false, "export cards with their scheduling values instead of notes")
+	format := fs.String("format", "", "output format: csv, json or ndjson, detected from the output file extension by default")
+	output := fs.String("o", "", "output file, defaults to stdout")
+	fs.BoolVar(&opts.StripHTML, "strip-html", false, "convert the html of the fields to text")
+	media := fs.Bool("media", false, "download the referenced media files into a folder next to the output file")
+	fs.StringVar(&opts.MediaDir, "media-dir", "", "download the referenced media files into the directory")
+	fs.BoolVar(&opts.SkipMissingMedia, "skip-missing-media", false, "skip referenced media files that do not exist instead of failing")
+	fs.IntVar(&opts.PageSize, "page-size", 500, "number of notes or cards fetched with a single request")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	opts.Format = exporter.Format(*format)
+	if opts.Format == "" {
+		opts.Format = exporter.FormatCSV
+		if ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(*output)), "."); ext == "json" || ext == "ndjson" {
+			opts.Format = exporter.Format(ext)
+		}
+	}
+	if err := opts.Format.Validate(); err != nil {
+		return err
+	}
+	if *media && opts.MediaDir == "" {
+		if *output == "" {
+			return errors.New("-media requires -o or -media-dir")
+		}
+		opts.MediaDir = strings.TrimSuffix(*output, filepath.Ext(*output)) + "_media"
+	}
+
+	w := stdout
+	var file *os.File
+	if *output != "" {
+		var err error
+		if file, err = os.CreateTemp(filepath.Dir(*output), "."+filepath.Base(*output)+".*"); err != nil {
+			return err
+		}
+		defer os.Remove(file.Name())
+		defer file.Close()
+		w = file
+	}
+
+	export, kind := exporter.ExportNotes, "notes"
+	if *cards {
+		export, kind = exporter.ExportCards, "cards"
+	}
+	n, err := export(client, fs.Arg(0), w, opts)
+	if err != nil {
+		return err
+	}
+	if file != nil {
+		if err := file.Chmod(0o644); err != nil {
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+		if err := os.Rename(file.Name(), *output); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(stderr, "exported %d %s\n", n, kind)
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/atselvan/ankiconnect"
+	"github.com/atselvan/ankiconnect/importer"
+)
+
+var duplicateModes = map[string]importer.DuplicateMode{
+	"skip":   importer.DuplicatesSkip,
+	"update": importer.DuplicatesUpdate,
+	"allow":  importer.DuplicatesAllow,
+}
+
+// runImport imports a CSV or TSV file and writes the report as json to stdout.
+func runImport(client *ankiconnect.Client, args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("import", "[flags] <file>", stderr)
+	opts := importer.Options{
+		Fields: map[string]string{},
+		Media:  map[string]string{},
+	}
+	var tags listFlag
+	fs.StringVar(&opts.Deck, "deck", "Default", "deck of the notes")
+	fs.StringVar(&opts.Model, "model", "Basic", "model (note type) of the notes")
+	delimiter := fs.String("delimiter", "", `column separator, "tab" or a single character, detected from the file extension by default`)
+	fs.BoolVar(&opts.NoHeader, "no-header", false, "the first row contains data instead of the column names")
+	fs.Var(mapFlag(opts.Fields), "field", "maps a model field to a column name or 1-based index, e.g. -field Front=word (repeatable)")
+	fs.StringVar(&opts.DeckColumn, "deck-column", "", "column containing the deck of the row")
+	fs.StringVar(&opts.TagsColumn, "tags-column", "", "column containing the space separated tags of the row")
+	fs.Var(&tags, "tag", "tag added to every note (repeatable)")
+	fs.Var(mapFlag(opts.Media), "media", "maps a column containing media file names to a field, e.g. -media image=Back (repeatable)")
+	fs.StringVar(&opts.MediaDir, "media-dir", "", "directory of the media files, defaults to the directory of the file")
+	duplicates := fs.String("duplicates", "skip", "handling of rows matching existing notes: skip, update or allow")
+	fs.IntVar(&opts.BatchSize, "batch-size", 100, "number of rows imported with a single request")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	mode, ok := duplicateModes[*duplicates]
+	if !ok {
+		return fmt.Errorf("invalid duplicates mode %q", *duplicates)
+	}
+	opts.Duplicates = mode
+	opts.Tags = tags
+	if len(opts.Fields) == 0 {
+		opts.Fields = nil
+	}
+	switch {
+	case *delimiter == "tab" || *delimiter == `\t`:
+		opts.Comma = '\t'
+	case len([]rune(*delimiter)) == 1:
+		opts.Comma = []rune(*delimiter)[0]
+	case *delimiter != "":
+		return fmt.Errorf("invalid delimiter %q", *delimiter)
+	}
+
+	report, err := importer.ImportFile(client, fs.Arg(0), opts)
+	if report != nil {
+		if werr := writeJSON(stdout, report); err == nil {
+			err = werr
+		}
+	}
+	return err
+}
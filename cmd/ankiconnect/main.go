@@ -0,0 +1,149 @@
+// Command ankiconnect runs bulk operations against the ankiconnect api of a running Anki.
+//
+// Usage:
+//
+//	ankiconnect [-url url] [-key key] <command> [flags] [args]
+//
+// The commands are:
+//
+//	import    import notes from a CSV or TSV file
+//	export    export notes or cards to CSV, JSON or NDJSON
+//	markdown  compile Markdown files to notes
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/atselvan/ankiconnect"
+)
+
+type (
+	// command is a subcommand of the cli.
+	command struct {
+		summary string
+		run     func(client *ankiconnect.Client, args []string, stdout, stderr io.Writer) error
+	}
+
+	// mapFlag is a repeatable flag of key=value pairs.
+	mapFlag map[string]string
+
+	// listFlag is a repeatable flag.
+	listFlag []string
+)
+
+// errUsage is returned by commands when the usage has been printed.
+var errUsage = errors.New("usage")
+
+var commands = map[string]command{
+	"import":   {summary: "import notes from a CSV or TSV file", run: runImport},
+	"export":   {summary: "export notes or cards to CSV, JSON or NDJSON", run: runExport},
+	"markdown": {summary: "compile Markdown files to notes", run: runMarkdown},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command line and returns the exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("ankiconnect", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	url := fs.String("url", "http://localhost:8765", "url of the ankiconnect api")
+	key := fs.String("key", os.Getenv("ANKICONNECT_API_KEY"), "api key, defaults to $ANKICONNECT_API_KEY")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: ankiconnect [flags] <command> [command flags] [args]\n\ncommands:\n")
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(stderr, "  %-10s%s\n", name, commands[name].summary)
+		}
+		fmt.Fprintf(stderr, "\nflags:\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitCode(err)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "ankiconnect: unknown command %q\n", fs.Arg(0))
+		fs.Usage()
+		return 2
+	}
+
+	client := ankiconnect.NewClient().SetURL(*url).SetAPIKey(*key)
+	if err := cmd.run(client, fs.Args()[1:], stdout, stderr); err != nil {
+		if errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp) {
+			return exitCode(err)
+		}
+		fmt.Fprintf(stderr, "ankiconnect: %s: %v\n", fs.Arg(0), err)
+		return 1
+	}
+	return 0
+}
+
+// exitCode returns the exit code for a usage error, help requests exit successfully.
+func exitCode(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	return 2
+}
+
+// newFlagSet returns the flag set of a command, printing the usage and errors to stderr.
+func newFlagSet(name, usage string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: ankiconnect %s %s\n\nflags:\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// writeJSON writes v as indented json.
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (m mapFlag) String() string {
+	pairs := make([]string, 0, len(m))
+	for k, v := range m {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (m mapFlag) Set(value string) error {
+	k, v, ok := strings.Cut(value, "=")
+	if !ok || k == "" || v == "" {
+		return fmt.Errorf("%q is not of the form name=value", value)
+	}
+	m[k] = v
+	return nil
+}
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
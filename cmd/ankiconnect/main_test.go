@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/atselvan/ankiconnect"
+	"github.com/atselvan/ankiconnect/ankiconnecttest"
+	"github.com/atselvan/ankiconnect/exporter"
+	"github.com/atselvan/ankiconnect/importer"
+	"github.com/atselvan/ankiconnect/markdown"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	t.Run("usage", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		assert.Equal(t, 2, run(nil, &stdout, &stderr))
+		assert.Contains(t, stderr.String(), "import")
+
+		stderr.Reset()
+		assert.Equal(t, 2, run([]string{"unknown"}, &stdout, &stderr))
+		assert.Contains(t, stderr.String(), `unknown command "unknown"`)
+	})
+
+	t.Run("import", func(t *testing.T) {
+		server := ankiconnecttest.NewServer()
+		defer server.Close()
+
+		path := filepath.Join(t.TempDir(), "vocab.tsv")
+		assert.NoError(t, os.WriteFile(path, []byte("word\tmeaning\ntaberu\tto eat\n"), 0o600))
+
+		var stdout, stderr bytes.Buffer
+		code := run([]string{
+			"-url", server.URL, "import",
+			"-deck", "Japanese",
+			"-field", "Front=word", "-field", "Back=2",
+			"-tag", "imported",
+			"-duplicates", "update",
+			path,
+		}, &stdout, &stderr)
+		assert.Equal(t, 0, code, stderr.String())
+
+		var report importer.Report
+		assert.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+		assert.Equal(t, 1, report.Created)
+		note, ok := server.Note(report.Rows[0].NoteId)
+		assert.True(t, ok)
+		assert.Equal(t, "to eat", note.Fields["Back"])
+		assert.Equal(t, []string{"imported"}, note.Tags)
+	})
+
+	t.Run("import error", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"import", "-duplicates", "never", "file.csv"}, &stdout, &stderr)
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr.String(), `invalid duplicates mode "never"`)
+
+		stderr.Reset()
+		assert.Equal(t, 2, run([]string{"import"}, &stdout, &stderr))
+		assert.Contains(t, stderr.String(), "usage: ankiconnect import")
+	})
+
+	t.Run("export error", func(t *testing.T) {
+		server := ankiconnecttest.NewServer()
+		defer server.Close()
+
+		dir := t.TempDir()
+		path := filepath.Join(dir, "notes.csv")
+		assert.NoError(t, os.WriteFile(path, []byte("previous export"), 0o600))
+
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"-url", server.URL, "export", "-format", "xml", "-o", path, "deck:Default"}, &stdout, &stderr)
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr.String(), `unsupported format "xml"`)
+
+		server.FailAction(ankiconnect.ActionFindNotes, "collection is not available")
+		code = run([]string{"-url", server.URL, "export", "-o", path, "deck:Default"}, &stdout, &stderr)
+		assert.Equal(t, 1, code)
+
+		content, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, "previous export", string(content))
+		entries, err := os.ReadDir(dir)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+
+	t.Run("export", func(t *testing.T) {
+		server := ankiconnecttest.NewServer()
+		defer server.Close()
+		_, err := server.AddNote(ankiconnect.Note{
+			DeckName:  "Default",
+			ModelName: "Basic",
+			Fields:    ankiconnect.Fields{"Front": "neko", "Back": `<img src="cat.png">`},
+		})
+		assert.NoError(t, err)
+		_, err = server.Client().Media.StoreMediaFile("cat.png", "cG5n")
+		assert.NoError(t, err)
+
+		path := filepath.Join(t.TempDir(), "notes.json")
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"-url", server.URL, "export", "-o", path, "-media", "deck:Default"}, &stdout, &stderr)
+		assert.Equal(t, 0, code, stderr.String())
+		assert.Equal(t, "exported 1 notes\n", stderr.String())
+
+		var notes []exporter.Note
+		content, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.NoError(t, json.Unmarshal(content, &notes))
+		assert.Len(t, notes, 1)
+		_, err = os.Stat(filepath.Join(filepath.Dir(path), "notes_media", "cat.png"))
+		assert.NoError(t, err)
+
+		stdout.Reset()
+		code = run([]string{"-url", server.URL, "export", "-cards", "-format", "ndjson", "deck:Default"}, &stdout, &stderr)
+		assert.Equal(t, 0, code, stderr.String())
+		assert.Contains(t, stdout.String(), `"interval":0`)
+	})
+
+	t.Run("markdown", func(t *testing.T) {
+		server := ankiconnecttest.NewServer()
+		defer server.Close()
+
+		path := filepath.Join(t.TempDir(), "go.md")
+		assert.NoError(t, os.WriteFile(path, []byte("Q: What is a goroutine?\nA: A lightweight thread.\n"), 0o600))
+
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"-url", server.URL, "markdown", "-deck", "Go", "-tag", "md", path}, &stdout, &stderr)
+		assert.Equal(t, 0, code, stderr.String())
+
+		var reports map[string]markdown.Report
+		assert.NoError(t, json.Unmarshal(stdout.Bytes(), &reports))
+		assert.Equal(t, 1, reports[path].Created)
+		note, ok := server.Note(reports[path].Notes[0].NoteId)
+		assert.True(t, ok)
+		assert.Equal(t, "<p>A lightweight thread.</p>", note.Fields["Back"])
+		assert.Contains(t, note.Tags, "md")
+	})
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"io"
+
+	"github.com/atselvan/ankiconnect"
+	"github.com/atselvan/ankiconnect/markdown"
+)
+
+// runMarkdown compiles Markdown files to notes and writes the reports as json to stdout, by file.
+func runMarkdown(client *ankiconnect.Client, args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("markdown", "[flags] <file>...", stderr)
+	var opts markdown.Options
+	var tags listFlag
+	fs.StringVar(&opts.Deck, "deck", "", "deck of the new notes, unless set by the front matter")
+	fs.StringVar(&opts.Model, "model", "", `model of the notes, unless set by the front matter (default "Basic")`)
+	fs.StringVar(&opts.ClozeModel, "cloze-model", "", `model of the cloze notes, unless set by the front matter (default "Cloze")`)
+	fs.Var(&tags, "tag", "tag added to the new notes (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+	opts.Tags = tags
+
+	reports := map[string]*markdown.Report{}
+	var err error
+	for _, path := range fs.Args() {
+		var report *markdown.Report
+		report, err = markdown.CompileFile(client, path, opts)
+		if err != nil {
+			break
+		}
+		reports[path] = report
+	}
+	if werr := writeJSON(stdout, reports); err == nil {
+		err = werr
+	}
+	return err
+}
@@ -0,0 +1,166 @@
+package ankiconnect
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+const (
+	ActionGetProfiles              = "getProfiles"
+	ActionGetActiveProfile         = "getActiveProfile"
+	ActionLoadProfile              = "loadProfile"
+	ActionReloadCollection         = "reloadCollection"
+	ActionGuiCheckDatabase         = "guiCheckDatabase"
+	ActionGetNumCardsReviewedToday = "getNumCardsReviewedToday"
+	ActionGetNumCardsReviewedByDay = "getNumCardsReviewedByDay"
+	ActionGetCollectionStatsHTML   = "getCollectionStatsHTML"
+
+	profileNotFoundErrMsg = "profile was not found: %s"
+)
+
+type (
+	// CollectionManager describes the interface that can be used to perform operations on the
+	// collection and the profiles of Anki.
+	CollectionManager interface {
+		GetProfiles() (*[]string, error)
+		GetActiveProfile() (*string, error)
+		LoadProfile(name string) error
+		Reload() error
+		CheckDatabase() error
+		GetNumCardsReviewedToday() (*int, error)
+		GetNumCardsReviewedByDay() (*[]ReviewsByDay, error)
+		GetStatsHTML(wholeCollection bool) (*string, error)
+	}
+
+	// ParamsLoadProfile represents the ankiconnect API params for loading a profile.
+	ParamsLoadProfile struct {
+		Name string `json:"name,omitempty"`
+	}
+
+	// ParamsCollectionStatsHTML represents the ankiconnect API params for getting the statistics of the collection.
+	ParamsCollectionStatsHTML struct {
+		WholeCollection bool `json:"wholeCollection"`
+	}
+
+	// ReviewsByDay is the number of cards reviewed on a day.
+	// ankiconnect returns it as a ["2021-02-28", 124] pair.
+	ReviewsByDay struct {
+		// Date is the day in the format 2006-01-02.
+		Date  string
+		Count int
+	}
+
+	// collectionManager implements CollectionManager.
+	collectionManager struct {
+		Client *Client
+	}
+)
+
+// UnmarshalJSON decodes a [date, count] pair.
+func (r *ReviewsByDay) UnmarshalJSON(data []byte) error {
+	var pair []json.RawMessage
+	if err := json.Unmarshal(data, &pair); err != nil {
+		return err
+	}
+	if len(pair) != 2 {
+		return fmt.Errorf("ankiconnect: invalid reviews by day %s", data)
+	}
+	if err := json.Unmarshal(pair[0], &r.Date); err != nil {
+		return err
+	}
+	return json.Unmarshal(pair[1], &r.Count)
+}
+
+// MarshalJSON encodes the reviews as a [date, count] pair, like ankiconnect.
+func (r ReviewsByDay) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{r.Date, r.Count})
+}
+
+// GetProfiles retrieves the names of the profiles of Anki.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+func (cm *collectionManager) GetProfiles() (*[]string, error) {
+	return post[[]string, ParamsDefault](cm.Client, ActionGetProfiles, nil)
+}
+
+// GetActiveProfile retrieves the name of the profile that is currently loaded.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+func (cm *collectionManager) GetActiveProfile() (*string, error) {
+	return post[string, ParamsDefault](cm.Client, ActionGetActiveProfile, nil)
+}
+
+// LoadProfile switches Anki to the profile with the name. Loading the active profile does nothing.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+//   - the profile does not exist, the error wraps ErrProfileNotFound.
+func (cm *collectionManager) LoadProfile(name string) error {
+	params := ParamsLoadProfile{
+		Name: name,
+	}
+	loaded, err := post[bool](cm.Client, ActionLoadProfile, &params)
+	if err != nil {
+		return err
+	}
+	if !*loaded {
+		// ankiconnect returns false instead of an error if the profile does not exist
+		return &Error{
+			Action:     ActionLoadProfile,
+			Message:    fmt.Sprintf(profileNotFoundErrMsg, name),
+			StatusCode: http.StatusNotFound,
+			Kind:       ErrProfileNotFound,
+		}
+	}
+	return nil
+}
+
+// Reload reloads the collection from the disk, e.g. after it was changed by another program.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+func (cm *collectionManager) Reload() error {
+	_, err := post[interface{}, ParamsDefault](cm.Client, ActionReloadCollection, nil)
+	return err
+}
+
+// CheckDatabase runs the "Check Database" tool of Anki. The check runs in the background,
+// the method returns when it has been started.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+func (cm *collectionManager) CheckDatabase() error {
+	_, err := post[bool, ParamsDefault](cm.Client, ActionGuiCheckDatabase, nil)
+	return err
+}
+
+// GetNumCardsReviewedToday retrieves the number of cards reviewed today.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+func (cm *collectionManager) GetNumCardsReviewedToday() (*int, error) {
+	return post[int, ParamsDefault](cm.Client, ActionGetNumCardsReviewedToday, nil)
+}
+
+// GetNumCardsReviewedByDay retrieves the number of cards reviewed on each day with reviews, newest first.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+func (cm *collectionManager) GetNumCardsReviewedByDay() (*[]ReviewsByDay, error) {
+	return post[[]ReviewsByDay, ParamsDefault](cm.Client, ActionGetNumCardsReviewedByDay, nil)
+}
+
+// GetStatsHTML retrieves the statistics page of Anki as html, for the whole collection or
+// for the current deck.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+func (cm *collectionManager) GetStatsHTML(wholeCollection bool) (*string, error) {
+	params := ParamsCollectionStatsHTML{
+		WholeCollection: wholeCollection,
+	}
+	return post[string](cm.Client, ActionGetCollectionStatsHTML, &params)
+}
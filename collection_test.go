@@ -0,0 +1,157 @@
+package ankiconnect
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectionManager_Profiles(t *testing.T) {
+	t.Run("get profiles", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t,
+			[]byte(`{"action": "getProfiles", "version": 6}`),
+			[]byte(`{"result": ["Alice", "Bob"], "error": null}`))
+
+		profiles, err := client.Collection.GetProfiles()
+		assert.Nil(t, err)
+		assert.Equal(t, &[]string{"Alice", "Bob"}, profiles)
+	})
+
+	t.Run("get active profile", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t,
+			[]byte(`{"action": "getActiveProfile", "version": 6}`),
+			[]byte(`{"result": "Alice", "error": null}`))
+
+		profile, err := client.Collection.GetActiveProfile()
+		assert.Nil(t, err)
+		assert.Equal(t, "Alice", *profile)
+	})
+
+	loadRequest := []byte(`{"action": "loadProfile", "version": 6, "params": {"name": "Bob"}}`)
+
+	t.Run("load profile", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t, loadRequest, []byte(`{"result": true, "error": null}`))
+
+		err := client.Collection.LoadProfile("Bob")
+		assert.Nil(t, err)
+	})
+
+	t.Run("load unknown profile", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t, loadRequest, []byte(`{"result": false, "error": null}`))
+
+		err := client.Collection.LoadProfile("Bob")
+		assertRestErr(t, err, http.StatusNotFound, "profile was not found: Bob")
+		assert.True(t, errors.Is(err, ErrProfileNotFound))
+		assert.Equal(t, "profile_not_found", ErrorKind(err))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerErrorResponse(t)
+
+		profiles, err := client.Collection.GetProfiles()
+		assert.Nil(t, profiles)
+		assertRestErr(t, err, http.StatusBadRequest, "some error message")
+	})
+}
+
+func TestCollectionManager_Maintenance(t *testing.T) {
+	t.Run("reload", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t,
+			[]byte(`{"action": "reloadCollection", "version": 6}`),
+			[]byte(`{"result": null, "error": null}`))
+
+		err := client.Collection.Reload()
+		assert.Nil(t, err)
+	})
+
+	t.Run("check database", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t,
+			[]byte(`{"action": "guiCheckDatabase", "version": 6}`),
+			[]byte(`{"result": true, "error": null}`))
+
+		err := client.Collection.CheckDatabase()
+		assert.Nil(t, err)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerErrorResponse(t)
+
+		err := client.Collection.Reload()
+		assertRestErr(t, err, http.StatusBadRequest, "some error message")
+	})
+}
+
+func TestCollectionManager_Statistics(t *testing.T) {
+	t.Run("reviewed today", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t,
+			[]byte(`{"action": "getNumCardsReviewedToday", "version": 6}`),
+			[]byte(`{"result": 42, "error": null}`))
+
+		count, err := client.Collection.GetNumCardsReviewedToday()
+		assert.Nil(t, err)
+		assert.Equal(t, 42, *count)
+	})
+
+	t.Run("reviewed by day", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t,
+			[]byte(`{"action": "getNumCardsReviewedByDay", "version": 6}`),
+			[]byte(`{"result": [["2021-02-28", 124], ["2021-02-27", 261]], "error": null}`))
+
+		days, err := client.Collection.GetNumCardsReviewedByDay()
+		assert.Nil(t, err)
+		assert.Equal(t, &[]ReviewsByDay{{Date: "2021-02-28", Count: 124}, {Date: "2021-02-27", Count: 261}}, days)
+	})
+
+	t.Run("invalid reviews by day", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t,
+			[]byte(`{"action": "getNumCardsReviewedByDay", "version": 6}`),
+			[]byte(`{"result": [["2021-02-28"]], "error": null}`))
+
+		days, err := client.Collection.GetNumCardsReviewedByDay()
+		assert.Nil(t, days)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("stats html", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t,
+			[]byte(`{"action": "getCollectionStatsHTML", "version": 6, "params": {"wholeCollection": true}}`),
+			[]byte(`{"result": "<center>stats</center>", "error": null}`))
+
+		html, err := client.Collection.GetStatsHTML(true)
+		assert.Nil(t, err)
+		assert.Equal(t, "<center>stats</center>", *html)
+	})
+}
+
+func TestReviewsByDay_MarshalJSON(t *testing.T) {
+	data, err := ReviewsByDay{Date: "2021-02-28", Count: 124}.MarshalJSON()
+	assert.NoError(t, err)
+	assert.Equal(t, `["2021-02-28",124]`, string(data))
+}
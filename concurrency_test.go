@@ -0,0 +1,53 @@
+package ankiconnect_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/atselvan/ankiconnect"
+	"github.com/atselvan/ankiconnect/ankiconnecttest"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestClient_Concurrent uses a single client from many goroutines, it is meant to be run with -race.
+func TestClient_Concurrent(t *testing.T) {
+	server := ankiconnecttest.NewServer()
+	defer server.Close()
+
+	client := server.Client().
+		SetRetryPolicy(ankiconnect.DefaultRetryPolicy()).
+		SetCircuitBreaker(ankiconnect.NewCircuitBreaker(5, time.Second)).
+		SetConcurrencyLimit(4).
+		SetRateLimit(1000, 50)
+	_, err := client.Connect()
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			deck := fmt.Sprintf("Deck %d", i)
+			assert.NoError(t, client.Decks.Create(deck))
+			err := client.Notes.Add(ankiconnect.Note{
+				DeckName:  deck,
+				ModelName: "Basic",
+				Fields:    ankiconnect.Fields{"Front": deck, "Back": "back"},
+			})
+			assert.NoError(t, err)
+			_, err = client.Decks.GetAll()
+			assert.NoError(t, err)
+			if i%5 == 0 {
+				_, err = client.Connect()
+				assert.NoError(t, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	decks, err := client.Decks.GetAll()
+	assert.NoError(t, err)
+	assert.Len(t, *decks, 21)
+}
@@ -1,9 +1,5 @@
 package ankiconnect
 
-import (
-	"github.com/privatesquare/bkst-go-utils/utils/errors"
-)
-
 const (
 	ActionDeckNames    = "deckNames"
 	ActionCreateDeck   = "createDeck"
@@ -14,9 +10,9 @@ const (
 type (
 	// DecksManager describes the interface that can be used to perform operations on anki decks.
 	DecksManager interface {
-		GetAll() (*[]string, *errors.RestErr)
-		Create(name string) *errors.RestErr
-		Delete(name string) *errors.RestErr
+		GetAll() (*[]string, error)
+		Create(name string) error
+		Delete(name string) error
 	}
 
 	// ParamsCreateDeck represents the ankiconnect API params required for creating a new deck.
@@ -40,11 +36,11 @@ type (
 // The result is a slice of string with the names of the decks.
 // The method returns an error if:
 //   - the api request to ankiconnect fails.
-//   - the api returns a http error.
-func (dm *decksManager) GetAll() (*[]string, *errors.RestErr) {
-	result, restErr := post[[]string, ParamsDefault](dm.Client, ActionDeckNames, nil)
-	if restErr != nil {
-		return nil, restErr
+//   - the api returns an error.
+func (dm *decksManager) GetAll() (*[]string, error) {
+	result, err := post[[]string, ParamsDefault](dm.Client, ActionDeckNames, nil)
+	if err != nil {
+		return nil, err
 	}
 	return result, nil
 }
@@ -52,14 +48,14 @@ func (dm *decksManager) GetAll() (*[]string, *errors.RestErr) {
 // Create creates a new deck in Anki.
 // The method returns an error if:
 //   - the api request to ankiconnect fails.
-//   - the api returns a http error.
-func (dm *decksManager) Create(name string) *errors.RestErr {
+//   - the api returns an error.
+func (dm *decksManager) Create(name string) error {
 	params := ParamsCreateDeck{
 		Deck: name,
 	}
-	_, restErr := post[int64](dm.Client, ActionCreateDeck, &params)
-	if restErr != nil {
-		return restErr
+	_, err := post[int64](dm.Client, ActionCreateDeck, &params)
+	if err != nil {
+		return err
 	}
 	return nil
 }
@@ -67,15 +63,15 @@ func (dm *decksManager) Create(name string) *errors.RestErr {
 // Delete deletes a deck from Anki
 // The method returns an error if:
 //   - the api request to ankiconnect fails.
-//   - the api returns a http error.
-func (dm *decksManager) Delete(name string) *errors.RestErr {
+//   - the api returns an error.
+func (dm *decksManager) Delete(name string) error {
 	params := ParamsDeleteDecks{
 		Decks:    &[]string{name},
 		CardsToo: true,
 	}
-	_, restErr := post[string](dm.Client, ActionDeleteDecks, &params)
-	if restErr != nil {
-		return restErr
+	_, err := post[string](dm.Client, ActionDeleteDecks, &params)
+	if err != nil {
+		return err
 	}
 	return nil
 }
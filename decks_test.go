@@ -26,9 +26,9 @@ func TestDecksManager_GetAll(t *testing.T) {
 
 		registerVerifiedPayload(t, getAllRequest, getAllResult)
 
-		decks, restErr := client.Decks.GetAll()
+		decks, err := client.Decks.GetAll()
 		assert.NotNil(t, decks)
-		assert.Nil(t, restErr)
+		assert.Nil(t, err)
 		assert.Equal(t, 3, len(*decks))
 	})
 
@@ -37,21 +37,19 @@ func TestDecksManager_GetAll(t *testing.T) {
 
 		registerErrorResponse(t)
 
-		decks, restErr := client.Decks.GetAll()
+		decks, err := client.Decks.GetAll()
 		assert.Nil(t, decks)
-		assert.NotNil(t, restErr)
-		assert.Equal(t, http.StatusBadRequest, restErr.StatusCode)
-		assert.Equal(t, "some error message", restErr.Message)
+		assert.NotNil(t, err)
+		assertRestErr(t, err, http.StatusBadRequest, "some error message")
 	})
 
 	t.Run("http request error", func(t *testing.T) {
 		defer httpmock.Reset()
 
-		decks, restErr := client.Decks.GetAll()
+		decks, err := client.Decks.GetAll()
 		assert.Nil(t, decks)
-		assert.NotNil(t, restErr)
-		assert.Equal(t, http.StatusInternalServerError, restErr.StatusCode)
-		assert.Equal(t, "Internal Server Error", restErr.Message)
+		assert.NotNil(t, err)
+		assertRestErr(t, err, http.StatusInternalServerError, "Internal Server Error")
 	})
 }
 
@@ -73,8 +71,8 @@ func TestDecksManager_Create(t *testing.T) {
 
 		registerVerifiedPayload(t, createRequest, createResponse)
 
-		restErr := client.Decks.Create("Japanese::Tokyo")
-		assert.Nil(t, restErr)
+		err := client.Decks.Create("Japanese::Tokyo")
+		assert.Nil(t, err)
 	})
 
 	t.Run("error", func(t *testing.T) {
@@ -82,10 +80,9 @@ func TestDecksManager_Create(t *testing.T) {
 
 		registerErrorResponse(t)
 
-		restErr := client.Decks.Create("test")
-		assert.NotNil(t, restErr)
-		assert.Equal(t, http.StatusBadRequest, restErr.StatusCode)
-		assert.Equal(t, "some error message", restErr.Message)
+		err := client.Decks.Create("test")
+		assert.NotNil(t, err)
+		assertRestErr(t, err, http.StatusBadRequest, "some error message")
 	})
 }
 
@@ -104,8 +101,8 @@ func TestDecksManagerDelete(t *testing.T) {
 
 		registerVerifiedPayload(t, deleteDeckRequest, genericSuccessJson)
 
-		restErr := client.Decks.Delete("test")
-		assert.Nil(t, restErr)
+		err := client.Decks.Delete("test")
+		assert.Nil(t, err)
 	})
 
 	t.Run("error", func(t *testing.T) {
@@ -113,9 +110,8 @@ func TestDecksManagerDelete(t *testing.T) {
 
 		registerErrorResponse(t)
 
-		restErr := client.Decks.Delete("test")
-		assert.NotNil(t, restErr)
-		assert.Equal(t, http.StatusBadRequest, restErr.StatusCode)
-		assert.Equal(t, "some error message", restErr.Message)
+		err := client.Decks.Delete("test")
+		assert.NotNil(t, err)
+		assertRestErr(t, err, http.StatusBadRequest, "some error message")
 	})
 }
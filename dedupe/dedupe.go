@@ -0,0 +1,330 @@
+// Package dedupe finds notes of a model that are duplicates of each other and merges them.
+//
+// Anki only prevents exact duplicates of the first field when a note is added. Find groups the notes
+// whose key fields are equal after normalization, e.g. "<b>Taberu</b>" and "taberu ", into clusters,
+// and Merge keeps the note of each cluster with the most reviews and deletes the others:
+//
+//	clusters, err := dedupe.Find(client, dedupe.Options{Model: "Basic"})
+//	for _, c := range clusters {
+//		fmt.Println(c.Key, c.Keep().NoteId, len(c.Notes)-1)
+//	}
+//	report, err := dedupe.Merge(client, clusters, dedupe.Options{Model: "Basic", ConcatenateFields: true})
+package dedupe
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/atselvan/ankiconnect"
+	"github.com/atselvan/ankiconnect/internal/htmltext"
+	"github.com/atselvan/ankiconnect/query"
+)
+
+const (
+	defaultSeparator = "<br>"
+
+	noModelErrMsg      = "dedupe: the model is required"
+	noFieldsErrMsg     = "dedupe: model %s has no fields"
+	unknownFieldErrMsg = "dedupe: model %s has no field %s"
+)
+
+type (
+	// Options configures the search and the merge of duplicates.
+	Options struct {
+		// Model is the model (note type) of the notes, it is required.
+		Model string
+		// Query restricts the notes that are compared, e.g. to a deck. All notes of the model
+		// are compared across decks if it is empty.
+		Query string
+		// Fields are the fields whose values identify duplicates, the first field of the model if empty.
+		// Notes whose fields are all empty are never duplicates.
+		Fields []string
+		// Normalize converts a field value to the value that is compared, Normalize if nil.
+		Normalize func(string) string
+		// ConcatenateFields appends the values of the fields of the deleted notes that differ from the
+		// values of the kept note to its fields. Otherwise only the fields of the kept note are kept.
+		ConcatenateFields bool
+		// Separator separates the concatenated values, "<br>" if empty.
+		Separator string
+	}
+
+	// Cluster is a group of notes that are duplicates of each other.
+	Cluster struct {
+		// Key is the normalized value of the key fields.
+		Key string `json:"key"`
+		// Notes are the notes of the cluster, the note that is kept by Merge first: the note whose
+		// cards have the most reviews, or the oldest note if they have the same number of reviews.
+		Notes []Note `json:"notes"`
+	}
+
+	// Note is a note of a cluster.
+	Note struct {
+		ankiconnect.ResultNotesInfo
+		// Reviews is the number of reviews of the cards of the note.
+		Reviews int64 `json:"reviews"`
+	}
+
+	// Report describes the result of a merge.
+	Report struct {
+		// Kept are the ids of the notes that were kept, one per cluster.
+		Kept []int64 `json:"kept"`
+		// Updated are the ids of the kept notes whose fields were changed.
+		Updated []int64 `json:"updated"`
+		// Deleted are the ids of the notes that were deleted.
+		Deleted []int64 `json:"deleted"`
+	}
+)
+
+// Keep returns the note of the cluster that is kept by Merge.
+func (c Cluster) Keep() Note {
+	return c.Notes[0]
+}
+
+// Normalize returns the value that is compared to find duplicates: the html is converted to text,
+// the text is converted to the Unicode normalization form C and lower case, and sequences of white
+// space are replaced by a single space.
+func Normalize(s string) string {
+	s = norm.NFC.String(htmltext.Strip(s))
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
+
+// Find returns the clusters of duplicate notes of the model, sorted by key.
+// The notes are retrieved with paginated notesInfo requests, the reviews of the duplicate notes
+// with cardsInfo requests.
+// The method returns an error if:
+//   - the model is not set or a field is not a field of the model.
+//   - the query is invalid.
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+func Find(client *ankiconnect.Client, opts Options) ([]Cluster, error) {
+	if opts.Model == "" {
+		return nil, errors.New(noModelErrMsg)
+	}
+	fields, err := keyFields(client, opts)
+	if err != nil {
+		return nil, err
+	}
+	q, err := opts.query()
+	if err != nil {
+		return nil, err
+	}
+	normalize := opts.normalize()
+
+	groups := map[string][]Note{}
+	for info, err := range client.Notes.Iterate(q.String(), &ankiconnect.IterateOptions{Order: ankiconnect.SortAscending}) {
+		if err != nil {
+			return nil, err
+		}
+		values := make([]string, len(fields))
+		empty := true
+		for i, f := range fields {
+			values[i] = normalize(info.Fields[f].Value)
+			empty = empty && values[i] == ""
+		}
+		if empty {
+			continue
+		}
+		key := strings.Join(values, "\x1f")
+		groups[key] = append(groups[key], Note{ResultNotesInfo: info})
+	}
+
+	var clusters []Cluster
+	var ids []int64
+	for key, notes := range groups {
+		if len(notes) < 2 {
+			continue
+		}
+		clusters = append(clusters, Cluster{Key: key, Notes: notes})
+		for _, n := range notes {
+			ids = append(ids, n.NoteId)
+		}
+	}
+	if len(clusters) == 0 {
+		return nil, nil
+	}
+	reviews, err := countReviews(client, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range clusters {
+		for i := range c.Notes {
+			c.Notes[i].Reviews = reviews[c.Notes[i].NoteId]
+		}
+		sort.SliceStable(c.Notes, func(i, j int) bool {
+			if c.Notes[i].Reviews != c.Notes[j].Reviews {
+				return c.Notes[i].Reviews > c.Notes[j].Reviews
+			}
+			return c.Notes[i].NoteId < c.Notes[j].NoteId
+		})
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].Key < clusters[j].Key })
+	return clusters, nil
+}
+
+// Merge merges each cluster into its first note: the tags of the other notes are added to it,
+// with ConcatenateFields their differing field values are appended to its fields, and the other
+// notes are deleted. The fields are updated with a single multi request and the notes are deleted
+// with a single request.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error, the notes are not deleted if the kept notes could not be updated.
+func Merge(client *ankiconnect.Client, clusters []Cluster, opts Options) (*Report, error) {
+	report := &Report{}
+	var actions []ankiconnect.MultiAction
+	var deleted []int64
+	for _, c := range clusters {
+		if len(c.Notes) == 0 {
+			continue
+		}
+		keep := c.Keep()
+		report.Kept = append(report.Kept, keep.NoteId)
+		if len(c.Notes) < 2 {
+			continue
+		}
+		if tags := missingTags(c); len(tags) > 0 {
+			actions = append(actions, ankiconnect.MultiAction{
+				Action: ankiconnect.ActionAddTags,
+				Params: ankiconnect.ParamsAddTags{Notes: &[]int64{keep.NoteId}, Tags: strings.Join(tags, " ")},
+			})
+		}
+		if opts.ConcatenateFields {
+			if fields := opts.concatenate(c); len(fields) > 0 {
+				actions = append(actions, ankiconnect.MultiAction{
+					Action: ankiconnect.ActionUpdateNoteFields,
+					Params: ankiconnect.ParamsUpdateNote{Note: &ankiconnect.UpdateNote{Id: keep.NoteId, Fields: fields}},
+				})
+				report.Updated = append(report.Updated, keep.NoteId)
+			}
+		}
+		for _, n := range c.Notes[1:] {
+			deleted = append(deleted, n.NoteId)
+		}
+	}
+
+	if len(actions) > 0 {
+		results, err := client.Multi(actions...)
+		if err != nil {
+			return nil, err
+		}
+		for i, result := range *results {
+			if result.Error != "" {
+				return nil, fmt.Errorf("dedupe: %s: %s", actions[i].Action, result.Error)
+			}
+		}
+	}
+	if len(deleted) > 0 {
+		if err := client.Notes.Delete(deleted...); err != nil {
+			return nil, err
+		}
+	}
+	report.Deleted = deleted
+	return report, nil
+}
+
+// keyFields returns the fields identifying duplicates and checks that the model has them.
+func keyFields(client *ankiconnect.Client, opts Options) ([]string, error) {
+	modelFields, err := client.Models.GetFields(opts.Model)
+	if err != nil {
+		return nil, err
+	}
+	if len(*modelFields) == 0 {
+		return nil, fmt.Errorf(noFieldsErrMsg, opts.Model)
+	}
+	if len(opts.Fields) == 0 {
+		return (*modelFields)[:1], nil
+	}
+	for _, f := range opts.Fields {
+		found := false
+		for _, mf := range *modelFields {
+			found = found || mf == f
+		}
+		if !found {
+			return nil, fmt.Errorf(unknownFieldErrMsg, opts.Model, f)
+		}
+	}
+	return opts.Fields, nil
+}
+
+// countReviews returns the number of reviews of the cards of the notes by note id.
+func countReviews(client *ankiconnect.Client, ids []int64) (map[int64]int64, error) {
+	reviews := map[int64]int64{}
+	for card, err := range client.Cards.Iterate(query.NoteIDs(ids...).String(), nil) {
+		if err != nil {
+			return nil, err
+		}
+		reviews[card.Note] += card.Reps
+	}
+	return reviews, nil
+}
+
+// missingTags returns the tags of the other notes of the cluster that the kept note does not have.
+func missingTags(c Cluster) []string {
+	seen := map[string]bool{}
+	for _, t := range c.Keep().Tags {
+		seen[strings.ToLower(t)] = true
+	}
+	var tags []string
+	for _, n := range c.Notes[1:] {
+		for _, t := range n.Tags {
+			if !seen[strings.ToLower(t)] {
+				seen[strings.ToLower(t)] = true
+				tags = append(tags, t)
+			}
+		}
+	}
+	return tags
+}
+
+// concatenate returns the fields of the kept note that change when the differing values of the
+// other notes are appended.
+func (o Options) concatenate(c Cluster) ankiconnect.Fields {
+	normalize := o.normalize()
+	separator := o.Separator
+	if separator == "" {
+		separator = defaultSeparator
+	}
+	keep := c.Keep()
+	fields := ankiconnect.Fields{}
+	for name, data := range keep.Fields {
+		value := data.Value
+		seen := map[string]bool{normalize(value): true}
+		for _, n := range c.Notes[1:] {
+			other := n.Fields[name].Value
+			if key := normalize(other); key != "" && !seen[key] {
+				seen[key] = true
+				if value != "" {
+					value += separator
+				}
+				value += other
+			}
+		}
+		if value != data.Value {
+			fields[name] = value
+		}
+	}
+	return fields
+}
+
+func (o Options) normalize() func(string) string {
+	if o.Normalize != nil {
+		return o.Normalize
+	}
+	return Normalize
+}
+
+// query returns the search query of the notes that are compared.
+func (o Options) query() (query.Node, error) {
+	q := query.And{query.NoteType(o.Model)}
+	if o.Query != "" {
+		scope, err := query.Parse(o.Query)
+		if err != nil {
+			return nil, err
+		}
+		q = append(q, scope)
+	}
+	return q, nil
+}
@@ -0,0 +1,131 @@
+package dedupe
+
+import (
+	"testing"
+
+	"github.com/atselvan/ankiconnect"
+	"github.com/atselvan/ankiconnect/ankiconnecttest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"<b>Taberu</b>":            "taberu",
+		"  to  eat\n":              "to eat",
+		"café":                    "café",
+		"<div>a</div><div>b</div>": "a b",
+		"Tom &amp; Jerry":          "tom & jerry",
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, Normalize(in), in)
+	}
+}
+
+// addNote adds a note to the server, sets the reviews of its cards and returns its id.
+func addNote(t *testing.T, server *ankiconnecttest.Server, deck, front, back string, reviews int64, tags ...string) int64 {
+	id, err := server.AddNote(ankiconnect.Note{
+		DeckName:  deck,
+		ModelName: "Basic",
+		Fields:    ankiconnect.Fields{"Front": front, "Back": back},
+		Tags:      tags,
+		Options:   &ankiconnect.Options{AllowDuplicate: true},
+	})
+	assert.NoError(t, err)
+	note, _ := server.Note(id)
+	for _, card := range note.Cards {
+		assert.NoError(t, server.UpdateCard(card, func(c *ankiconnecttest.Card) { c.Reps = reviews }))
+	}
+	return id
+}
+
+func TestFindAndMerge(t *testing.T) {
+	server := ankiconnecttest.NewServer()
+	defer server.Close()
+	server.AddDeck("Japanese")
+	server.AddDeck("Verbs")
+	client := server.Client()
+
+	taberu := addNote(t, server, "Japanese", "taberu", "to eat", 2, "verb")
+	taberuBold := addNote(t, server, "Verbs", "<b>Taberu</b>", "eat", 5, "jlpt5")
+	taberuSpace := addNote(t, server, "Verbs", " taberu ", "to  eat", 0)
+	nomu := addNote(t, server, "Japanese", "nomu", "to drink", 1)
+	addNote(t, server, "Japanese", "miru", "to see", 0)
+	nomuAgain := addNote(t, server, "Japanese", "NOMU", "to drink", 1)
+
+	clusters, err := Find(client, Options{Model: "Basic"})
+	assert.NoError(t, err)
+	if !assert.Len(t, clusters, 2) {
+		return
+	}
+	assert.Equal(t, "nomu", clusters[0].Key)
+	// same reviews, the oldest note is kept
+	assert.Equal(t, []int64{nomu, nomuAgain}, []int64{clusters[0].Notes[0].NoteId, clusters[0].Notes[1].NoteId})
+	assert.Equal(t, "taberu", clusters[1].Key)
+	assert.Equal(t, taberuBold, clusters[1].Keep().NoteId)
+	assert.Equal(t, int64(5), clusters[1].Keep().Reviews)
+	assert.Equal(t, []int64{taberu, taberuSpace}, []int64{clusters[1].Notes[1].NoteId, clusters[1].Notes[2].NoteId})
+
+	report, err := Merge(client, clusters, Options{Model: "Basic", ConcatenateFields: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{nomu, taberuBold}, report.Kept)
+	assert.Equal(t, []int64{taberuBold}, report.Updated)
+	assert.ElementsMatch(t, []int64{nomuAgain, taberu, taberuSpace}, report.Deleted)
+
+	kept, ok := server.Note(taberuBold)
+	assert.True(t, ok)
+	assert.Equal(t, "<b>Taberu</b>", kept.Fields["Front"])
+	assert.Equal(t, "eat<br>to eat", kept.Fields["Back"])
+	assert.ElementsMatch(t, []string{"jlpt5", "verb"}, kept.Tags)
+	for _, id := range report.Deleted {
+		_, ok := server.Note(id)
+		assert.False(t, ok, "note %d", id)
+	}
+
+	clusters, err = Find(client, Options{Model: "Basic"})
+	assert.NoError(t, err)
+	assert.Empty(t, clusters)
+}
+
+func TestFindOptions(t *testing.T) {
+	server := ankiconnecttest.NewServer()
+	defer server.Close()
+	server.AddDeck("Japanese")
+	server.AddDeck("Verbs")
+	client := server.Client()
+
+	addNote(t, server, "Japanese", "taberu", "to eat", 0)
+	addNote(t, server, "Verbs", "taberu", "to eat", 0)
+	addNote(t, server, "Verbs", "Taberu", "to consume", 0)
+	addNote(t, server, "Verbs", "taberu", "to consume", 0)
+
+	t.Run("fields", func(t *testing.T) {
+		clusters, err := Find(client, Options{Model: "Basic", Fields: []string{"Front", "Back"}})
+		assert.NoError(t, err)
+		if assert.Len(t, clusters, 2) {
+			assert.Equal(t, "taberu\x1fto consume", clusters[0].Key)
+			assert.Len(t, clusters[0].Notes, 2)
+		}
+	})
+
+	t.Run("query and normalize", func(t *testing.T) {
+		clusters, err := Find(client, Options{
+			Model:     "Basic",
+			Query:     "deck:Verbs",
+			Normalize: func(s string) string { return s },
+		})
+		assert.NoError(t, err)
+		if assert.Len(t, clusters, 1) {
+			assert.Equal(t, "taberu", clusters[0].Key)
+			assert.Len(t, clusters[0].Notes, 2)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := Find(client, Options{})
+		assert.EqualError(t, err, noModelErrMsg)
+		_, err = Find(client, Options{Model: "Basic", Fields: []string{"Word"}})
+		assert.EqualError(t, err, "dedupe: model Basic has no field Word")
+		_, err = Find(client, Options{Model: "Basic", Query: "deck:("})
+		assert.Error(t, err)
+	})
+}
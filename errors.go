@@ -0,0 +1,191 @@
+package ankiconnect
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	resterrors "github.com/privatesquare/bkst-go-utils/utils/errors"
+)
+
+var (
+	// ErrDuplicateNote is returned when a note cannot be added because it is a duplicate.
+	ErrDuplicateNote = errors.New("duplicate note")
+	// ErrDeckNotFound is returned when an action refers to a deck that does not exist.
+	ErrDeckNotFound = errors.New("deck not found")
+	// ErrNoteNotFound is returned when an action refers to a note that does not exist.
+	ErrNoteNotFound = errors.New("note not found")
+	// ErrModelNotFound is returned when an action refers to a model (note type) that does not exist.
+	ErrModelNotFound = errors.New("model not found")
+	// ErrModelExists is returned when a model is created with a name that is already in use.
+	ErrModelExists = errors.New("model already exists")
+	// ErrAnkiUnreachable is returned when the ankiconnect api cannot be reached.
+	ErrAnkiUnreachable = errors.New("ankiconnect is unreachable")
+	// ErrCircuitOpen is returned without sending the request while the circuit breaker is open.
+	// It wraps ErrAnkiUnreachable.
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+	// ErrPermissionDenied is returned when ankiconnect rejects the request because of a missing
+	// or invalid api key or a denied origin.
+	ErrPermissionDenied = errors.New("permission denied")
+	// ErrUnsupportedVersion is returned when the installed ankiconnect add-on does not support
+	// the requested api version.
+	ErrUnsupportedVersion = errors.New("unsupported ankiconnect version")
+	// ErrUnsupportedAction is returned when the installed ankiconnect add-on does not support the action.
+	ErrUnsupportedAction = errors.New("unsupported action")
+	// ErrUnexpectedRequest is returned in replay mode when the cassette has no recorded interaction for a request.
+	ErrUnexpectedRequest = errors.New("unexpected request")
+	// ErrSyncLoginRequired is returned when Anki has no AnkiWeb credentials configured.
+	ErrSyncLoginRequired = errors.New(SyncErrLoginRequired)
+	// ErrFullSyncRequired is returned when AnkiWeb requires a one-way full sync
+	// that has to be confirmed by the user in the Anki GUI.
+	ErrFullSyncRequired = errors.New(SyncErrFullSyncRequired)
+	// ErrSyncTimeout is returned when waiting for a sync to finish took too long.
+	ErrSyncTimeout = errors.New(SyncErrTimeout)
+	// ErrFieldMismatch is returned when the fields of a note do not match the fields of its model.
+	ErrFieldMismatch = errors.New("note fields do not match the model fields")
+	// ErrProfileNotFound is returned when a profile that does not exist is loaded.
+	ErrProfileNotFound = errors.New("profile not found")
+	// ErrMediaNotFound is returned when a media file that does not exist is retrieved.
+	ErrMediaNotFound = errors.New("media file not found")
+	// ErrInvalidResponse is returned when ankiconnect answered but its response could not be decoded,
+	// e.g. because the result has an unexpected type. It is not retried.
+	ErrInvalidResponse = errors.New("invalid ankiconnect response")
+)
+
+// errorPatterns maps fragments of the error messages returned by ankiconnect to the sentinel errors.
+// The fragments are matched case-insensitively.
+var errorPatterns = []struct {
+	fragment string
+	kind     error
+}{
+	{"cannot create note because it is a duplicate", ErrDuplicateNote},
+	{"deck was not found", ErrDeckNotFound},
+	{"note was not found", ErrNoteNotFound},
+	{"model was not found", ErrModelNotFound},
+	{"model name already exists", ErrModelExists},
+	{"valid api key must be provided", ErrPermissionDenied},
+	{"permission denied", ErrPermissionDenied},
+	{"unsupported version", ErrUnsupportedVersion},
+	{"unsupported action", ErrUnsupportedAction},
+	{"auth not configured", ErrSyncLoginRequired},
+}
+
+// Error is the error returned by the client when an ankiconnect action fails.
+// Use errors.Is with one of the Err* sentinel values to check the reason of the failure,
+// or errors.As to access the action and the raw message returned by ankiconnect.
+type Error struct {
+	// Action is the ankiconnect action that failed.
+	Action string
+	// Message is the raw error message returned by ankiconnect or the http client.
+	Message string
+	// StatusCode is the http status code used when the error is converted to a RestErr.
+	StatusCode int
+	// Kind is the sentinel error describing the failure, it is nil if the failure is not known.
+	Kind error
+	// Err is the underlying error, e.g. the error returned by the http client.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	msg := e.Message
+	if msg == "" && e.Kind != nil {
+		msg = e.Kind.Error()
+	}
+	if e.Action == "" {
+		return "ankiconnect: " + msg
+	}
+	return "ankiconnect: " + e.Action + ": " + msg
+}
+
+// Is reports whether the error is of the given sentinel kind.
+func (e *Error) Is(target error) bool {
+	return e.Kind != nil && e.Kind == target
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// newAPIError returns the Error for a error message returned by ankiconnect.
+func newAPIError(action, message string) *Error {
+	return &Error{
+		Action:     action,
+		Message:    message,
+		StatusCode: http.StatusBadRequest,
+		Kind:       classifyErrorMessage(message),
+	}
+}
+
+// newTransportError returns the Error for a failed http request to ankiconnect.
+// Only connection failures match ErrAnkiUnreachable, other failures happen after ankiconnect
+// answered, e.g. when the response cannot be decoded, and match ErrInvalidResponse.
+func newTransportError(action string, err error) *Error {
+	var urlErr *url.Error
+	var netErr net.Error
+	if errors.As(err, &urlErr) || errors.As(err, &netErr) {
+		return &Error{
+			Action:     action,
+			Message:    err.Error(),
+			StatusCode: http.StatusInternalServerError,
+			Kind:       ErrAnkiUnreachable,
+			Err:        err,
+		}
+	}
+	return &Error{
+		Action:     action,
+		Message:    err.Error(),
+		StatusCode: http.StatusBadGateway,
+		Kind:       ErrInvalidResponse,
+		Err:        err,
+	}
+}
+
+// classifyErrorMessage returns the sentinel error matching a error message returned by ankiconnect.
+func classifyErrorMessage(message string) error {
+	lower := strings.ToLower(message)
+	for _, p := range errorPatterns {
+		if strings.Contains(lower, p.fragment) {
+			return p.kind
+		}
+	}
+	return nil
+}
+
+// ToRestErr converts an error returned by the client to a RestErr.
+// It is provided for callers that still rely on the RestErr type returned by earlier
+// versions of this library. The Message holds the ankiconnect error message and the
+// StatusCode matches the status code that was returned by earlier versions.
+func ToRestErr(err error) *resterrors.RestErr {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if !errors.As(err, &e) {
+		return &resterrors.RestErr{
+			Message:    err.Error(),
+			StatusCode: http.StatusInternalServerError,
+			Error:      http.StatusText(http.StatusInternalServerError),
+		}
+	}
+	restErr := &resterrors.RestErr{
+		Message:    e.Message,
+		StatusCode: e.StatusCode,
+		Error:      e.Message,
+	}
+	switch {
+	case restErr.StatusCode == 0:
+		restErr.StatusCode = http.StatusBadRequest
+	case restErr.StatusCode == http.StatusInternalServerError && e.Err != nil:
+		restErr.Message = http.StatusText(http.StatusInternalServerError)
+		restErr.Error = e.Err.Error()
+	case restErr.StatusCode != http.StatusBadRequest && e.Kind != nil:
+		restErr.Error = e.Kind.Error()
+	case restErr.StatusCode != http.StatusBadRequest:
+		restErr.Error = http.StatusText(restErr.StatusCode)
+	}
+	return restErr
+}
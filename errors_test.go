@@ -0,0 +1,76 @@
+package ankiconnect
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError_Classification(t *testing.T) {
+	tests := []struct {
+		message string
+		kind    error
+	}{
+		{"cannot create note because it is a duplicate", ErrDuplicateNote},
+		{"deck was not found: Japanese", ErrDeckNotFound},
+		{"model was not found: Basic-a39a1", ErrModelNotFound},
+		{"Model name already exists", ErrModelExists},
+		{"valid api key must be provided", ErrPermissionDenied},
+		{"unsupported version", ErrUnsupportedVersion},
+	}
+	for _, tt := range tests {
+		t.Run(tt.message, func(t *testing.T) {
+			err := newAPIError(ActionAddNote, tt.message)
+			assert.ErrorIs(t, err, tt.kind)
+			assert.Equal(t, "ankiconnect: addNote: "+tt.message, err.Error())
+		})
+	}
+
+	t.Run("unknown", func(t *testing.T) {
+		err := newAPIError(ActionAddNote, "some error message")
+		assert.Nil(t, err.Kind)
+		assert.False(t, errors.Is(err, ErrDuplicateNote))
+	})
+}
+
+func TestError_Returned(t *testing.T) {
+	t.Run("duplicate note", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t,
+			[]byte(`{"action": "addNote", "version": 6, "params": {"note": {"deckName": "test", "fields": {"Front": "front content"}}}}`),
+			[]byte(`{"result": null, "error": "cannot create note because it is a duplicate"}`))
+
+		err := client.Notes.Add(Note{DeckName: "test", Fields: Fields{"Front": "front content"}})
+		assert.ErrorIs(t, err, ErrDuplicateNote)
+
+		var apiErr *Error
+		assert.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, ActionAddNote, apiErr.Action)
+		assert.Equal(t, "cannot create note because it is a duplicate", apiErr.Message)
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		_, err := client.Decks.GetAll()
+		assert.ErrorIs(t, err, ErrAnkiUnreachable)
+		assert.NotNil(t, errors.Unwrap(err))
+	})
+}
+
+func TestToRestErr(t *testing.T) {
+	assert.Nil(t, ToRestErr(nil))
+
+	restErr := ToRestErr(newAPIError(ActionDeckNames, "some error message"))
+	assert.Equal(t, http.StatusBadRequest, restErr.StatusCode)
+	assert.Equal(t, "some error message", restErr.Message)
+	assert.Equal(t, "some error message", restErr.Error)
+
+	restErr = ToRestErr(errors.New("other error"))
+	assert.Equal(t, http.StatusInternalServerError, restErr.StatusCode)
+	assert.Equal(t, "other error", restErr.Message)
+}
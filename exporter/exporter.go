@@ -0,0 +1,442 @@
+// Package exporter exports the notes and cards matching an Anki search query to CSV, JSON or NDJSON.
+//
+// The notes and cards are fetched in pages and written to the io.Writer as they are received,
+// so that large collections can be exported without loading them into memory.
+//
+//	n, err := exporter.ExportNotes(client, "deck:Japanese", os.Stdout, exporter.Options{
+//		Format:    exporter.FormatCSV,
+//		StripHTML: true,
+//	})
+package exporter
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/atselvan/ankiconnect"
+	"github.com/atselvan/ankiconnect/internal/search"
+)
+
+const (
+	// FormatCSV writes a header and a row per note or card. The field columns are the union of the
+	// fields of the exported models, in the order of the model names and the model fields. The cells
+	// of the fields a model does not have are empty.
+	FormatCSV Format = "csv"
+	// FormatJSON writes a json array of notes or cards.
+	FormatJSON Format = "json"
+	// FormatNDJSON writes a json object per line.
+	FormatNDJSON Format = "ndjson"
+)
+
+type (
+	// Format is the output format of an export.
+	Format string
+
+	// Options configures an export.
+	Options struct {
+		// Format is the output format, FormatCSV if empty.
+		Format Format
+		// StripHTML removes the html tags from the field values and converts html entities to text.
+		StripHTML bool
+		// MediaDir is the directory to which the media files referenced by the exported fields are
+		// downloaded. Media files are not downloaded if it is empty.
+		MediaDir string
+		// SkipMissingMedia skips referenced media files that do not exist in the collection instead
+		// of failing the export.
+		SkipMissingMedia bool
+		// PageSize is the number of notes or cards fetched with a single request.
+		PageSize int
+	}
+
+	// Field is a note field.
+	Field struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+
+	// Note is an exported note.
+	Note struct {
+		Id     int64    `json:"id"`
+		Model  string   `json:"model"`
+		Fields []Field  `json:"fields"`
+		Tags   []string `json:"tags"`
+	}
+
+	// Card is an exported card with its scheduling values.
+	Card struct {
+		Id       int64   `json:"id"`
+		NoteId   int64   `json:"noteId"`
+		Deck     string  `json:"deck"`
+		Model    string  `json:"model"`
+		Ord      int64   `json:"ord"`
+		Question string  `json:"question"`
+		Answer   string  `json:"answer"`
+		Fields   []Field `json:"fields"`
+		Type     int64   `json:"type"`
+		Queue    int64   `json:"queue"`
+		Due      int64   `json:"due"`
+		Interval int64   `json:"interval"`
+		Reps     int64   `json:"reps"`
+		Lapses   int64   `json:"lapses"`
+		Left     int64   `json:"left"`
+		Mod      int64   `json:"mod"`
+	}
+
+	// encoder writes the exported records in an output format.
+	encoder interface {
+		encode(header func() []string, row func() []string, v interface{}) error
+		close() error
+	}
+
+	csvEncoder struct {
+		w      *csv.Writer
+		header bool
+	}
+
+	jsonEncoder struct {
+		w     io.Writer
+		count int
+	}
+
+	ndjsonEncoder struct {
+		enc *json.Encoder
+	}
+
+	// exporter holds the state of an export.
+	exporter struct {
+		client *ankiconnect.Client
+		opts   Options
+		media  map[string]bool
+		// columns are the field columns of a csv export, column maps their names to their index.
+		columns []string
+		column  map[string]int
+	}
+)
+
+var (
+	noteColumns = []string{"id", "model", "tags"}
+	cardColumns = []string{"id", "noteId", "deck", "model", "ord", "type", "queue", "due", "interval",
+		"reps", "lapses", "left", "mod", "question", "answer"}
+)
+
+// ExportNotes writes the notes matching the query to w and returns the number of exported notes.
+// The function returns an error if:
+//   - the format is not supported.
+//   - an api request to ankiconnect fails.
+//   - the api returns an error.
+//   - writing the output or the media files fails.
+//   - a referenced media file does not exist and SkipMissingMedia is not set, the error wraps
+//     ankiconnect.ErrMediaNotFound.
+func ExportNotes(client *ankiconnect.Client, query string, w io.Writer, opts Options) (int, error) {
+	exp, enc, err := newExporter(client, w, opts)
+	if err != nil {
+		return 0, err
+	}
+	if err := exp.csvColumns(query, client.Notes.Search); err != nil {
+		return 0, err
+	}
+	n := 0
+	for info, err := range client.Notes.Iterate(query, exp.iterateOptions()) {
+		if err != nil {
+			return n, err
+		}
+		fields, err := exp.fields(info.Fields)
+		if err != nil {
+			return n, err
+		}
+		note := Note{
+			Id:     info.NoteId,
+			Model:  info.ModelName,
+			Fields: fields,
+			Tags:   info.Tags,
+		}
+		if note.Tags == nil {
+			note.Tags = []string{}
+		}
+		err = enc.encode(
+			func() []string { return append(slices.Clone(noteColumns), exp.columns...) },
+			func() []string {
+				row := []string{strconv.FormatInt(note.Id, 10), note.Model, strings.Join(note.Tags, " ")}
+				return append(row, exp.fieldValues(fields)...)
+			},
+			note)
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, enc.close()
+}
+
+// ExportCards writes the cards matching the query to w and returns the number of exported cards.
+// The function returns an error if:
+//   - the format is not supported.
+//   - an api request to ankiconnect fails.
+//   - the api returns an error.
+//   - writing the output or the media files fails.
+//   - a referenced media file does not exist and SkipMissingMedia is not set, the error wraps
+//     ankiconnect.ErrMediaNotFound.
+func ExportCards(client *ankiconnect.Client, query string, w io.Writer, opts Options) (int, error) {
+	exp, enc, err := newExporter(client, w, opts)
+	if err != nil {
+		return 0, err
+	}
+	if err := exp.csvColumns(query, client.Cards.Search); err != nil {
+		return 0, err
+	}
+	n := 0
+	for info, err := range client.Cards.Iterate(query, exp.iterateOptions()) {
+		if err != nil {
+			return n, err
+		}
+		fields, err := exp.fields(info.Fields)
+		if err != nil {
+			return n, err
+		}
+		question, answer := info.Question, info.Answer
+		if opts.StripHTML {
+			question, answer = StripHTML(question), StripHTML(answer)
+		}
+		card := Card{
+			Id:       info.CardId,
+			NoteId:   info.Note,
+			Deck:     info.DeckName,
+			Model:    info.ModelName,
+			Ord:      info.Ord,
+			Question: question,
+			Answer:   answer,
+			Fields:   fields,
+			Type:     info.Type,
+			Queue:    info.Queue,
+			Due:      info.Due,
+			Interval: info.Interval,
+			Reps:     info.Reps,
+			Lapses:   info.Lapses,
+			Left:     info.Left,
+			Mod:      info.Mod,
+		}
+		err = enc.encode(
+			func() []string { return append(slices.Clone(cardColumns), exp.columns...) },
+			func() []string {
+				row := []string{strconv.FormatInt(card.Id, 10), strconv.FormatInt(card.NoteId, 10), card.Deck, card.Model}
+				for _, v := range []int64{card.Ord, card.Type, card.Queue, card.Due, card.Interval,
+					card.Reps, card.Lapses, card.Left, card.Mod} {
+					row = append(row, strconv.FormatInt(v, 10))
+				}
+				row = append(row, card.Question, card.Answer)
+				return append(row, exp.fieldValues(fields)...)
+			},
+			card)
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, enc.close()
+}
+
+// Validate returns an error if the format is not supported. The empty format is FormatCSV.
+func (f Format) Validate() error {
+	switch f {
+	case FormatCSV, FormatJSON, FormatNDJSON, "":
+		return nil
+	default:
+		return fmt.Errorf("exporter: unsupported format %q", string(f))
+	}
+}
+
+func newExporter(client *ankiconnect.Client, w io.Writer, opts Options) (*exporter, encoder, error) {
+	if err := opts.Format.Validate(); err != nil {
+		return nil, nil, err
+	}
+	var enc encoder
+	switch opts.Format {
+	case FormatJSON:
+		enc = &jsonEncoder{w: w}
+	case FormatNDJSON:
+		e := json.NewEncoder(w)
+		e.SetEscapeHTML(false)
+		enc = &ndjsonEncoder{enc: e}
+	default:
+		enc = &csvEncoder{w: csv.NewWriter(w)}
+	}
+	if opts.MediaDir != "" {
+		if err := os.MkdirAll(opts.MediaDir, 0o755); err != nil {
+			return nil, nil, err
+		}
+	}
+	return &exporter{client: client, opts: opts, media: map[string]bool{}}, enc, nil
+}
+
+func (exp *exporter) iterateOptions() *ankiconnect.IterateOptions {
+	return &ankiconnect.IterateOptions{
+		PageSize: exp.opts.PageSize,
+		Order:    ankiconnect.SortAscending,
+	}
+}
+
+// fields returns the fields in the order of the model, downloading the referenced media files
+// and stripping the html if requested.
+func (exp *exporter) fields(data map[string]ankiconnect.FieldData) ([]Field, error) {
+	fields := make([]Field, 0, len(data))
+	for name, d := range data {
+		fields = append(fields, Field{Name: name, Value: d.Value})
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return data[fields[i].Name].Order < data[fields[j].Name].Order
+	})
+	for i := range fields {
+		if err := exp.downloadMedia(fields[i].Value); err != nil {
+			return nil, err
+		}
+		if exp.opts.StripHTML {
+			fields[i].Value = StripHTML(fields[i].Value)
+		}
+	}
+	return fields, nil
+}
+
+// downloadMedia downloads the media files referenced by the field value to the media directory.
+// Every file is downloaded once per export.
+func (exp *exporter) downloadMedia(value string) error {
+	if exp.opts.MediaDir == "" {
+		return nil
+	}
+	for _, name := range MediaReferences(value) {
+		name = filepath.Base(name)
+		if exp.media[name] || name == "." || name == string(filepath.Separator) {
+			continue
+		}
+		exp.media[name] = true
+		encoded, err := exp.client.Media.RetrieveMediaFile(name)
+		if errors.Is(err, ankiconnect.ErrMediaNotFound) && exp.opts.SkipMissingMedia {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("exporter: media file %s: %w", name, err)
+		}
+		content, err := base64.StdEncoding.DecodeString(*encoded)
+		if err != nil {
+			return fmt.Errorf("exporter: media file %s: %w", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(exp.opts.MediaDir, name), content, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// csvColumns sets the field columns of a csv export to the union of the fields of the models of
+// the notes or cards matching the query, search is the search function of the notes or the cards.
+func (exp *exporter) csvColumns(query string, search func(query string) (*[]int64, error)) error {
+	if exp.opts.Format != FormatCSV && exp.opts.Format != "" {
+		return nil
+	}
+	models, err := exp.client.Models.GetAll()
+	if err != nil {
+		return err
+	}
+	names := slices.Clone(*models)
+	sort.Strings(names)
+	exp.column = map[string]int{}
+	for _, model := range names {
+		ids, err := search(modelQuery(query, model))
+		if err != nil {
+			return err
+		}
+		if len(*ids) == 0 {
+			continue
+		}
+		fields, err := exp.client.Models.GetFields(model)
+		if err != nil {
+			return err
+		}
+		for _, name := range *fields {
+			if _, ok := exp.column[name]; !ok {
+				exp.column[name] = len(exp.columns)
+				exp.columns = append(exp.columns, name)
+			}
+		}
+	}
+	return nil
+}
+
+// modelQuery restricts the query to the notes of the model.
+func modelQuery(query, model string) string {
+	term := search.Term("note", search.EscapeValue(model))
+	if strings.TrimSpace(query) == "" {
+		return term
+	}
+	return "(" + query + ") " + term
+}
+
+// fieldValues returns the values of the fields in the order of the csv columns, the values of the
+// columns the fields do not have are empty.
+func (exp *exporter) fieldValues(fields []Field) []string {
+	values := make([]string, len(exp.columns))
+	for _, f := range fields {
+		if i, ok := exp.column[f.Name]; ok {
+			values[i] = f.Value
+		}
+	}
+	return values
+}
+
+func (e *csvEncoder) encode(header func() []string, row func() []string, _ interface{}) error {
+	if !e.header {
+		e.header = true
+		if err := e.w.Write(header()); err != nil {
+			return err
+		}
+	}
+	return e.w.Write(row())
+}
+
+func (e *csvEncoder) close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *jsonEncoder) encode(_ func() []string, _ func() []string, v interface{}) error {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	prefix := ",\n"
+	if e.count == 0 {
+		prefix = "[\n"
+	}
+	e.count++
+	_, err := io.WriteString(e.w, prefix+strings.TrimSuffix(b.String(), "\n"))
+	return err
+}
+
+func (e *jsonEncoder) close() error {
+	end := "\n]\n"
+	if e.count == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
+
+func (e *ndjsonEncoder) encode(_ func() []string, _ func() []string, v interface{}) error {
+	return e.enc.Encode(v)
+}
+
+func (e *ndjsonEncoder) close() error {
+	return nil
+}
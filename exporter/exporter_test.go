@@ -0,0 +1,211 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/atselvan/ankiconnect"
+	"github.com/atselvan/ankiconnect/ankiconnecttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newServer(t *testing.T) (*ankiconnecttest.Server, []int64) {
+	server := ankiconnecttest.NewServer()
+	client := server.Client()
+	_, err := client.Media.StoreMediaFile("cat.png", base64.StdEncoding.EncodeToString([]byte("png")))
+	assert.NoError(t, err)
+
+	var ids []int64
+	for _, fields := range []ankiconnect.Fields{
+		{"Front": "<b>neko</b>", "Back": `cat<br><img src="cat.png">`},
+		{"Front": "inu", "Back": "dog &amp; puppy"},
+	} {
+		id, err := server.AddNote(ankiconnect.Note{
+			DeckName:  "Default",
+			ModelName: "Basic",
+			Fields:    fields,
+			Tags:      []string{"animal"},
+		})
+		assert.NoError(t, err)
+		ids = append(ids, id)
+	}
+	return server, ids
+}
+
+func TestExportNotes(t *testing.T) {
+	server, ids := newServer(t)
+	defer server.Close()
+
+	t.Run("csv", func(t *testing.T) {
+		var out bytes.Buffer
+		n, err := ExportNotes(server.Client(), "deck:Default", &out, Options{})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, n)
+
+		records, err := csv.NewReader(&out).ReadAll()
+		assert.NoError(t, err)
+		assert.Equal(t, [][]string{
+			{"id", "model", "tags", "Front", "Back"},
+			{itoa(ids[0]), "Basic", "animal", "<b>neko</b>", `cat<br><img src="cat.png">`},
+			{itoa(ids[1]), "Basic", "animal", "inu", "dog &amp; puppy"},
+		}, records)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var out bytes.Buffer
+		_, err := ExportNotes(server.Client(), "deck:Default", &out, Options{Format: FormatJSON, StripHTML: true})
+		assert.NoError(t, err)
+
+		var notes []Note
+		assert.NoError(t, json.Unmarshal(out.Bytes(), &notes))
+		assert.Equal(t, []Note{
+			{Id: ids[0], Model: "Basic", Fields: []Field{{"Front", "neko"}, {"Back", "cat"}}, Tags: []string{"animal"}},
+			{Id: ids[1], Model: "Basic", Fields: []Field{{"Front", "inu"}, {"Back", "dog & puppy"}}, Tags: []string{"animal"}},
+		}, notes)
+	})
+
+	t.Run("csv with several models", func(t *testing.T) {
+		server, ids := newServer(t)
+		defer server.Close()
+		cloze, err := server.AddNote(ankiconnect.Note{
+			DeckName:  "Default",
+			ModelName: "Cloze",
+			Fields:    ankiconnect.Fields{"Text": "{{c1::neko}} is a cat", "Back Extra": "animal"},
+		})
+		require.NoError(t, err)
+
+		var out bytes.Buffer
+		n, err := ExportNotes(server.Client(), "deck:Default", &out, Options{})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, n)
+
+		records, err := csv.NewReader(&out).ReadAll()
+		assert.NoError(t, err)
+		assert.Equal(t, [][]string{
+			{"id", "model", "tags", "Front", "Back", "Text", "Back Extra"},
+			{itoa(ids[0]), "Basic", "animal", "<b>neko</b>", `cat<br><img src="cat.png">`, "", ""},
+			{itoa(ids[1]), "Basic", "animal", "inu", "dog &amp; puppy", "", ""},
+			{itoa(cloze), "Cloze", "", "", "", "{{c1::neko}} is a cat", "animal"},
+		}, records)
+	})
+
+	t.Run("empty json", func(t *testing.T) {
+		var out bytes.Buffer
+		n, err := ExportNotes(server.Client(), "deck:Missing", &out, Options{Format: FormatJSON})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n)
+		assert.Equal(t, "[]\n", out.String())
+	})
+
+	t.Run("ndjson with media", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "media")
+		var out bytes.Buffer
+		_, err := ExportNotes(server.Client(), "deck:Default", &out, Options{Format: FormatNDJSON, MediaDir: dir})
+		assert.NoError(t, err)
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		assert.Len(t, lines, 2)
+		assert.Contains(t, lines[0], `<img src=\"cat.png\">`)
+		content, err := os.ReadFile(filepath.Join(dir, "cat.png"))
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("png"), content)
+	})
+
+	t.Run("missing media", func(t *testing.T) {
+		server.AddDeck("Dangling")
+		id, err := server.AddNote(ankiconnect.Note{
+			DeckName:  "Dangling",
+			ModelName: "Basic",
+			Fields:    ankiconnect.Fields{"Front": `<img src="missing.png">`, "Back": `<img src="cat.png">`},
+		})
+		require.NoError(t, err)
+
+		dir := t.TempDir()
+		_, err = ExportNotes(server.Client(), "deck:Dangling", &bytes.Buffer{}, Options{MediaDir: dir})
+		assert.ErrorIs(t, err, ankiconnect.ErrMediaNotFound)
+		assert.NotErrorIs(t, err, ankiconnect.ErrAnkiUnreachable)
+		assert.Contains(t, err.Error(), "missing.png")
+
+		var out bytes.Buffer
+		n, err := ExportNotes(server.Client(), "deck:Dangling", &out, Options{MediaDir: dir, SkipMissingMedia: true})
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assert.Contains(t, out.String(), itoa(id))
+		_, err = os.Stat(filepath.Join(dir, "missing.png"))
+		assert.True(t, os.IsNotExist(err))
+		_, err = os.Stat(filepath.Join(dir, "cat.png"))
+		assert.NoError(t, err)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := ExportNotes(server.Client(), "deck:Default", &bytes.Buffer{}, Options{Format: "xml"})
+		assert.EqualError(t, err, `exporter: unsupported format "xml"`)
+
+		server.FailAction(ankiconnect.ActionNotesInfo, "collection is not available")
+		defer server.FailAction(ankiconnect.ActionNotesInfo, "")
+		_, err = ExportNotes(server.Client(), "deck:Default", &bytes.Buffer{}, Options{})
+		assert.Error(t, err)
+	})
+}
+
+func TestExportCards(t *testing.T) {
+	server, ids := newServer(t)
+	defer server.Close()
+
+	cards, err := server.Client().Cards.Search("deck:Default")
+	assert.NoError(t, err)
+	assert.NoError(t, server.UpdateCard((*cards)[0], func(card *ankiconnecttest.Card) {
+		card.Interval, card.Reps = 12, 3
+	}))
+
+	t.Run("csv", func(t *testing.T) {
+		var out bytes.Buffer
+		n, err := ExportCards(server.Client(), "deck:Default", &out, Options{StripHTML: true})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, n)
+
+		records, err := csv.NewReader(&out).ReadAll()
+		assert.NoError(t, err)
+		assert.Equal(t, append(cardColumns, "Front", "Back"), records[0])
+		assert.Equal(t, itoa((*cards)[0]), records[1][0])
+		assert.Equal(t, itoa(ids[0]), records[1][1])
+		assert.Equal(t, "Default", records[1][2])
+		assert.Equal(t, "12", records[1][8])
+		assert.Equal(t, "3", records[1][9])
+		assert.Equal(t, "neko", records[1][15])
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		var out bytes.Buffer
+		_, err := ExportCards(server.Client(), "deck:Default", &out, Options{Format: FormatNDJSON})
+		assert.NoError(t, err)
+
+		var card Card
+		assert.NoError(t, json.Unmarshal([]byte(strings.Split(out.String(), "\n")[0]), &card))
+		assert.Equal(t, (*cards)[0], card.Id)
+		assert.Equal(t, int64(12), card.Interval)
+		assert.Equal(t, []Field{{"Front", "<b>neko</b>"}, {"Back", `cat<br><img src="cat.png">`}}, card.Fields)
+	})
+}
+
+func TestStripHTML(t *testing.T) {
+	assert.Equal(t, "a\nb & c", StripHTML(`<style>.card {}</style><div>a</div><b>b</b> &amp; c`))
+	assert.Equal(t, "front\n\nback", StripHTML("front<br/><hr id=answer><br>back"))
+}
+
+func TestMediaReferences(t *testing.T) {
+	assert.Equal(t, []string{"a b.png", "c.jpg", "d.mp3"},
+		MediaReferences(`<img src="a b.png"><IMG alt="" src=c.jpg><img src="https://x/y.png">[sound:d.mp3]`))
+}
+
+func itoa(n int64) string {
+	b, _ := json.Marshal(n)
+	return string(b)
+}
@@ -0,0 +1,37 @@
+package exporter
+
+import (
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/atselvan/ankiconnect/internal/htmltext"
+)
+
+var (
+	imagePattern = regexp.MustCompile(`(?i)<img[^>]+src\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+	soundPattern = regexp.MustCompile(`\[sound:([^\]]+)\]`)
+)
+
+// StripHTML converts the html of a field or card to text: style and script elements and all tags
+// are removed, line breaks and block ends are converted to new lines and entities are unescaped.
+func StripHTML(s string) string {
+	return htmltext.Strip(s)
+}
+
+// MediaReferences returns the names of the media files referenced by img tags and sound tags
+// in a field value. References to remote urls are ignored.
+func MediaReferences(s string) []string {
+	var names []string
+	for _, pattern := range []*regexp.Regexp{imagePattern, soundPattern} {
+		for _, m := range pattern.FindAllStringSubmatch(s, -1) {
+			// the name is in the group of the quoting style that matched
+			name := html.UnescapeString(m[1] + strings.Join(m[2:], ""))
+			if name == "" || strings.Contains(name, "://") {
+				continue
+			}
+			names = append(names, name)
+		}
+	}
+	return names
+}
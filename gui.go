@@ -0,0 +1,262 @@
+package ankiconnect
+
+import (
+	"fmt"
+	"net/http"
+)
+
+const (
+	ActionGuiBrowse        = "guiBrowse"
+	ActionGuiSelectCard    = "guiSelectCard"
+	ActionGuiSelectedNotes = "guiSelectedNotes"
+	ActionGuiAddCards      = "guiAddCards"
+	ActionGuiEditNote      = "guiEditNote"
+	ActionGuiReviewActive  = "guiReviewActive"
+	ActionGuiDeckOverview  = "guiDeckOverview"
+	ActionGuiDeckBrowser   = "guiDeckBrowser"
+	ActionGuiDeckReview    = "guiDeckReview"
+	ActionGuiImportFile    = "guiImportFile"
+	ActionGuiUndo          = "guiUndo"
+	ActionGuiExitAnki      = "guiExitAnki"
+
+	// The columns of the card browser that can be used to order the cards of Browse.
+	ColumnSortField    = "noteFld"
+	ColumnNoteCreated  = "noteCrt"
+	ColumnNoteModified = "noteMod"
+	ColumnCardModified = "cardMod"
+	ColumnDue          = "cardDue"
+	ColumnInterval     = "cardIvl"
+	ColumnEase         = "cardEase"
+	ColumnReviews      = "cardReps"
+	ColumnLapses       = "cardLapses"
+	ColumnDeck         = "deck"
+
+	browserNotOpenErrMsg = "the card could not be selected, the browser is not open"
+	guiDeckErrMsg        = "deck was not found: %s"
+)
+
+type (
+	// GUIManager describes the interface that can be used to control the Anki window,
+	// e.g. to open the card browser or the editor for a human to review a note.
+	GUIManager interface {
+		Browse(query string, order *BrowseOrder) (*[]int64, error)
+		SelectCard(id int64) error
+		SelectedNotes() (*[]int64, error)
+		AddCards(note Note) (*int64, error)
+		EditNote(id int64) error
+		ReviewActive() (*bool, error)
+		DeckOverview(name string) error
+		DeckBrowser() error
+		DeckReview(name string) error
+		ImportFile(path string) error
+		Undo() (*bool, error)
+		ExitAnki() error
+	}
+
+	// BrowseOrder is the order of the cards shown by the card browser.
+	BrowseOrder struct {
+		// Column is the column the cards are sorted by, e.g. ColumnDue.
+		Column string
+		// Order is SortAscending or SortDescending, SortAsReturned keeps the order of the browser.
+		Order SortOrder
+	}
+
+	// ParamsGuiBrowse represents the ankiconnect API params for opening the card browser.
+	ParamsGuiBrowse struct {
+		Query        string              `json:"query"`
+		ReorderCards *ParamsReorderCards `json:"reorderCards,omitempty"`
+	}
+
+	// ParamsReorderCards represents the order of the cards of the card browser.
+	ParamsReorderCards struct {
+		Order    string `json:"order"`
+		ColumnId string `json:"columnId"`
+	}
+
+	// ParamsGuiSelectCard represents the ankiconnect API params for selecting a card in the card browser.
+	ParamsGuiSelectCard struct {
+		Card int64 `json:"card"`
+	}
+
+	// ParamsGuiEditNote represents the ankiconnect API params for opening the editor of a note.
+	ParamsGuiEditNote struct {
+		Note int64 `json:"note"`
+	}
+
+	// ParamsGuiDeck represents the ankiconnect API params of the actions showing a deck.
+	ParamsGuiDeck struct {
+		Name string `json:"name"`
+	}
+
+	// ParamsGuiImportFile represents the ankiconnect API params for opening the import dialog.
+	ParamsGuiImportFile struct {
+		Path string `json:"path,omitempty"`
+	}
+
+	// guiManager implements GUIManager.
+	guiManager struct {
+		Client *Client
+	}
+)
+
+// Browse opens the card browser with the cards matching the query and returns the ids of the cards.
+// The cards are sorted by the column of order if it is not nil.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+func (gm *guiManager) Browse(query string, order *BrowseOrder) (*[]int64, error) {
+	params := ParamsGuiBrowse{
+		Query: query,
+	}
+	if order != nil && order.Order != SortAsReturned {
+		params.ReorderCards = &ParamsReorderCards{
+			Order:    "ascending",
+			ColumnId: order.Column,
+		}
+		if order.Order == SortDescending {
+			params.ReorderCards.Order = "descending"
+		}
+	}
+	return post[[]int64](gm.Client, ActionGuiBrowse, &params)
+}
+
+// SelectCard selects the card in the open card browser.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+//   - the card browser is not open.
+func (gm *guiManager) SelectCard(id int64) error {
+	params := ParamsGuiSelectCard{
+		Card: id,
+	}
+	selected, err := post[bool](gm.Client, ActionGuiSelectCard, &params)
+	if err != nil {
+		return err
+	}
+	if !*selected {
+		return &Error{
+			Action:     ActionGuiSelectCard,
+			Message:    browserNotOpenErrMsg,
+			StatusCode: http.StatusConflict,
+		}
+	}
+	return nil
+}
+
+// SelectedNotes returns the ids of the notes selected in the open card browser.
+// The result is empty if the browser is not open.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+func (gm *guiManager) SelectedNotes() (*[]int64, error) {
+	return post[[]int64, ParamsDefault](gm.Client, ActionGuiSelectedNotes, nil)
+}
+
+// AddCards opens the Add dialog with the deck, the model, the fields and the tags of the note,
+// and returns the id the note will have if the user adds it.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error, e.g. the deck or the model does not exist.
+func (gm *guiManager) AddCards(note Note) (*int64, error) {
+	params := ParamsCreateNote{
+		Note: &note,
+	}
+	return post[int64](gm.Client, ActionGuiAddCards, &params)
+}
+
+// EditNote opens the editor of the note.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error, e.g. the note does not exist.
+func (gm *guiManager) EditNote(id int64) error {
+	params := ParamsGuiEditNote{
+		Note: id,
+	}
+	_, err := post[interface{}](gm.Client, ActionGuiEditNote, &params)
+	return err
+}
+
+// ReviewActive reports whether the review screen is shown.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+func (gm *guiManager) ReviewActive() (*bool, error) {
+	return post[bool, ParamsDefault](gm.Client, ActionGuiReviewActive, nil)
+}
+
+// DeckOverview shows the overview screen of the deck.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+//   - the deck does not exist, the error wraps ErrDeckNotFound.
+func (gm *guiManager) DeckOverview(name string) error {
+	return gm.showDeck(ActionGuiDeckOverview, name)
+}
+
+// DeckBrowser shows the list of decks.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+func (gm *guiManager) DeckBrowser() error {
+	_, err := post[interface{}, ParamsDefault](gm.Client, ActionGuiDeckBrowser, nil)
+	return err
+}
+
+// DeckReview starts the review of the deck.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+//   - the deck does not exist, the error wraps ErrDeckNotFound.
+func (gm *guiManager) DeckReview(name string) error {
+	return gm.showDeck(ActionGuiDeckReview, name)
+}
+
+// ImportFile opens the import dialog for the file at path on the machine running Anki,
+// or the file picker if path is empty.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+func (gm *guiManager) ImportFile(path string) error {
+	params := ParamsGuiImportFile{
+		Path: path,
+	}
+	_, err := post[interface{}](gm.Client, ActionGuiImportFile, &params)
+	return err
+}
+
+// Undo undoes the last action of the user and reports whether there was an action to undo.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+func (gm *guiManager) Undo() (*bool, error) {
+	return post[bool, ParamsDefault](gm.Client, ActionGuiUndo, nil)
+}
+
+// ExitAnki closes Anki. Anki exits asynchronously after the response has been sent.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+func (gm *guiManager) ExitAnki() error {
+	_, err := post[interface{}, ParamsDefault](gm.Client, ActionGuiExitAnki, nil)
+	return err
+}
+
+// showDeck executes an action showing a deck, ankiconnect returns false if the deck does not exist.
+func (gm *guiManager) showDeck(action, name string) error {
+	params := ParamsGuiDeck{
+		Name: name,
+	}
+	shown, err := post[bool](gm.Client, action, &params)
+	if err != nil {
+		return err
+	}
+	if !*shown {
+		return &Error{
+			Action:     action,
+			Message:    fmt.Sprintf(guiDeckErrMsg, name),
+			StatusCode: http.StatusNotFound,
+			Kind:       ErrDeckNotFound,
+		}
+	}
+	return nil
+}
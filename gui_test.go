@@ -0,0 +1,232 @@
+package ankiconnect
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGUIManager_Browse(t *testing.T) {
+	t.Run("ordered", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t, []byte(`{
+    "action": "guiBrowse",
+    "version": 6,
+    "params": {
+        "query": "deck:Japanese",
+        "reorderCards": {
+            "order": "descending",
+            "columnId": "noteCrt"
+        }
+    }
+}`), []byte(`{"result": [1494723142483, 1494703460437], "error": null}`))
+
+		cards, err := client.GUI.Browse("deck:Japanese", &BrowseOrder{Column: ColumnNoteCreated, Order: SortDescending})
+		assert.Nil(t, err)
+		assert.Equal(t, &[]int64{1494723142483, 1494703460437}, cards)
+	})
+
+	t.Run("unordered", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t,
+			[]byte(`{"action": "guiBrowse", "version": 6, "params": {"query": "is:due"}}`),
+			[]byte(`{"result": [], "error": null}`))
+
+		cards, err := client.GUI.Browse("is:due", &BrowseOrder{Column: ColumnDue})
+		assert.Nil(t, err)
+		assert.Empty(t, *cards)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerErrorResponse(t)
+
+		cards, err := client.GUI.Browse("is:due", nil)
+		assert.Nil(t, cards)
+		assertRestErr(t, err, http.StatusBadRequest, "some error message")
+	})
+}
+
+func TestGUIManager_Selection(t *testing.T) {
+	selectRequest := []byte(`{"action": "guiSelectCard", "version": 6, "params": {"card": 1494723142483}}`)
+
+	t.Run("select card", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t, selectRequest, []byte(`{"result": true, "error": null}`))
+
+		err := client.GUI.SelectCard(1494723142483)
+		assert.Nil(t, err)
+	})
+
+	t.Run("browser not open", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t, selectRequest, []byte(`{"result": false, "error": null}`))
+
+		err := client.GUI.SelectCard(1494723142483)
+		assertRestErr(t, err, http.StatusConflict, browserNotOpenErrMsg)
+	})
+
+	t.Run("selected notes", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t,
+			[]byte(`{"action": "guiSelectedNotes", "version": 6}`),
+			[]byte(`{"result": [1494723142483], "error": null}`))
+
+		notes, err := client.GUI.SelectedNotes()
+		assert.Nil(t, err)
+		assert.Equal(t, &[]int64{1494723142483}, notes)
+	})
+}
+
+func TestGUIManager_Notes(t *testing.T) {
+	t.Run("add cards", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t, []byte(`{
+    "action": "guiAddCards",
+    "version": 6,
+    "params": {
+        "note": {
+            "deckName": "Japanese",
+            "modelName": "Basic",
+            "fields": {
+                "Front": "taberu",
+                "Back": "to eat"
+            },
+            "tags": ["verb"]
+        }
+    }
+}`), []byte(`{"result": 1496198395707, "error": null}`))
+
+		id, err := client.GUI.AddCards(Note{
+			DeckName:  "Japanese",
+			ModelName: "Basic",
+			Fields:    Fields{"Front": "taberu", "Back": "to eat"},
+			Tags:      []string{"verb"},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1496198395707), *id)
+	})
+
+	t.Run("edit note", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t,
+			[]byte(`{"action": "guiEditNote", "version": 6, "params": {"note": 1496198395707}}`),
+			[]byte(`{"result": null, "error": null}`))
+
+		err := client.GUI.EditNote(1496198395707)
+		assert.Nil(t, err)
+	})
+
+	t.Run("edit note error", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t,
+			[]byte(`{"action": "guiEditNote", "version": 6, "params": {"note": 1}}`),
+			[]byte(`{"result": null, "error": "note was not found: 1"}`))
+
+		err := client.GUI.EditNote(1)
+		assert.True(t, errors.Is(err, ErrNoteNotFound))
+	})
+}
+
+func TestGUIManager_Screens(t *testing.T) {
+	t.Run("review active", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t,
+			[]byte(`{"action": "guiReviewActive", "version": 6}`),
+			[]byte(`{"result": true, "error": null}`))
+
+		active, err := client.GUI.ReviewActive()
+		assert.Nil(t, err)
+		assert.True(t, *active)
+	})
+
+	t.Run("deck overview", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t,
+			[]byte(`{"action": "guiDeckOverview", "version": 6, "params": {"name": "Japanese"}}`),
+			[]byte(`{"result": true, "error": null}`))
+
+		err := client.GUI.DeckOverview("Japanese")
+		assert.Nil(t, err)
+	})
+
+	t.Run("deck review of unknown deck", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t,
+			[]byte(`{"action": "guiDeckReview", "version": 6, "params": {"name": "Missing"}}`),
+			[]byte(`{"result": false, "error": null}`))
+
+		err := client.GUI.DeckReview("Missing")
+		assertRestErr(t, err, http.StatusNotFound, "deck was not found: Missing")
+		assert.True(t, errors.Is(err, ErrDeckNotFound))
+	})
+
+	t.Run("deck browser", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t,
+			[]byte(`{"action": "guiDeckBrowser", "version": 6}`),
+			[]byte(`{"result": null, "error": null}`))
+
+		err := client.GUI.DeckBrowser()
+		assert.Nil(t, err)
+	})
+
+	t.Run("import file", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t,
+			[]byte(`{"action": "guiImportFile", "version": 6, "params": {"path": "/tmp/deck.apkg"}}`),
+			[]byte(`{"result": null, "error": null}`))
+
+		err := client.GUI.ImportFile("/tmp/deck.apkg")
+		assert.Nil(t, err)
+	})
+
+	t.Run("undo", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t,
+			[]byte(`{"action": "guiUndo", "version": 6}`),
+			[]byte(`{"result": false, "error": null}`))
+
+		undone, err := client.GUI.Undo()
+		assert.Nil(t, err)
+		assert.False(t, *undone)
+	})
+
+	t.Run("exit", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t,
+			[]byte(`{"action": "guiExitAnki", "version": 6}`),
+			[]byte(`{"result": null, "error": null}`))
+
+		err := client.GUI.ExitAnki()
+		assert.Nil(t, err)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerErrorResponse(t)
+
+		err := client.GUI.DeckBrowser()
+		assertRestErr(t, err, http.StatusBadRequest, "some error message")
+	})
+}
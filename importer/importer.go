@@ -0,0 +1,672 @@
+// Package importer imports notes from CSV and TSV files into Anki through ankiconnect.
+//
+// The rows are imported in batches: the existing notes are looked up with a single multi request per
+// batch and the new notes are added with a single addNotes request per batch.
+//
+//	report, err := importer.ImportFile(client, "vocab.csv", importer.Options{
+//		Deck:       "Japanese",
+//		Model:      "Basic",
+//		Fields:     map[string]string{"Front": "word", "Back": "meaning"},
+//		TagsColumn: "tags",
+//		Duplicates: importer.DuplicatesUpdate,
+//	})
+package importer
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/atselvan/ankiconnect"
+	"github.com/atselvan/ankiconnect/internal/medianame"
+	"github.com/atselvan/ankiconnect/query"
+)
+
+const (
+	// DuplicatesSkip skips the rows whose first field matches an existing note of the model.
+	DuplicatesSkip DuplicateMode = iota
+	// DuplicatesUpdate updates the fields of the existing note whose first field matches the row
+	// and adds the tags of the row to it. The deck of the existing note is not changed.
+	DuplicatesUpdate
+	// DuplicatesAllow adds every row as a new note, even if it is a duplicate.
+	DuplicatesAllow
+)
+
+// The results of importing a row.
+const (
+	StatusCreated Status = "created"
+	StatusUpdated Status = "updated"
+	StatusSkipped Status = "skipped"
+	StatusFailed  Status = "failed"
+)
+
+const (
+	defaultBatchSize = 100
+
+	notAddedErrMsg   = "the note could not be added"
+	emptyKeyErrMsg   = "the %s field is empty"
+	columnsErrMsg    = "the row has %d columns, column %d is required"
+	duplicateRowMsg  = "duplicate of row %d"
+	existingNoteMsg  = "duplicate of note %d"
+	unknownColumnMsg = "importer: unknown column %q"
+	mediaPathErrMsg  = "the media file %s is not inside the media directory"
+)
+
+type (
+	// DuplicateMode describes how rows that match existing notes are handled.
+	DuplicateMode int
+
+	// Status is the result of importing a row.
+	Status string
+
+	// Options configures an import.
+	Options struct {
+		// Deck is the deck of the notes, unless the row has a value in the DeckColumn.
+		// Decks that do not exist are created.
+		Deck string
+		// Model is the model (note type) of the notes, it is required.
+		Model string
+		// Comma is the column separator, ',' if zero. ImportFile uses '\t' for .tsv and .tab files.
+		Comma rune
+		// NoHeader must be set if the first row does not contain the column names.
+		NoHeader bool
+		// Fields maps the model fields to columns, referenced by their header or their 1-based index.
+		// If nil, the columns whose header is the name of a model field are used, or without
+		// header the columns are mapped to the model fields in order.
+		Fields map[string]string
+		// DeckColumn is the column that contains the deck of the row, optional.
+		DeckColumn string
+		// TagsColumn is the column that contains the space separated tags of the row, optional.
+		TagsColumn string
+		// Tags are added to every note.
+		Tags []string
+		// Media maps columns containing media file names to the model fields that reference them.
+		// The files are read from MediaDir and stored in the Anki media folder under their base name
+		// with a hash of their content appended, so that files of the same name in different
+		// directories do not replace each other. Images are referenced with an img tag and other
+		// files with a sound tag.
+		Media map[string]string
+		// MediaDir is the directory of the media files, ImportFile uses the directory of the file.
+		// The media file names must be relative paths that do not leave the directory.
+		MediaDir string
+		// Duplicates describes how rows matching existing notes are handled, DuplicatesSkip by default.
+		Duplicates DuplicateMode
+		// BatchSize is the number of rows imported with a single request, 100 if <= 0.
+		BatchSize int
+	}
+
+	// Report describes the result of an import.
+	Report struct {
+		Created int         `json:"created"`
+		Updated int         `json:"updated"`
+		Skipped int         `json:"skipped"`
+		Failed  int         `json:"failed"`
+		Rows    []RowResult `json:"rows"`
+	}
+
+	// RowResult is the result of importing a single row.
+	RowResult struct {
+		// Row is the 1-based number of the record in the file, including the header.
+		Row    int    `json:"row"`
+		Status Status `json:"status"`
+		NoteId int64  `json:"noteId,omitempty"`
+		Error  string `json:"error,omitempty"`
+	}
+
+	// importer holds the state of an import.
+	importer struct {
+		client *ankiconnect.Client
+		opts   Options
+
+		modelFields []string
+		fields      map[string]int
+		media       map[int]string
+		deckColumn  int
+		tagsColumn  int
+
+		decks map[string]bool
+		// keys maps the keys of the imported rows to their row and note id.
+		keys   map[string]keyResult
+		report *Report
+	}
+
+	keyResult struct {
+		row    int
+		noteId int64
+	}
+
+	// row is a record of the file converted to a note.
+	row struct {
+		number int
+		record []string
+		note   ankiconnect.Note
+		key    string
+		// existing is the id of the note matching the key of the row.
+		existing int64
+		// deferred is set if the row updates the note of a previous row of the same batch.
+		deferred    bool
+		duplicateOf int
+		result      RowResult
+	}
+)
+
+// ImportFile imports the CSV or TSV file at path.
+// The media files are read from the directory of the file unless opts.MediaDir is set.
+// See Import for the errors returned.
+func ImportFile(client *ankiconnect.Client, path string, opts Options) (*Report, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if opts.Comma == 0 {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".tsv", ".tab":
+			opts.Comma = '\t'
+		}
+	}
+	if opts.MediaDir == "" {
+		opts.MediaDir = filepath.Dir(path)
+	}
+	return Import(client, f, opts)
+}
+
+// Import imports the CSV or TSV rows read from r as notes.
+// Rows that cannot be imported are reported as failed in the report.
+// The function returns an error if:
+//   - the model is not set or a column of the mapping does not exist.
+//   - the input is not valid CSV.
+//   - an api request to ankiconnect fails.
+//   - the api returns an error, e.g. the model does not exist.
+//
+// The report contains the rows imported before the error.
+func Import(client *ankiconnect.Client, r io.Reader, opts Options) (*Report, error) {
+	if opts.Model == "" {
+		return nil, errors.New("importer: the model is required")
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	if opts.Comma != 0 {
+		reader.Comma = opts.Comma
+	}
+	if reader.Comma == '\t' {
+		reader.LazyQuotes = true
+	}
+
+	imp := &importer{
+		client: client,
+		opts:   opts,
+		keys:   map[string]keyResult{},
+		report: &Report{Rows: []RowResult{}},
+	}
+	modelFields, err := client.Models.GetFields(opts.Model)
+	if err != nil {
+		return nil, err
+	}
+	imp.modelFields = *modelFields
+	if len(imp.modelFields) == 0 {
+		return nil, fmt.Errorf("importer: model %s has no fields", opts.Model)
+	}
+
+	var header []string
+	number := 0
+	if !opts.NoHeader {
+		if header, err = reader.Read(); err != nil && err != io.EOF {
+			return nil, err
+		}
+		number++
+	}
+	if err := imp.resolveColumns(header); err != nil {
+		return nil, err
+	}
+
+	var batch []*row
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return imp.report, err
+		}
+		number++
+		batch = append(batch, &row{number: number, record: record})
+		if len(batch) == opts.BatchSize {
+			if err := imp.importBatch(batch); err != nil {
+				return imp.report, err
+			}
+			batch = nil
+		}
+	}
+	if err := imp.importBatch(batch); err != nil {
+		return imp.report, err
+	}
+	return imp.report, nil
+}
+
+// resolveColumns resolves the column references of the options to column indexes.
+func (imp *importer) resolveColumns(header []string) error {
+	imp.fields = map[string]int{}
+	switch {
+	case imp.opts.Fields != nil:
+		for field, column := range imp.opts.Fields {
+			i, err := columnIndex(header, column)
+			if err != nil {
+				return err
+			}
+			imp.fields[field] = i
+		}
+	case header != nil:
+		for _, field := range imp.modelFields {
+			for i, name := range header {
+				if strings.TrimSpace(name) == field {
+					imp.fields[field] = i
+				}
+			}
+		}
+	default:
+		for i, field := range imp.modelFields {
+			imp.fields[field] = i
+		}
+	}
+	if _, ok := imp.fields[imp.modelFields[0]]; !ok {
+		return fmt.Errorf("importer: no column is mapped to the first field %s", imp.modelFields[0])
+	}
+
+	imp.deckColumn, imp.tagsColumn = -1, -1
+	var err error
+	if imp.opts.DeckColumn != "" {
+		if imp.deckColumn, err = columnIndex(header, imp.opts.DeckColumn); err != nil {
+			return err
+		}
+	}
+	if imp.opts.TagsColumn != "" {
+		if imp.tagsColumn, err = columnIndex(header, imp.opts.TagsColumn); err != nil {
+			return err
+		}
+	}
+	imp.media = map[int]string{}
+	for column, field := range imp.opts.Media {
+		i, err := columnIndex(header, column)
+		if err != nil {
+			return err
+		}
+		imp.media[i] = field
+	}
+	return nil
+}
+
+// columnIndex returns the index of the column with the header name, or of the 1-based column number.
+func columnIndex(header []string, column string) (int, error) {
+	for i, name := range header {
+		if strings.TrimSpace(name) == column {
+			return i, nil
+		}
+	}
+	if n, err := strconv.Atoi(column); err == nil && n > 0 {
+		return n - 1, nil
+	}
+	return 0, fmt.Errorf(unknownColumnMsg, column)
+}
+
+// importBatch imports a batch of rows and adds their results to the report.
+func (imp *importer) importBatch(batch []*row) error {
+	defer func() {
+		for _, r := range batch {
+			imp.report.add(r.result)
+		}
+	}()
+
+	var rows []*row
+	for _, r := range batch {
+		r.result = RowResult{Row: r.number}
+		if msg := imp.buildNote(r); msg != "" {
+			r.fail(msg)
+			continue
+		}
+		rows = append(rows, r)
+	}
+	if err := imp.ensureDecks(rows); err != nil {
+		return err
+	}
+	if imp.opts.Duplicates != DuplicatesAllow {
+		var err error
+		if rows, err = imp.resolveDuplicates(rows); err != nil {
+			return err
+		}
+	}
+
+	var creates, updates, deferred []*row
+	for _, r := range rows {
+		if msg := imp.storeMedia(r); msg != "" {
+			r.fail(msg)
+			continue
+		}
+		switch {
+		case r.deferred:
+			deferred = append(deferred, r)
+		case r.existing != 0:
+			updates = append(updates, r)
+		default:
+			creates = append(creates, r)
+		}
+	}
+	if err := imp.create(creates); err != nil {
+		return err
+	}
+	for _, r := range deferred {
+		previous, ok := imp.keys[r.key]
+		if !ok {
+			r.fail(fmt.Sprintf(duplicateRowMsg, r.duplicateOf))
+			continue
+		}
+		r.existing = previous.noteId
+		updates = append(updates, r)
+	}
+	return imp.update(updates)
+}
+
+// buildNote converts the record of the row to a note.
+// It returns a message describing why the row cannot be imported.
+func (imp *importer) buildNote(r *row) string {
+	value := func(column int) (string, string) {
+		if column >= len(r.record) {
+			return "", fmt.Sprintf(columnsErrMsg, len(r.record), column+1)
+		}
+		return strings.TrimSpace(r.record[column]), ""
+	}
+
+	r.note = ankiconnect.Note{
+		DeckName:  imp.opts.Deck,
+		ModelName: imp.opts.Model,
+		Fields:    ankiconnect.Fields{},
+		Tags:      append([]string(nil), imp.opts.Tags...),
+	}
+	for field, column := range imp.fields {
+		v, msg := value(column)
+		if msg != "" {
+			return msg
+		}
+		r.note.Fields[field] = v
+	}
+	if imp.deckColumn >= 0 {
+		deck, msg := value(imp.deckColumn)
+		if msg != "" {
+			return msg
+		}
+		if deck != "" {
+			r.note.DeckName = deck
+		}
+	}
+	if imp.tagsColumn >= 0 {
+		tags, msg := value(imp.tagsColumn)
+		if msg != "" {
+			return msg
+		}
+		r.note.Tags = append(r.note.Tags, strings.Fields(tags)...)
+	}
+	if imp.opts.Duplicates == DuplicatesAllow {
+		r.note.Options = &ankiconnect.Options{AllowDuplicate: true}
+	}
+
+	r.key = r.note.Fields[imp.modelFields[0]]
+	if r.key == "" {
+		return fmt.Sprintf(emptyKeyErrMsg, imp.modelFields[0])
+	}
+	return ""
+}
+
+// ensureDecks creates the decks of the rows that do not exist.
+func (imp *importer) ensureDecks(rows []*row) error {
+	if imp.decks == nil {
+		decks, err := imp.client.Decks.GetAll()
+		if err != nil {
+			return err
+		}
+		imp.decks = map[string]bool{}
+		for _, deck := range *decks {
+			imp.decks[deck] = true
+		}
+	}
+	for _, r := range rows {
+		if r.note.DeckName == "" || imp.decks[r.note.DeckName] {
+			continue
+		}
+		if err := imp.client.Decks.Create(r.note.DeckName); err != nil {
+			return err
+		}
+		imp.decks[r.note.DeckName] = true
+	}
+	return nil
+}
+
+// resolveDuplicates looks up the notes matching the keys of the rows with a single multi request.
+// Depending on the duplicate mode the rows matching a note are skipped or marked for update.
+// It returns the rows that remain to be imported.
+func (imp *importer) resolveDuplicates(rows []*row) ([]*row, error) {
+	var lookups []*row
+	var actions []ankiconnect.MultiAction
+	for _, r := range rows {
+		if _, ok := imp.keys[r.key]; ok {
+			continue
+		}
+		q := query.And{query.NoteType(imp.opts.Model), query.Field(imp.modelFields[0], r.key)}
+		lookups = append(lookups, r)
+		actions = append(actions, ankiconnect.MultiAction{
+			Action: ankiconnect.ActionFindNotes,
+			Params: ankiconnect.ParamsFindNotes{Query: q.String()},
+		})
+	}
+	existing := map[*row]int64{}
+	if len(actions) > 0 {
+		results, err := imp.client.Multi(actions...)
+		if err != nil {
+			return nil, err
+		}
+		for i, result := range *results {
+			if i >= len(lookups) {
+				break
+			}
+			if result.Error != "" {
+				return nil, fmt.Errorf("importer: %s: %s", ankiconnect.ActionFindNotes, result.Error)
+			}
+			var ids []int64
+			if err := json.Unmarshal(result.Result, &ids); err != nil {
+				return nil, err
+			}
+			if len(ids) > 0 {
+				existing[lookups[i]] = minID(ids)
+			}
+		}
+	}
+
+	var remaining []*row
+	batchKeys := map[string]keyResult{}
+	for _, r := range rows {
+		previous, imported := imp.keys[r.key]
+		if p, ok := batchKeys[r.key]; ok {
+			previous, imported = p, true
+		}
+		switch {
+		case imported && imp.opts.Duplicates == DuplicatesSkip:
+			r.skip(fmt.Sprintf(duplicateRowMsg, previous.row))
+			continue
+		case imported && previous.noteId == 0:
+			// the note of the previous row is created in this batch, it is updated afterwards
+			r.deferred, r.duplicateOf = true, previous.row
+		case imported:
+			r.existing = previous.noteId
+		case existing[r] != 0 && imp.opts.Duplicates == DuplicatesSkip:
+			r.skip(fmt.Sprintf(existingNoteMsg, existing[r]))
+			continue
+		case existing[r] != 0:
+			r.existing = existing[r]
+		}
+		if _, ok := batchKeys[r.key]; !ok {
+			batchKeys[r.key] = keyResult{row: r.number, noteId: r.existing}
+		}
+		remaining = append(remaining, r)
+	}
+	return remaining, nil
+}
+
+// storeMedia stores the media files of the row in Anki and references them in the note fields.
+func (imp *importer) storeMedia(r *row) string {
+	for column, field := range imp.media {
+		if column >= len(r.record) {
+			continue
+		}
+		name := strings.TrimSpace(r.record[column])
+		if name == "" {
+			continue
+		}
+		if !filepath.IsLocal(name) {
+			return fmt.Sprintf(mediaPathErrMsg, name)
+		}
+		content, err := os.ReadFile(filepath.Join(imp.opts.MediaDir, name))
+		if err != nil {
+			return err.Error()
+		}
+		stored, err := imp.client.Media.StoreMediaFile(medianame.Unique(name, content), base64.StdEncoding.EncodeToString(content))
+		if err != nil {
+			return err.Error()
+		}
+		reference := mediaReference(*stored)
+		if v := r.note.Fields[field]; v != "" {
+			reference = v + " " + reference
+		}
+		r.note.Fields[field] = reference
+	}
+	return ""
+}
+
+// create adds the notes of the rows with a single addNotes request.
+func (imp *importer) create(rows []*row) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	notes := make([]ankiconnect.Note, len(rows))
+	for i, r := range rows {
+		notes[i] = r.note
+	}
+	ids, err := imp.client.Notes.AddMany(notes)
+	if err != nil {
+		return err
+	}
+	for i, r := range rows {
+		if i >= len(*ids) || (*ids)[i] == 0 {
+			r.fail(notAddedErrMsg)
+			continue
+		}
+		r.result.Status, r.result.NoteId = StatusCreated, (*ids)[i]
+		imp.keys[r.key] = keyResult{row: r.number, noteId: r.result.NoteId}
+	}
+	return nil
+}
+
+// update updates the fields of the existing notes of the rows and adds the tags of the rows
+// to them with a single multi request.
+func (imp *importer) update(rows []*row) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	var actions []ankiconnect.MultiAction
+	// first holds the index of the first action of every row
+	first := make([]int, len(rows))
+	for i, r := range rows {
+		first[i] = len(actions)
+		actions = append(actions, ankiconnect.MultiAction{
+			Action: ankiconnect.ActionUpdateNoteFields,
+			Params: ankiconnect.ParamsUpdateNote{
+				Note: &ankiconnect.UpdateNote{Id: r.existing, Fields: r.note.Fields},
+			},
+		})
+		if len(r.note.Tags) > 0 {
+			actions = append(actions, ankiconnect.MultiAction{
+				Action: ankiconnect.ActionAddTags,
+				Params: ankiconnect.ParamsAddTags{
+					Notes: &[]int64{r.existing},
+					Tags:  strings.Join(r.note.Tags, " "),
+				},
+			})
+		}
+	}
+	results, err := imp.client.Multi(actions...)
+	if err != nil {
+		return err
+	}
+	for i, r := range rows {
+		last := len(actions)
+		if i+1 < len(rows) {
+			last = first[i+1]
+		}
+		msg := ""
+		for j := first[i]; j < last && j < len(*results); j++ {
+			if (*results)[j].Error != "" {
+				msg = (*results)[j].Error
+				break
+			}
+		}
+		if msg != "" {
+			r.fail(msg)
+			continue
+		}
+		r.result.Status, r.result.NoteId = StatusUpdated, r.existing
+		imp.keys[r.key] = keyResult{row: r.number, noteId: r.existing}
+	}
+	return nil
+}
+
+func (r *row) fail(msg string) {
+	r.result.Status, r.result.Error = StatusFailed, msg
+}
+
+func (r *row) skip(msg string) {
+	r.result.Status, r.result.Error = StatusSkipped, msg
+}
+
+// add adds the result of a row to the report.
+func (rp *Report) add(result RowResult) {
+	switch result.Status {
+	case StatusCreated:
+		rp.Created++
+	case StatusUpdated:
+		rp.Updated++
+	case StatusSkipped:
+		rp.Skipped++
+	case StatusFailed:
+		rp.Failed++
+	default:
+		// the row was not processed because the import failed
+		return
+	}
+	rp.Rows = append(rp.Rows, result)
+}
+
+// mediaReference returns the field content referencing a media file.
+func mediaReference(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".jpg", ".jpeg", ".png", ".gif", ".svg", ".webp", ".bmp", ".tif", ".tiff", ".avif":
+		return fmt.Sprintf(`<img src="%s">`, filename)
+	}
+	return fmt.Sprintf("[sound:%s]", filename)
+}
+
+func minID(ids []int64) int64 {
+	id := ids[0]
+	for _, other := range ids[1:] {
+		id = min(id, other)
+	}
+	return id
+}
@@ -0,0 +1,258 @@
+package importer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/atselvan/ankiconnect"
+	"github.com/atselvan/ankiconnect/ankiconnecttest"
+	"github.com/atselvan/ankiconnect/internal/medianame"
+	"github.com/stretchr/testify/assert"
+)
+
+func findNote(t *testing.T, server *ankiconnecttest.Server, id int64) ankiconnecttest.Note {
+	note, ok := server.Note(id)
+	assert.True(t, ok, "note %d", id)
+	return note
+}
+
+func deckOf(t *testing.T, server *ankiconnecttest.Server, id int64) string {
+	cards, err := server.Client().Cards.Get(fmt.Sprintf("nid:%d", id))
+	assert.NoError(t, err)
+	if !assert.NotEmpty(t, *cards) {
+		return ""
+	}
+	return (*cards)[0].DeckName
+}
+
+func TestImport(t *testing.T) {
+	t.Run("header mapping", func(t *testing.T) {
+		server := ankiconnecttest.NewServer()
+		defer server.Close()
+
+		input := "word,meaning,deck,tags\n" +
+			"taberu,to eat,Japanese::Verbs,verb common\n" +
+			"nomu,to drink,,verb\n" +
+			",missing key,,\n" +
+			"taberu,to eat again,,\n"
+		report, err := Import(server.Client(), strings.NewReader(input), Options{
+			Deck:       "Japanese",
+			Model:      "Basic",
+			Fields:     map[string]string{"Front": "word", "Back": "meaning"},
+			DeckColumn: "deck",
+			TagsColumn: "tags",
+			Tags:       []string{"imported"},
+			BatchSize:  2,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, report.Created)
+		assert.Equal(t, 1, report.Skipped)
+		assert.Equal(t, 1, report.Failed)
+		assert.Equal(t, []RowResult{
+			{Row: 2, Status: StatusCreated, NoteId: report.Rows[0].NoteId},
+			{Row: 3, Status: StatusCreated, NoteId: report.Rows[1].NoteId},
+			{Row: 4, Status: StatusFailed, Error: "the Front field is empty"},
+			{Row: 5, Status: StatusSkipped, Error: "duplicate of row 2"},
+		}, report.Rows)
+
+		note := findNote(t, server, report.Rows[0].NoteId)
+		assert.Equal(t, "Japanese::Verbs", deckOf(t, server, note.Id))
+		assert.Equal(t, "to eat", note.Fields["Back"])
+		assert.ElementsMatch(t, []string{"imported", "verb", "common"}, note.Tags)
+		assert.Equal(t, "Japanese", deckOf(t, server, report.Rows[1].NoteId))
+
+		var addNotes int
+		for _, action := range server.Actions() {
+			assert.NotEqual(t, ankiconnect.ActionAddNote, action)
+			if action == ankiconnect.ActionAddNotes {
+				addNotes++
+			}
+		}
+		assert.Equal(t, 1, addNotes)
+	})
+
+	t.Run("update existing notes", func(t *testing.T) {
+		server := ankiconnecttest.NewServer()
+		defer server.Close()
+		id, err := server.AddNote(ankiconnect.Note{
+			DeckName:  "Default",
+			ModelName: "Basic",
+			Fields:    ankiconnect.Fields{"Front": "taberu", "Back": "old"},
+		})
+		assert.NoError(t, err)
+
+		input := "taberu\tto eat\nnomu\tto drink\nnomu\tto drink (liquids)\n"
+		report, err := Import(server.Client(), strings.NewReader(input), Options{
+			Deck:       "Default",
+			Model:      "Basic",
+			Comma:      '\t',
+			NoHeader:   true,
+			Duplicates: DuplicatesUpdate,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, report.Created)
+		assert.Equal(t, 2, report.Updated)
+		assert.Equal(t, RowResult{Row: 1, Status: StatusUpdated, NoteId: id}, report.Rows[0])
+		assert.Equal(t, "to eat", findNote(t, server, id).Fields["Back"])
+
+		created := report.Rows[1].NoteId
+		assert.Equal(t, RowResult{Row: 3, Status: StatusUpdated, NoteId: created}, report.Rows[2])
+		assert.Equal(t, "to drink (liquids)", findNote(t, server, created).Fields["Back"])
+	})
+
+	t.Run("update tags", func(t *testing.T) {
+		server := ankiconnecttest.NewServer()
+		defer server.Close()
+		server.AddDeck("Japanese")
+		id, err := server.AddNote(ankiconnect.Note{
+			DeckName:  "Default",
+			ModelName: "Basic",
+			Fields:    ankiconnect.Fields{"Front": "taberu", "Back": "old"},
+			Tags:      []string{"old"},
+		})
+		assert.NoError(t, err)
+
+		input := "Front,Back,tags,deck\ntaberu,to eat,verb,Japanese\n"
+		report, err := Import(server.Client(), strings.NewReader(input), Options{
+			Deck:       "Default",
+			Model:      "Basic",
+			TagsColumn: "tags",
+			DeckColumn: "deck",
+			Tags:       []string{"imported"},
+			Duplicates: DuplicatesUpdate,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, report.Updated)
+		note := findNote(t, server, id)
+		assert.Equal(t, "to eat", note.Fields["Back"])
+		assert.ElementsMatch(t, []string{"old", "imported", "verb"}, note.Tags)
+		// the deck of an existing note is not changed
+		assert.Equal(t, "Default", deckOf(t, server, id))
+	})
+
+	t.Run("allow duplicates", func(t *testing.T) {
+		server := ankiconnecttest.NewServer()
+		defer server.Close()
+
+		report, err := Import(server.Client(), strings.NewReader("a,1\na,2\n"), Options{
+			Deck:       "Default",
+			Model:      "Basic",
+			NoHeader:   true,
+			Duplicates: DuplicatesAllow,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, report.Created)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		server := ankiconnecttest.NewServer()
+		defer server.Close()
+
+		_, err := Import(server.Client(), strings.NewReader("a,b\n"), Options{Deck: "Default"})
+		assert.EqualError(t, err, "importer: the model is required")
+
+		_, err = Import(server.Client(), strings.NewReader("a,b\n"), Options{
+			Model:  "Basic",
+			Fields: map[string]string{"Front": "word"},
+		})
+		assert.EqualError(t, err, `importer: unknown column "word"`)
+
+		_, err = Import(server.Client(), strings.NewReader("a,b\n"), Options{Model: "Unknown"})
+		assert.ErrorIs(t, err, ankiconnect.ErrModelNotFound)
+	})
+}
+
+func TestImportFile(t *testing.T) {
+	server := ankiconnecttest.NewServer()
+	defer server.Close()
+
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "cat.png"), []byte("png"), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "cat.mp3"), []byte("mp3"), 0o600))
+	path := filepath.Join(dir, "vocab.tsv")
+	input := "Front\tBack\timage\taudio\n" +
+		"neko\tcat\tcat.png\tcat.mp3\n" +
+		"inu\tdog\tdog.png\t\n"
+	assert.NoError(t, os.WriteFile(path, []byte(input), 0o600))
+
+	report, err := ImportFile(server.Client(), path, Options{
+		Deck:  "Default",
+		Model: "Basic",
+		Media: map[string]string{"image": "Back", "audio": "Front"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 1, report.Failed)
+	assert.Contains(t, report.Rows[1].Error, "dog.png")
+
+	png, mp3 := medianame.Unique("cat.png", []byte("png")), medianame.Unique("cat.mp3", []byte("mp3"))
+	note := findNote(t, server, report.Rows[0].NoteId)
+	assert.Equal(t, `cat <img src="`+png+`">`, note.Fields["Back"])
+	assert.Equal(t, "neko [sound:"+mp3+"]", note.Fields["Front"])
+	content, ok := server.Media(png)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("png"), content)
+}
+
+func TestImportFile_SameMediaNames(t *testing.T) {
+	server := ankiconnecttest.NewServer()
+	defer server.Close()
+
+	dir := t.TempDir()
+	for _, sub := range []string{"a", "b"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(dir, "img", sub), 0o700))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "img", sub, "x.png"), []byte(sub), 0o600))
+	}
+	path := filepath.Join(dir, "vocab.csv")
+	input := "Front,Back,image\n" +
+		"neko,cat,img/a/x.png\n" +
+		"inu,dog,img/b/x.png\n"
+	assert.NoError(t, os.WriteFile(path, []byte(input), 0o600))
+
+	report, err := ImportFile(server.Client(), path, Options{
+		Deck:  "Default",
+		Model: "Basic",
+		Media: map[string]string{"image": "Back"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Created)
+
+	for i, sub := range []string{"a", "b"} {
+		name := medianame.Unique("x.png", []byte(sub))
+		note := findNote(t, server, report.Rows[i].NoteId)
+		assert.Contains(t, note.Fields["Back"], `<img src="`+name+`">`)
+		content, ok := server.Media(name)
+		assert.True(t, ok)
+		assert.Equal(t, []byte(sub), content)
+	}
+}
+
+func TestImportFile_MediaOutsideDir(t *testing.T) {
+	server := ankiconnecttest.NewServer()
+	defer server.Close()
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "deck")
+	assert.NoError(t, os.Mkdir(dir, 0o700))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "secret.png"), []byte("secret"), 0o600))
+	path := filepath.Join(dir, "vocab.csv")
+	input := "Front,Back,image\n" +
+		"neko,cat,../secret.png\n" +
+		"inu,dog," + filepath.Join(root, "secret.png") + "\n"
+	assert.NoError(t, os.WriteFile(path, []byte(input), 0o600))
+
+	report, err := ImportFile(server.Client(), path, Options{
+		Deck:  "Default",
+		Model: "Basic",
+		Media: map[string]string{"image": "Back"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Failed)
+	assert.Equal(t, "the media file ../secret.png is not inside the media directory", report.Rows[0].Error)
+	assert.Contains(t, report.Rows[1].Error, "is not inside the media directory")
+	_, ok := server.Media("secret.png")
+	assert.False(t, ok)
+}
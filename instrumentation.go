@@ -0,0 +1,145 @@
+package ankiconnect
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	// ErrorKindNone is the error kind reported for successful calls.
+	ErrorKindNone = ""
+	// ErrorKindAPI is the error kind reported for errors returned by ankiconnect that are not known.
+	ErrorKindAPI = "api"
+	// ErrorKindOther is the error kind reported for errors that are not returned by ankiconnect,
+	// e.g. errors returned by interceptors.
+	ErrorKindOther = "other"
+)
+
+// errorKinds maps the sentinel errors to the error kinds reported to Metrics and Tracer implementations.
+var errorKinds = map[error]string{
+	ErrDuplicateNote:      "duplicate_note",
+	ErrDeckNotFound:       "deck_not_found",
+	ErrNoteNotFound:       "note_not_found",
+	ErrModelNotFound:      "model_not_found",
+	ErrModelExists:        "model_exists",
+	ErrAnkiUnreachable:    "unreachable",
+	ErrCircuitOpen:        "circuit_open",
+	ErrPermissionDenied:   "permission_denied",
+	ErrUnsupportedVersion: "unsupported_version",
+	ErrUnsupportedAction:  "unsupported_action",
+	ErrUnexpectedRequest:  "unexpected_request",
+	ErrSyncLoginRequired:  "sync_login_required",
+	ErrFullSyncRequired:   "full_sync_required",
+	ErrSyncTimeout:        "sync_timeout",
+	ErrProfileNotFound:    "profile_not_found",
+	ErrMediaNotFound:      "media_not_found",
+	ErrInvalidResponse:    "invalid_response",
+}
+
+type (
+	// Metrics receives the measurements of every call made by the client.
+	// Implementations must be safe for concurrent use.
+	Metrics interface {
+		// ObserveCall is called once per call with the action, the time the call took
+		// and the kind of the error, which is ErrorKindNone for successful calls.
+		ObserveCall(action string, duration time.Duration, errorKind string)
+	}
+
+	// Tracer creates a span for every call made by the client.
+	// It can be implemented on top of OpenTelemetry or any other tracing library.
+	Tracer interface {
+		// StartSpan starts the span of a call to the action.
+		StartSpan(action string) Span
+	}
+
+	// Span is a span created by a Tracer.
+	Span interface {
+		// StartChild starts a child span, it is used for the actions of a multi request.
+		StartChild(action string) Span
+		// SetAttribute sets an attribute of the span.
+		SetAttribute(key string, value interface{})
+		// SetError records the error of the call and its kind on the span.
+		SetError(err error, errorKind string)
+		// End ends the span.
+		End()
+	}
+)
+
+// SetMetrics can be used to record metrics for every call made by the client.
+// A nil value disables metrics, which is the default.
+func (c *Client) SetMetrics(metrics Metrics) *Client {
+	c.metrics = metrics
+	return c
+}
+
+// SetTracer can be used to create a trace span for every call made by the client.
+// A nil value disables tracing, which is the default.
+func (c *Client) SetTracer(tracer Tracer) *Client {
+	c.tracer = tracer
+	return c
+}
+
+// ErrorKind returns a short name for the reason of an error returned by the client,
+// suitable to be used as a metric label. It returns ErrorKindNone for a nil error.
+func ErrorKind(err error) string {
+	if err == nil {
+		return ErrorKindNone
+	}
+	var e *Error
+	if !errors.As(err, &e) {
+		return ErrorKindOther
+	}
+	if kind, ok := errorKinds[e.Kind]; ok {
+		return kind
+	}
+	return ErrorKindAPI
+}
+
+// instrument records the metrics and the trace span of a call executed by run.
+func (c *Client) instrument(call *Call, run func() error) error {
+	if c.metrics == nil && c.tracer == nil {
+		return run()
+	}
+
+	var span Span
+	var children []Span
+	if c.tracer != nil {
+		span = c.tracer.StartSpan(call.Action)
+		if m, ok := call.Params.(*ParamsMulti); ok && m != nil {
+			for _, a := range m.Actions {
+				children = append(children, span.StartChild(a.Action))
+			}
+		}
+	}
+
+	start := time.Now()
+	err := run()
+	duration := time.Since(start)
+	kind := ErrorKind(err)
+
+	if c.metrics != nil {
+		c.metrics.ObserveCall(call.Action, duration, kind)
+	}
+	if span != nil {
+		endChildren(children, call.Result)
+		span.SetAttribute("payload_size", len(call.Payload))
+		if err != nil {
+			span.SetError(err, kind)
+		}
+		span.End()
+	}
+	return err
+}
+
+// endChildren ends the spans of the actions of a multi request, recording the errors of the actions.
+func endChildren(children []Span, result interface{}) {
+	results, _ := result.(*[]Result[json.RawMessage])
+	for i, child := range children {
+		if results != nil && i < len(*results) && (*results)[i].Error != "" {
+			err := newAPIError("", (*results)[i].Error)
+			child.SetError(err, ErrorKind(err))
+		}
+		child.End()
+	}
+}
@@ -0,0 +1,124 @@
+package ankiconnect
+
+import (
+	"errors"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type observedCall struct {
+	action    string
+	errorKind string
+}
+
+type metricsStub struct {
+	mu    sync.Mutex
+	calls []observedCall
+}
+
+func (m *metricsStub) ObserveCall(action string, _ time.Duration, errorKind string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, observedCall{action: action, errorKind: errorKind})
+}
+
+type spanStub struct {
+	action    string
+	errorKind string
+	attrs     map[string]interface{}
+	children  []*spanStub
+	ended     bool
+}
+
+func (s *spanStub) StartChild(action string) Span {
+	child := &spanStub{action: action, attrs: map[string]interface{}{}}
+	s.children = append(s.children, child)
+	return child
+}
+
+func (s *spanStub) SetAttribute(key string, value interface{}) { s.attrs[key] = value }
+func (s *spanStub) SetError(_ error, errorKind string)         { s.errorKind = errorKind }
+func (s *spanStub) End()                                       { s.ended = true }
+
+type tracerStub struct {
+	spans []*spanStub
+}
+
+func (tr *tracerStub) StartSpan(action string) Span {
+	span := &spanStub{action: action, attrs: map[string]interface{}{}}
+	tr.spans = append(tr.spans, span)
+	return span
+}
+
+func TestClient_SetMetrics(t *testing.T) {
+	defer httpmock.Reset()
+	defer client.SetMetrics(nil)
+
+	metrics := &metricsStub{}
+	client.SetMetrics(metrics)
+
+	registerMultipleVerifiedPayloads(t, [][2][]byte{
+		{[]byte(`{"action": "deckNames", "version": 6}`), []byte(`{"result": ["Default"], "error": null}`)},
+		{[]byte(`{"action": "createDeck", "version": 6, "params": {"deck": "test"}}`),
+			[]byte(`{"result": null, "error": "deck was not found"}`)},
+	})
+
+	_, err := client.Decks.GetAll()
+	require.NoError(t, err)
+	assert.Error(t, client.Decks.Create("test"))
+
+	assert.Equal(t, []observedCall{
+		{action: ActionDeckNames, errorKind: ErrorKindNone},
+		{action: ActionCreateDeck, errorKind: "deck_not_found"},
+	}, metrics.calls)
+}
+
+func TestClient_SetTracer(t *testing.T) {
+	defer httpmock.Reset()
+	defer client.SetTracer(nil)
+
+	tracer := &tracerStub{}
+	client.SetTracer(tracer)
+
+	registerVerifiedPayload(t,
+		[]byte(`{"action": "multi", "version": 6, "params": {"actions": [
+			{"action": "deckNames", "version": 6},
+			{"action": "createDeck", "version": 6, "params": {"deck": "test"}}
+		]}}`),
+		[]byte(`{"result": [
+			{"result": ["Default"], "error": null},
+			{"result": null, "error": "some error message"}
+		], "error": null}`))
+
+	_, err := client.Multi(
+		MultiAction{Action: ActionDeckNames},
+		MultiAction{Action: ActionCreateDeck, Params: ParamsCreateDeck{Deck: "test"}},
+	)
+	require.NoError(t, err)
+
+	require.Len(t, tracer.spans, 1)
+	span := tracer.spans[0]
+	assert.Equal(t, ActionMulti, span.action)
+	assert.True(t, span.ended)
+	assert.Positive(t, span.attrs["payload_size"])
+	require.Len(t, span.children, 2)
+	assert.Equal(t, ActionDeckNames, span.children[0].action)
+	assert.Equal(t, ErrorKindNone, span.children[0].errorKind)
+	assert.Equal(t, ErrorKindAPI, span.children[1].errorKind)
+	assert.True(t, span.children[1].ended)
+}
+
+func TestErrorKind(t *testing.T) {
+	assert.Equal(t, ErrorKindNone, ErrorKind(nil))
+	assert.Equal(t, ErrorKindOther, ErrorKind(errors.New("other")))
+	assert.Equal(t, ErrorKindAPI, ErrorKind(newAPIError(ActionAddNote, "some error message")))
+	assert.Equal(t, "duplicate_note", ErrorKind(newAPIError(ActionAddNote, "cannot create note because it is a duplicate")))
+	assert.Equal(t, "unreachable", ErrorKind(newTransportError(ActionAddNote, &url.Error{Op: "Post", URL: "http://localhost:8765", Err: errors.New("connection refused")})))
+	assert.Equal(t, "invalid_response", ErrorKind(newTransportError(ActionAddNote, errors.New("json: cannot unmarshal bool into Go value of type string"))))
+}
@@ -0,0 +1,54 @@
+package ankiconnect
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type (
+	// Call describes a single ankiconnect action passing through the interceptor chain.
+	Call struct {
+		// Action is the ankiconnect action to be executed.
+		Action string
+		// Params are the typed params of the action, e.g. *ParamsCreateNote, or nil if the action
+		// has no params. Interceptors may modify the params before invoking the next handler,
+		// but must not replace them with a value of a different type.
+		Params interface{}
+		// Payload is the json payload sent to ankiconnect, including the api key if one is set.
+		// It is set once the request has been sent.
+		Payload json.RawMessage
+		// Result is a pointer to the typed result of the action, e.g. *[]string for deckNames.
+		// It is set if the action succeeded.
+		Result interface{}
+		// Response is the raw json response returned by ankiconnect.
+		Response json.RawMessage
+		// Latency is the time it took to execute the action, including retries.
+		Latency time.Duration
+	}
+
+	// Handler executes a call and returns the error of the call.
+	Handler func(call *Call) error
+
+	// Interceptor is invoked for every call made by the client. It can inspect or modify the call
+	// before passing it on to next, inspect the result and the error afterwards, or return an error
+	// without invoking next at all.
+	Interceptor func(call *Call, next Handler) error
+)
+
+// Use adds interceptors to the client. Interceptors are invoked in the order they were added,
+// the first interceptor being the outermost one.
+func (c *Client) Use(interceptors ...Interceptor) *Client {
+	c.interceptors = append(c.interceptors, interceptors...)
+	return c
+}
+
+// intercept passes the call through the interceptors of the client and finally to handler.
+func (c *Client) intercept(call *Call, handler Handler) error {
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		interceptor, next := c.interceptors[i], handler
+		handler = func(call *Call) error {
+			return interceptor(call, next)
+		}
+	}
+	return handler(call)
+}
@@ -0,0 +1,112 @@
+package ankiconnect
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Use(t *testing.T) {
+	deckNamesPayload := []byte(`{"action": "deckNames", "version": 6}`)
+	deckNamesResult := []byte(`{"result": ["Default"], "error": null}`)
+
+	t.Run("order and observed call", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		c := NewClient()
+		httpmock.ActivateNonDefault(c.httpClient.GetClient())
+		registerVerifiedPayload(t, deckNamesPayload, deckNamesResult)
+
+		var order []string
+		var observed Call
+		c.Use(
+			func(call *Call, next Handler) error {
+				order = append(order, "outer")
+				return next(call)
+			},
+			func(call *Call, next Handler) error {
+				order = append(order, "inner")
+				err := next(call)
+				observed = *call
+				return err
+			},
+		)
+
+		decks, err := c.Decks.GetAll()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Default"}, *decks)
+		assert.Equal(t, []string{"outer", "inner"}, order)
+		assert.Equal(t, ActionDeckNames, observed.Action)
+		assert.JSONEq(t, string(deckNamesPayload), string(observed.Payload))
+		assert.JSONEq(t, string(deckNamesResult), string(observed.Response))
+		assert.Equal(t, decks, observed.Result)
+		assert.Positive(t, observed.Latency)
+	})
+
+	t.Run("request mutation", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		c := NewClient()
+		httpmock.ActivateNonDefault(c.httpClient.GetClient())
+		registerVerifiedPayload(t,
+			[]byte(`{"action": "addNote", "version": 6, "params": {"note": {"deckName": "test", "tags": ["imported"]}}}`),
+			[]byte(`{"result": 1659294247478, "error": null}`))
+
+		c.Use(func(call *Call, next Handler) error {
+			if params, ok := call.Params.(*ParamsCreateNote); ok {
+				params.Note.Tags = append(params.Note.Tags, "imported")
+			}
+			return next(call)
+		})
+
+		assert.NoError(t, c.Notes.Add(Note{DeckName: "test"}))
+	})
+
+	t.Run("fault injection", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		c := NewClient()
+		httpmock.ActivateNonDefault(c.httpClient.GetClient())
+
+		injected := errors.New("injected")
+		c.Use(func(call *Call, next Handler) error {
+			return injected
+		})
+
+		_, err := c.Decks.GetAll()
+		assert.ErrorIs(t, err, injected)
+		assert.Zero(t, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("errors are visible to interceptors", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		c := NewClient()
+		httpmock.ActivateNonDefault(c.httpClient.GetClient())
+		registerErrorResponse(t)
+
+		var observed error
+		c.Use(func(call *Call, next Handler) error {
+			observed = next(call)
+			return observed
+		})
+
+		_, err := c.Decks.GetAll()
+		assert.Error(t, err)
+		assert.Equal(t, err, observed)
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		c := NewClient().Use(func(call *Call, next Handler) error {
+			call.Params = "invalid"
+			return next(call)
+		})
+
+		_, err := c.Decks.GetAll()
+		var apiErr *Error
+		assert.ErrorAs(t, err, &apiErr)
+	})
+}
@@ -0,0 +1,43 @@
+// Package htmltext converts the html of note fields and cards to plain text.
+// It is shared by the apkg, exporter and dedupe packages.
+package htmltext
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	blockPattern     = regexp.MustCompile(`(?is)<(style|script)[^>]*>.*?</(style|script)>`)
+	linebreakPattern = regexp.MustCompile(`(?i)<br\s*/?>|</(div|p|li|tr)>`)
+	tagPattern       = regexp.MustCompile(`<[^>]*>`)
+
+	// ankiPattern and mediaTagPattern are the patterns of Anki's strip_html and
+	// strip_html_preserving_media_filenames.
+	ankiPattern     = regexp.MustCompile(`(?si)<!--.*?-->|<style.*?>.*?</style>|<script.*?>.*?</script>|<.*?>`)
+	mediaTagPattern = regexp.MustCompile(`(?si)<\b(?:img|audio|video|object)\b(?:[^>]|"[^"]+?"|'[^']+?')+?\b(?:src|data)\b=` +
+		`(?:"([^"]+?)"[^>]*>|'([^']+?)'[^>]*>|([^ >]+?)(?: [^>]*>|>))`)
+)
+
+// Strip converts html to text: style and script elements and all tags are removed,
+// line breaks and block ends are converted to new lines and entities are unescaped.
+func Strip(s string) string {
+	s = blockPattern.ReplaceAllString(s, "")
+	s = linebreakPattern.ReplaceAllString(s, "\n")
+	s = tagPattern.ReplaceAllString(s, "")
+	return strings.TrimSpace(html.UnescapeString(s))
+}
+
+// StripPreservingMedia converts html to text as Anki does for the sort field and the checksum of
+// the first field of a note: media tags are replaced with their file names, comments, style and
+// script elements and all tags are removed and entities are unescaped. Unlike Strip line breaks
+// are not converted and spaces are not trimmed.
+func StripPreservingMedia(s string) string {
+	s = mediaTagPattern.ReplaceAllString(s, " ${1}${2}${3} ")
+	s = ankiPattern.ReplaceAllString(s, "")
+	if !strings.Contains(s, "&") {
+		return s
+	}
+	return strings.ReplaceAll(html.UnescapeString(s), "\u00a0", " ")
+}
@@ -0,0 +1,20 @@
+package htmltext
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStrip(t *testing.T) {
+	assert.Equal(t, "a\nb & c", Strip(`<script>x()</script><div>a</div><b>b</b> &amp; c`))
+	assert.Equal(t, "plain", Strip(" plain "))
+}
+
+func TestStripPreservingMedia(t *testing.T) {
+	assert.Equal(t, "Tom & Jerry", StripPreservingMedia("<b>Tom</b> &amp; Jerry"))
+	assert.Equal(t, "a  cat.png  b", StripPreservingMedia(`a <img src="cat.png"> b`))
+	assert.Equal(t, " x.jpg ", StripPreservingMedia(`<img alt='x' src=x.jpg>`))
+	assert.Equal(t, "a b", StripPreservingMedia("<!-- c -->a&nbsp;b<style>p {}</style>"))
+	assert.Equal(t, "a\n", StripPreservingMedia("a<br>\n"))
+}
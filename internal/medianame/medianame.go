@@ -0,0 +1,21 @@
+// Package medianame derives the names media files are stored under in the Anki media folder.
+// It is shared by the importer and markdown packages.
+package medianame
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+)
+
+// Unique returns the base name of path with a short hash of the content appended to its stem,
+// e.g. diagram-0a4d55a8d778e502.png. storeMediaFile replaces existing files of the same name,
+// so files with the same base name but a different content get different names, and storing a
+// file twice results in the same name.
+func Unique(path string, content []byte) string {
+	base := filepath.Base(path)
+	ext := filepath.Ext(base)
+	sum := sha1.Sum(content)
+	return strings.TrimSuffix(base, ext) + "-" + hex.EncodeToString(sum[:8]) + ext
+}
@@ -0,0 +1,15 @@
+package medianame
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnique(t *testing.T) {
+	a := Unique("img/a/diagram.png", []byte("a"))
+	assert.Regexp(t, `^diagram-[0-9a-f]{16}\.png$`, a)
+	assert.Equal(t, a, Unique("diagram.png", []byte("a")))
+	assert.NotEqual(t, a, Unique("img/b/diagram.png", []byte("b")))
+	assert.Regexp(t, `^README-[0-9a-f]{16}$`, Unique("README", nil))
+}
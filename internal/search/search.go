@@ -0,0 +1,42 @@
+// Package search formats the terms of Anki search queries.
+// It is shared by the query package and the ankiconnect package, which cannot import query
+// because the tests of query use the ankiconnect client.
+package search
+
+import "strings"
+
+// EscapeValue escapes the wildcards and backslashes of a literal value.
+func EscapeValue(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r == '\\' || r == '*' || r == '_' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Term returns the search term for the key and the value, quoted if required.
+// The term is a text search if key is empty. The value is used as is, it has to be escaped
+// with EscapeValue to be matched literally.
+func Term(key, value string) string {
+	s := value
+	if key != "" {
+		s = key + ":" + value
+	}
+	if !needsQuotes(s) && (key != "" || !isKeyword(s)) {
+		return s
+	}
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// needsQuotes reports whether a term must be quoted to be parsed as a single term.
+func needsQuotes(s string) bool {
+	return s == "" || strings.ContainsAny(s, " \t\r\n()\"") || strings.HasPrefix(s, "-")
+}
+
+// isKeyword reports whether a text term would be parsed as the and or or operator.
+func isKeyword(s string) bool {
+	return strings.EqualFold(s, "and") || strings.EqualFold(s, "or")
+}
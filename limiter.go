@@ -0,0 +1,99 @@
+package ankiconnect
+
+import (
+	"sync"
+	"time"
+)
+
+type (
+	// rateLimiter is a token bucket that refills at rate tokens per second up to burst tokens.
+	rateLimiter struct {
+		rate  float64
+		burst float64
+
+		mu     sync.Mutex
+		tokens float64
+		last   time.Time
+	}
+)
+
+// SetRateLimit limits the number of requests sent to ankiconnect to requestsPerSecond,
+// allowing bursts of up to burst requests. Requests that exceed the limit wait until they are allowed.
+// Every attempt of a retried request counts against the limit.
+// A requestsPerSecond value <= 0 disables rate limiting, which is the default.
+func (c *Client) SetRateLimit(requestsPerSecond float64, burst int) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if requestsPerSecond <= 0 {
+		c.limiter = nil
+		return c
+	}
+	c.limiter = newRateLimiter(requestsPerSecond, burst)
+	return c
+}
+
+// SetConcurrencyLimit limits the number of requests that are in flight at the same time to n.
+// Anki executes the ankiconnect requests one at a time on its main thread, so limiting the
+// concurrency avoids requests piling up and timing out when the client is shared by many goroutines.
+// A value <= 0 disables the limit, which is the default.
+func (c *Client) SetConcurrencyLimit(n int) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n <= 0 {
+		c.semaphore = nil
+		return c
+	}
+	c.semaphore = make(chan struct{}, n)
+	return c
+}
+
+// acquire blocks until the rate limit and the concurrency limit allow a request to be sent.
+// The returned function must be called when the request has completed.
+func (c *Client) acquire() func() {
+	c.mu.RLock()
+	limiter, semaphore := c.limiter, c.semaphore
+	c.mu.RUnlock()
+
+	if limiter != nil {
+		limiter.wait()
+	}
+	if semaphore == nil {
+		return func() {}
+	}
+	semaphore <- struct{}{}
+	return func() { <-semaphore }
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// wait blocks until a token is available and takes it.
+func (l *rateLimiter) wait() {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	// The token is reserved even if it is not available yet, so that waiting requests are served in order.
+	l.tokens--
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
@@ -0,0 +1,54 @@
+package ankiconnect
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter_Wait(t *testing.T) {
+	t.Run("allows bursts", func(t *testing.T) {
+		limiter := newRateLimiter(1, 3)
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			limiter.wait()
+		}
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+	})
+
+	t.Run("delays requests over the limit", func(t *testing.T) {
+		limiter := newRateLimiter(50, 1)
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			limiter.wait()
+		}
+		assert.GreaterOrEqual(t, time.Since(start), 35*time.Millisecond)
+	})
+}
+
+func TestClient_SetConcurrencyLimit(t *testing.T) {
+	c := NewClient()
+
+	release := c.SetConcurrencyLimit(1).acquire()
+	acquired := make(chan struct{})
+	go func() {
+		c.acquire()()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("request was sent while the concurrency limit was reached")
+	case <-time.After(20 * time.Millisecond):
+	}
+	release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("request was not sent after the concurrency limit was released")
+	}
+
+	c.SetConcurrencyLimit(0)
+	assert.Nil(t, c.semaphore)
+}
@@ -0,0 +1,107 @@
+package ankiconnect
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+)
+
+const (
+	redactedValue = "<redacted>"
+)
+
+// redactedKeys are the payload keys whose values are never logged, mapped to whether the length
+// of the value is logged instead. Media is sent base64 encoded in "data" fields, which would bloat
+// the logs, and "key" holds the api key.
+var redactedKeys = map[string]bool{
+	"data": true,
+	"key":  false,
+}
+
+// discardHandler is a slog.Handler that drops all records. It is used when no logger is set.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// SetLogger can be used to set the logger used by the client.
+// Every call is logged at debug level, failed calls are logged at warn level.
+// A nil logger disables logging, which is the default.
+func (c *Client) SetLogger(logger *slog.Logger) *Client {
+	if logger == nil {
+		logger = slog.New(discardHandler{})
+	}
+	c.logger = logger
+	return c
+}
+
+// logCall writes a structured record for a call to ankiconnect.
+// The payload is only logged at debug level, with media data and the api key redacted.
+func (c *Client) logCall(call *Call, status int, err error) {
+	ctx := context.Background()
+	level := slog.LevelDebug
+	if err != nil {
+		level = slog.LevelWarn
+	}
+	if !c.logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("action", call.Action),
+		slog.Duration("duration", call.Latency),
+		slog.Int("status", status),
+		slog.Int("payload_size", len(call.Payload)),
+		slog.Int("response_size", len(call.Response)),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	if c.logger.Enabled(ctx, slog.LevelDebug) && len(call.Payload) > 0 {
+		attrs = append(attrs, slog.String("payload", string(redactPayload(call.Payload))))
+	}
+	c.logger.LogAttrs(ctx, level, "ankiconnect call", attrs...)
+}
+
+// redactPayload replaces the values of the redacted keys in a json payload.
+func redactPayload(payload []byte) []byte {
+	var v interface{}
+	if err := json.Unmarshal(payload, &v); err != nil {
+		return payload
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(redact(v)); err != nil {
+		return payload
+	}
+	return bytes.TrimSpace(buf.Bytes())
+}
+
+func redact(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, field := range value {
+			withLength, ok := redactedKeys[k]
+			s, isString := field.(string)
+			switch {
+			case !ok:
+				value[k] = redact(field)
+			case !isString || s == "":
+			case withLength:
+				value[k] = fmt.Sprintf("%s (%d bytes)", redactedValue, len(s))
+			default:
+				value[k] = redactedValue
+			}
+		}
+	case []interface{}:
+		for i := range value {
+			value[i] = redact(value[i])
+		}
+	}
+	return v
+}
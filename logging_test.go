@@ -0,0 +1,82 @@
+package ankiconnect
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_SetLogger(t *testing.T) {
+	storePayload := []byte(`{
+    "action": "storeMediaFile",
+    "version": 6,
+    "key": "secret",
+    "params": {
+        "filename": "_hello.txt",
+        "data": "SGVsbG8sIHdvcmxkIQ=="
+    }
+}`)
+
+	t.Run("debug", func(t *testing.T) {
+		defer httpmock.Reset()
+		defer client.SetLogger(nil).SetAPIKey("")
+
+		var buf bytes.Buffer
+		client.SetLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))).
+			SetAPIKey("secret")
+		registerVerifiedPayload(t, storePayload, []byte(`{"result": "_hello.txt", "error": null}`))
+
+		_, err := client.Media.StoreMediaFile("_hello.txt", "SGVsbG8sIHdvcmxkIQ==")
+		require.NoError(t, err)
+
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+		assert.Equal(t, "DEBUG", record["level"])
+		assert.Equal(t, ActionStoreMedia, record["action"])
+		assert.Equal(t, float64(200), record["status"])
+		assert.Contains(t, record, "duration")
+		assert.Positive(t, record["payload_size"])
+		assert.NotContains(t, record, "error")
+		assert.NotContains(t, buf.String(), "SGVsbG8sIHdvcmxkIQ==")
+		assert.NotContains(t, buf.String(), "secret")
+		assert.Contains(t, record["payload"], "<redacted> (20 bytes)")
+	})
+
+	t.Run("error", func(t *testing.T) {
+		defer httpmock.Reset()
+		defer client.SetLogger(nil)
+
+		var buf bytes.Buffer
+		client.SetLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn})))
+		registerErrorResponse(t)
+
+		_, err := client.Decks.GetAll()
+		require.Error(t, err)
+
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+		assert.Equal(t, "WARN", record["level"])
+		assert.Equal(t, "ankiconnect: deckNames: some error message", record["error"])
+		assert.NotContains(t, record, "payload")
+	})
+
+	t.Run("default", func(t *testing.T) {
+		c := NewClient()
+		assert.False(t, c.logger.Enabled(context.Background(), slog.LevelError))
+	})
+}
+
+func TestRedactPayload(t *testing.T) {
+	payload := []byte(`{"action":"multi","params":{"actions":[{"action":"storeMediaFile","params":{"filename":"a.mp3","data":"AAAA"}}]}}`)
+	assert.JSONEq(t,
+		`{"action":"multi","params":{"actions":[{"action":"storeMediaFile","params":{"filename":"a.mp3","data":"<redacted> (4 bytes)"}}]}}`,
+		string(redactPayload(payload)))
+
+	assert.Equal(t, "not json", string(redactPayload([]byte("not json"))))
+}
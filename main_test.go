@@ -121,3 +121,13 @@ func registerMultipleVerifiedPayloads(t *testing.T, pairs [][2][]byte) {
 func registerVerifiedPayload(t *testing.T, payloadJson []byte, responseJson []byte) {
 	registerMultipleVerifiedPayloads(t, [][2][]byte{{payloadJson, responseJson}})
 }
+
+// Checks that err is a *Error and that it converts to a RestErr with the given status code and message
+func assertRestErr(t *testing.T, err error, statusCode int, message string) {
+	var apiErr *Error
+	if assert.ErrorAs(t, err, &apiErr) {
+		restErr := ToRestErr(err)
+		assert.Equal(t, statusCode, restErr.StatusCode)
+		assert.Equal(t, message, restErr.Message)
+	}
+}
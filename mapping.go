@@ -0,0 +1,436 @@
+package ankiconnect
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	mappingTag = "anki"
+
+	mappingOptionDeck  = "deck"
+	mappingOptionModel = "model"
+	mappingOptionTags  = "tags"
+	mappingOptionID    = "id"
+)
+
+type (
+	// DeckNamer can be implemented by the structs passed to MarshalNote to choose the deck of the note.
+	// A non-empty field tagged with `anki:",deck"` takes precedence.
+	DeckNamer interface {
+		AnkiDeck() string
+	}
+
+	// ModelNamer can be implemented by the structs passed to MarshalNote to choose the model of the note.
+	// A non-empty field tagged with `anki:",model"` takes precedence.
+	ModelNamer interface {
+		AnkiModel() string
+	}
+
+	// Tagger can be implemented by the structs passed to MarshalNote to set the tags of the note.
+	// A non-empty field tagged with `anki:",tags"` takes precedence.
+	Tagger interface {
+		AnkiTags() []string
+	}
+
+	// FieldMismatchError is returned by ValidateNote when the fields of a note do not match
+	// the fields of its model. It wraps ErrFieldMismatch.
+	FieldMismatchError struct {
+		Model string
+		// Missing are the fields of the model that are not set on the note.
+		Missing []string
+		// Extra are the fields of the note that do not exist in the model.
+		Extra []string
+	}
+
+	// noteMapping describes how the fields of a struct type are mapped to a note.
+	noteMapping struct {
+		fields []fieldMapping
+		deck   []int
+		model  []int
+		tags   []int
+		id     []int
+	}
+
+	// fieldMapping maps a struct field to a note field.
+	fieldMapping struct {
+		name  string
+		index []int
+	}
+)
+
+var (
+	mappings sync.Map // map[reflect.Type]*noteMapping
+
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	stringSliceType     = reflect.TypeOf([]string(nil))
+)
+
+// MarshalNote converts a struct into a Note.
+// The struct fields tagged with `anki:"<field name>"` are converted to the note fields, a field tagged
+// with `anki:"-"` or without a tag is ignored. The deck, the model, the tags and the note id can be set
+// from fields tagged with `anki:",deck"`, `anki:",model"`, `anki:",tags"` and `anki:",id"`,
+// or by implementing DeckNamer, ModelNamer and Tagger.
+// Fields can be strings, booleans, numbers or implement encoding.TextMarshaler.
+//
+//	type Vocab struct {
+//		Word    string   `anki:"Front"`
+//		Meaning string   `anki:"Back"`
+//		Tags    []string `anki:",tags"`
+//	}
+//
+//	func (Vocab) AnkiDeck() string  { return "Japanese" }
+//	func (Vocab) AnkiModel() string { return "Basic" }
+//
+// The function returns an error if the type is not a struct or contains fields of unsupported types.
+func MarshalNote[T any](v T) (Note, error) {
+	rv := reflect.ValueOf(&v).Elem()
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return Note{}, fmt.Errorf("ankiconnect: MarshalNote(nil %s)", rv.Type())
+		}
+		rv = rv.Elem()
+	}
+	m, err := mappingOf(rv.Type())
+	if err != nil {
+		return Note{}, err
+	}
+
+	note := Note{
+		Fields: Fields{},
+	}
+	for _, f := range m.fields {
+		value, err := formatField(readField(rv, f.index))
+		if err != nil {
+			return Note{}, fmt.Errorf("ankiconnect: field %s: %w", f.name, err)
+		}
+		note.Fields[f.name] = value
+	}
+	if m.deck != nil {
+		note.DeckName = readField(rv, m.deck).String()
+	}
+	if m.model != nil {
+		note.ModelName = readField(rv, m.model).String()
+	}
+	if m.tags != nil {
+		tags := readField(rv, m.tags).Convert(stringSliceType).Interface().([]string)
+		note.Tags = append([]string(nil), tags...)
+	}
+
+	// the methods are looked up on a pointer so that methods with both receiver types are found
+	ptr := reflect.New(rv.Type())
+	ptr.Elem().Set(rv)
+	if d, ok := ptr.Interface().(DeckNamer); ok && note.DeckName == "" {
+		note.DeckName = d.AnkiDeck()
+	}
+	if n, ok := ptr.Interface().(ModelNamer); ok && note.ModelName == "" {
+		note.ModelName = n.AnkiModel()
+	}
+	if t, ok := ptr.Interface().(Tagger); ok && len(note.Tags) == 0 {
+		note.Tags = t.AnkiTags()
+	}
+	return note, nil
+}
+
+// NoteID returns the value of the field tagged with `anki:",id"`, or 0 if the struct has no such field.
+func NoteID[T any](v T) int64 {
+	rv := reflect.ValueOf(&v).Elem()
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return 0
+		}
+		rv = rv.Elem()
+	}
+	m, err := mappingOf(rv.Type())
+	if err != nil || m.id == nil {
+		return 0
+	}
+	return readField(rv, m.id).Int()
+}
+
+// UnmarshalNote converts the info of a note into a struct of type T, the reverse of MarshalNote.
+// The fields of the note without a matching struct field are ignored.
+// The model and the tags of the note are set on the fields tagged with `anki:",model"` and `anki:",tags"`,
+// the note id on the field tagged with `anki:",id"`.
+// The function returns an error if T is not a struct or a field value cannot be converted.
+func UnmarshalNote[T any](info ResultNotesInfo) (T, error) {
+	var v T
+	rv := reflect.ValueOf(&v).Elem()
+	if rv.Kind() == reflect.Pointer {
+		rv.Set(reflect.New(rv.Type().Elem()))
+		rv = rv.Elem()
+	}
+	m, err := mappingOf(rv.Type())
+	if err != nil {
+		return v, err
+	}
+
+	for _, f := range m.fields {
+		data, ok := info.Fields[f.name]
+		if !ok {
+			continue
+		}
+		field, err := writeField(rv, f.index)
+		if err != nil {
+			return v, err
+		}
+		if err := parseField(field, data.Value); err != nil {
+			return v, fmt.Errorf("ankiconnect: field %s: %w", f.name, err)
+		}
+	}
+	if m.model != nil {
+		field, err := writeField(rv, m.model)
+		if err != nil {
+			return v, err
+		}
+		field.SetString(info.ModelName)
+	}
+	if m.tags != nil {
+		field, err := writeField(rv, m.tags)
+		if err != nil {
+			return v, err
+		}
+		field.Set(reflect.ValueOf(append([]string(nil), info.Tags...)).Convert(field.Type()))
+	}
+	if m.id != nil {
+		field, err := writeField(rv, m.id)
+		if err != nil {
+			return v, err
+		}
+		field.SetInt(info.NoteId)
+	}
+	return v, nil
+}
+
+// ValidateNote checks that the fields of the note match the fields of its model.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error, e.g. the model does not exist.
+//   - the note does not have all the fields of the model or has fields that the model does not have,
+//     the error is a *FieldMismatchError.
+func ValidateNote(models ModelsManager, note Note) error {
+	modelFields, err := models.GetFields(note.ModelName)
+	if err != nil {
+		return err
+	}
+	return checkFields(note.ModelName, *modelFields, note.Fields)
+}
+
+// checkFields compares the fields of a note with the fields of its model.
+func checkFields(model string, modelFields []string, fields Fields) error {
+	known := map[string]bool{}
+	mismatch := &FieldMismatchError{Model: model}
+	for _, name := range modelFields {
+		known[name] = true
+		if _, ok := fields[name]; !ok {
+			mismatch.Missing = append(mismatch.Missing, name)
+		}
+	}
+	for name := range fields {
+		if !known[name] {
+			mismatch.Extra = append(mismatch.Extra, name)
+		}
+	}
+	if len(mismatch.Missing) == 0 && len(mismatch.Extra) == 0 {
+		return nil
+	}
+	sort.Strings(mismatch.Extra)
+	return mismatch
+}
+
+// Error implements the error interface.
+func (e *FieldMismatchError) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, "missing fields "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Extra) > 0 {
+		parts = append(parts, "unknown fields "+strings.Join(e.Extra, ", "))
+	}
+	return fmt.Sprintf("ankiconnect: model %s: %s", e.Model, strings.Join(parts, "; "))
+}
+
+// Unwrap returns ErrFieldMismatch.
+func (e *FieldMismatchError) Unwrap() error {
+	return ErrFieldMismatch
+}
+
+// mappingOf returns the cached mapping of a struct type.
+func mappingOf(t reflect.Type) (*noteMapping, error) {
+	if m, ok := mappings.Load(t); ok {
+		return m.(*noteMapping), nil
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("ankiconnect: cannot map %s to a note, a struct is required", t)
+	}
+
+	m := &noteMapping{}
+	names := map[string]bool{}
+	for _, sf := range reflect.VisibleFields(t) {
+		tag, ok := sf.Tag.Lookup(mappingTag)
+		if !ok || tag == "-" || !sf.IsExported() {
+			continue
+		}
+		name, option, _ := strings.Cut(tag, ",")
+		if option == "" {
+			if name == "" {
+				return nil, fmt.Errorf("ankiconnect: %s.%s: empty field name", t, sf.Name)
+			}
+			if names[name] {
+				return nil, fmt.Errorf("ankiconnect: %s.%s: duplicate field %s", t, sf.Name, name)
+			}
+			if !isFieldType(sf.Type) {
+				return nil, fmt.Errorf("ankiconnect: %s.%s: unsupported field type %s", t, sf.Name, sf.Type)
+			}
+			names[name] = true
+			m.fields = append(m.fields, fieldMapping{name: name, index: sf.Index})
+			continue
+		}
+
+		var target *[]int
+		var kind reflect.Kind
+		switch option {
+		case mappingOptionDeck:
+			target, kind = &m.deck, reflect.String
+		case mappingOptionModel:
+			target, kind = &m.model, reflect.String
+		case mappingOptionTags:
+			target, kind = &m.tags, reflect.Slice
+		case mappingOptionID:
+			target, kind = &m.id, reflect.Int64
+		default:
+			return nil, fmt.Errorf("ankiconnect: %s.%s: unknown option %q", t, sf.Name, option)
+		}
+		if sf.Type.Kind() != kind || (kind == reflect.Slice && sf.Type.Elem().Kind() != reflect.String) {
+			return nil, fmt.Errorf("ankiconnect: %s.%s: the %s field must be of type %s", t, sf.Name, option, optionTypes[option])
+		}
+		if *target != nil {
+			return nil, fmt.Errorf("ankiconnect: %s.%s: duplicate %s field", t, sf.Name, option)
+		}
+		*target = sf.Index
+	}
+	mappings.Store(t, m)
+	return m, nil
+}
+
+// readField returns the nested struct field of v with the index.
+// The zero value of the field is returned if an embedded struct pointer on the way is nil.
+func readField(v reflect.Value, index []int) reflect.Value {
+	if f, err := v.FieldByIndexErr(index); err == nil {
+		return f
+	}
+	return reflect.Zero(v.Type().FieldByIndex(index).Type)
+}
+
+// writeField returns the settable nested struct field of v with the index, allocating the nil
+// embedded struct pointers on the way. It returns an error if such a pointer cannot be set
+// because its struct type is not exported.
+func writeField(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, fmt.Errorf("ankiconnect: cannot set a field of the nil embedded %s", v.Type())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
+
+var optionTypes = map[string]string{
+	mappingOptionDeck:  "string",
+	mappingOptionModel: "string",
+	mappingOptionTags:  "[]string",
+	mappingOptionID:    "int64",
+}
+
+// isFieldType reports whether a struct field of type t can be converted to and from a note field.
+func isFieldType(t reflect.Type) bool {
+	if t.Implements(textMarshalerType) && reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// formatField converts a struct field value to the content of a note field.
+func formatField(v reflect.Value) (string, error) {
+	if v.Type().Implements(textMarshalerType) {
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), nil
+	}
+	return "", fmt.Errorf("unsupported type %s", v.Type())
+}
+
+// parseField sets a struct field from the content of a note field.
+// Empty contents set the zero value for booleans and numbers.
+func parseField(v reflect.Value, s string) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+	if v.Kind() == reflect.String {
+		v.SetString(s)
+		return nil
+	}
+	if s = strings.TrimSpace(s); s == "" {
+		v.SetZero()
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
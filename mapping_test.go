@@ -0,0 +1,250 @@
+package ankiconnect
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	vocab struct {
+		ID      int64    `anki:",id"`
+		Word    string   `anki:"Front"`
+		Meaning string   `anki:"Back"`
+		Level   int      `anki:"Level"`
+		Common  bool     `anki:"Common"`
+		Reading upper    `anki:"Reading"`
+		Tags    []string `anki:",tags"`
+		Notes   string   `anki:"-"`
+		Ignored string
+	}
+
+	// upper stores its value in upper case in Anki.
+	upper string
+
+	card struct {
+		Deck  string `anki:",deck"`
+		Model string `anki:",model"`
+		Front string `anki:"Front"`
+	}
+
+	invalidMapping struct {
+		Values []string `anki:"Values"`
+	}
+
+	// Labels is a named tags type.
+	Labels []string
+
+	// Meta is embedded by pointer to check that nil embedded structs are handled.
+	Meta struct {
+		ID   int64  `anki:",id"`
+		Tags Labels `anki:",tags"`
+	}
+
+	meta struct {
+		Source string `anki:"Source"`
+	}
+
+	embedded struct {
+		*Meta
+		Front string `anki:"Front"`
+	}
+
+	unexportedEmbedded struct {
+		*meta
+		Front string `anki:"Front"`
+	}
+)
+
+func (vocab) AnkiDeck() string  { return "Japanese" }
+func (vocab) AnkiModel() string { return "Vocab" }
+
+func (card) AnkiDeck() string { return "Fallback" }
+
+func (u upper) MarshalText() ([]byte, error) {
+	return []byte(strings.ToUpper(string(u))), nil
+}
+
+func (u *upper) UnmarshalText(text []byte) error {
+	*u = upper(strings.ToLower(string(text)))
+	return nil
+}
+
+func TestMarshalNote(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		note, err := MarshalNote(vocab{
+			ID:      1,
+			Word:    "taberu",
+			Meaning: "to eat",
+			Level:   5,
+			Common:  true,
+			Reading: "taberu",
+			Tags:    []string{"verb"},
+			Notes:   "not mapped",
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, Note{
+			DeckName:  "Japanese",
+			ModelName: "Vocab",
+			Fields: Fields{
+				"Front":   "taberu",
+				"Back":    "to eat",
+				"Level":   "5",
+				"Common":  "true",
+				"Reading": "TABERU",
+			},
+			Tags: []string{"verb"},
+		}, note)
+	})
+
+	t.Run("tagged fields take precedence", func(t *testing.T) {
+		note, err := MarshalNote(&card{Deck: "Custom", Model: "Basic", Front: "a"})
+		assert.NoError(t, err)
+		assert.Equal(t, "Custom", note.DeckName)
+		assert.Equal(t, "Basic", note.ModelName)
+
+		note, err = MarshalNote(card{Model: "Basic"})
+		assert.NoError(t, err)
+		assert.Equal(t, "Fallback", note.DeckName)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := MarshalNote("not a struct")
+		assert.EqualError(t, err, "ankiconnect: cannot map string to a note, a struct is required")
+
+		_, err = MarshalNote((*card)(nil))
+		assert.Error(t, err)
+
+		_, err = MarshalNote(invalidMapping{})
+		assert.EqualError(t, err, "ankiconnect: ankiconnect.invalidMapping.Values: unsupported field type []string")
+
+		_, err = MarshalNote(struct {
+			Deck int `anki:",deck"`
+		}{})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "the deck field must be of type string")
+	})
+}
+
+func TestMarshalNote_Embedded(t *testing.T) {
+	note, err := MarshalNote(embedded{Meta: &Meta{ID: 7, Tags: Labels{"verb"}}, Front: "taberu"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"verb"}, note.Tags)
+	assert.Equal(t, int64(7), NoteID(embedded{Meta: &Meta{ID: 7}}))
+
+	// the fields of a nil embedded struct are zero values
+	note, err = MarshalNote(embedded{Front: "taberu"})
+	assert.NoError(t, err)
+	assert.Empty(t, note.Tags)
+	assert.Equal(t, int64(0), NoteID(embedded{}))
+
+	note, err = MarshalNote(unexportedEmbedded{Front: "taberu"})
+	assert.NoError(t, err)
+	assert.Equal(t, Fields{"Front": "taberu", "Source": ""}, note.Fields)
+}
+
+func TestUnmarshalNote_Embedded(t *testing.T) {
+	info := ResultNotesInfo{
+		NoteId: 7,
+		Fields: map[string]FieldData{"Front": {Value: "taberu"}, "Source": {Value: "book"}},
+		Tags:   []string{"verb"},
+	}
+	v, err := UnmarshalNote[embedded](info)
+	assert.NoError(t, err)
+	assert.Equal(t, embedded{Meta: &Meta{ID: 7, Tags: Labels{"verb"}}, Front: "taberu"}, v)
+
+	_, err = UnmarshalNote[unexportedEmbedded](info)
+	assert.Error(t, err)
+}
+
+func TestUnmarshalNote(t *testing.T) {
+	info := ResultNotesInfo{
+		NoteId:    42,
+		ModelName: "Vocab",
+		Fields: map[string]FieldData{
+			"Front":   {Value: "taberu"},
+			"Back":    {Value: "to eat", Order: 1},
+			"Level":   {Value: " 5 "},
+			"Common":  {Value: ""},
+			"Reading": {Value: "TABERU"},
+			"Extra":   {Value: "ignored"},
+		},
+		Tags: []string{"verb"},
+	}
+
+	t.Run("success", func(t *testing.T) {
+		v, err := UnmarshalNote[vocab](info)
+		assert.NoError(t, err)
+		assert.Equal(t, vocab{
+			ID:      42,
+			Word:    "taberu",
+			Meaning: "to eat",
+			Level:   5,
+			Reading: "taberu",
+			Tags:    []string{"verb"},
+		}, v)
+		assert.Equal(t, int64(42), NoteID(v))
+
+		c, err := UnmarshalNote[*card](info)
+		assert.NoError(t, err)
+		assert.Equal(t, &card{Model: "Vocab", Front: "taberu"}, c)
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		info.Fields["Level"] = FieldData{Value: "five"}
+		_, err := UnmarshalNote[vocab](info)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "ankiconnect: field Level:")
+	})
+}
+
+func TestValidateNote(t *testing.T) {
+	modelFieldsPayload := []byte(`{
+    "action": "modelFieldNames",
+    "version": 6,
+    "params": {
+        "modelName": "Basic"
+    }
+  }`)
+	modelFieldsResult := []byte(`{
+    "result": ["Front", "Back"],
+    "error": null
+  }`)
+
+	t.Run("success", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t, modelFieldsPayload, modelFieldsResult)
+
+		err := ValidateNote(client.Models, Note{ModelName: "Basic", Fields: Fields{"Front": "a", "Back": "b"}})
+		assert.NoError(t, err)
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t, modelFieldsPayload, modelFieldsResult)
+
+		err := ValidateNote(client.Models, Note{ModelName: "Basic", Fields: Fields{"Front": "a", "Extra": "b", "Notes": "c"}})
+		assert.ErrorIs(t, err, ErrFieldMismatch)
+		var mismatch *FieldMismatchError
+		if assert.True(t, errors.As(err, &mismatch)) {
+			assert.Equal(t, []string{"Back"}, mismatch.Missing)
+			assert.Equal(t, []string{"Extra", "Notes"}, mismatch.Extra)
+		}
+		assert.EqualError(t, err, "ankiconnect: model Basic: missing fields Back; unknown fields Extra, Notes")
+	})
+
+	t.Run("error", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerErrorResponse(t)
+
+		err := ValidateNote(client.Models, Note{ModelName: "Basic"})
+		assertRestErr(t, err, http.StatusBadRequest, "some error message")
+	})
+}
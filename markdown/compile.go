@@ -0,0 +1,448 @@
+package markdown
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/atselvan/ankiconnect"
+	"github.com/atselvan/ankiconnect/internal/medianame"
+	"github.com/atselvan/ankiconnect/query"
+)
+
+// The results of compiling a note.
+const (
+	StatusCreated   Status = "created"
+	StatusUpdated   Status = "updated"
+	StatusUnchanged Status = "unchanged"
+	StatusFailed    Status = "failed"
+)
+
+const (
+	// IDTagPrefix is the prefix of the tag that stores the id of a note in Anki.
+	IDTagPrefix = "mdid::"
+
+	defaultModel      = "Basic"
+	defaultClozeModel = "Cloze"
+
+	notAddedErrMsg    = "the note could not be added"
+	modelErrMsg       = "the note %d has the model %s instead of %s"
+	fewFieldsErrMsg   = "markdown: model %s has less than 2 fields"
+	noDeckErrMsg      = "markdown: no deck set in the front matter or the options"
+	duplicateIDErrMsg = "the id is used by %d notes"
+)
+
+type (
+	// Status is the result of compiling a note.
+	Status string
+
+	// Options configures a compilation. The front matter of the document overrides the deck and
+	// the models, its tags are added to the tags of the options.
+	Options struct {
+		// Deck is the deck of new notes, it is required if the document does not set it.
+		// A deck that does not exist is created. The deck of existing notes is not changed.
+		Deck string
+		// Model is the model of the notes, "Basic" if empty. The front is stored in the first field
+		// and the back in the second field of the model.
+		Model string
+		// ClozeModel is the model of the cloze notes, "Cloze" if empty. The text is stored in the first
+		// field and the back in the second field of the model.
+		ClozeModel string
+		// Tags are added to the new notes, in addition to the tag storing the id of the note.
+		Tags []string
+		// BaseDir is the directory the local images are resolved against, CompileFile uses the
+		// directory of the file.
+		BaseDir string
+	}
+
+	// Report describes the result of a compilation.
+	Report struct {
+		Created   int          `json:"created"`
+		Updated   int          `json:"updated"`
+		Unchanged int          `json:"unchanged"`
+		Failed    int          `json:"failed"`
+		Notes     []NoteResult `json:"notes"`
+	}
+
+	// NoteResult is the result of compiling a single note.
+	NoteResult struct {
+		ID     string `json:"id"`
+		Line   int    `json:"line"`
+		Status Status `json:"status"`
+		NoteId int64  `json:"noteId,omitempty"`
+		Error  string `json:"error,omitempty"`
+	}
+
+	// compiler holds the state of a compilation.
+	compiler struct {
+		client *ankiconnect.Client
+		opts   Options
+		fields map[string][]string
+		// media maps the paths of the local images to their names in the media folder.
+		media map[string]string
+	}
+
+	// compiled is a note of the document converted to an Anki note.
+	compiled struct {
+		note     ankiconnect.Note
+		existing int64
+		result   NoteResult
+	}
+)
+
+// CompileFile parses the Markdown file at path and compiles its notes.
+// The local images are resolved against the directory of the file unless opts.BaseDir is set.
+// See Compile for the errors returned.
+func CompileFile(client *ankiconnect.Client, path string, opts Options) (*Report, error) {
+	doc, err := ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if opts.BaseDir == "" {
+		opts.BaseDir = filepath.Dir(path)
+	}
+	return Compile(client, doc, opts)
+}
+
+// Compile adds the notes of the document to Anki, or updates the fields of the notes that were
+// added by a previous compilation. The notes are identified by a tag made of IDTagPrefix and the
+// id of the note, the notes whose fields are equal are left unchanged.
+// A note that cannot be rendered, added or updated is reported as failed in the report.
+// The method returns an error if:
+//   - no deck is set.
+//   - a model has less than two fields.
+//   - the api request to ankiconnect fails.
+//   - the api returns an error for a request concerning all notes.
+func Compile(client *ankiconnect.Client, doc *Document, opts Options) (*Report, error) {
+	fm := doc.FrontMatter
+	opts.Deck = first(fm.Deck, opts.Deck)
+	opts.Model = first(fm.Model, opts.Model, defaultModel)
+	opts.ClozeModel = first(fm.ClozeModel, opts.ClozeModel, defaultClozeModel)
+	opts.Tags = append(append([]string{}, opts.Tags...), fm.Tags...)
+	if opts.Deck == "" {
+		return nil, errors.New(noDeckErrMsg)
+	}
+
+	c := &compiler{
+		client: client,
+		opts:   opts,
+		fields: map[string][]string{},
+		media:  map[string]string{},
+	}
+	notes := make([]*compiled, len(doc.Notes))
+	for i, n := range doc.Notes {
+		cn, err := c.convert(n)
+		if err != nil {
+			return nil, err
+		}
+		notes[i] = cn
+	}
+
+	if err := c.lookup(notes); err != nil {
+		return nil, err
+	}
+	var create, update []*compiled
+	for _, cn := range notes {
+		switch {
+		case cn.result.Status != "":
+		case cn.existing == 0:
+			create = append(create, cn)
+		default:
+			update = append(update, cn)
+		}
+	}
+	if err := c.ensureDeck(create); err != nil {
+		return nil, err
+	}
+	if err := c.create(create); err != nil {
+		return nil, err
+	}
+	if err := c.update(update); err != nil {
+		return nil, err
+	}
+
+	report := &Report{}
+	for _, cn := range notes {
+		report.add(cn.result)
+	}
+	return report, nil
+}
+
+// convert renders a note of the document to an Anki note.
+// Rendering errors are reported as a failed note, errors of ankiconnect are returned.
+func (c *compiler) convert(n Note) (*compiled, error) {
+	cn := &compiled{result: NoteResult{ID: n.ID, Line: n.Line}}
+	model := c.opts.Model
+	if n.Cloze {
+		model = c.opts.ClozeModel
+	}
+	fields, err := c.modelFields(model)
+	if err != nil {
+		return nil, err
+	}
+
+	front, err := c.html(n.Front, true)
+	if err != nil {
+		cn.fail(err.Error())
+		return cn, nil
+	}
+	back, err := c.html(n.Back, false)
+	if err != nil {
+		cn.fail(err.Error())
+		return cn, nil
+	}
+	cn.note = ankiconnect.Note{
+		DeckName:  c.opts.Deck,
+		ModelName: model,
+		Fields:    ankiconnect.Fields{fields[0]: front, fields[1]: back},
+		Tags:      append(append([]string{}, c.opts.Tags...), IDTagPrefix+n.ID),
+	}
+	return cn, nil
+}
+
+// html renders Markdown and stores its local images in the media folder.
+func (c *compiler) html(src string, inline bool) (string, error) {
+	if src == "" {
+		return "", nil
+	}
+	s, err := render(src, inline)
+	if err != nil {
+		return "", err
+	}
+	return rewriteImages(s, c.storeImage)
+}
+
+// storeImage stores a local image in the media folder and returns its name there.
+// The image is stored under its base name with a hash of its content appended, so that images of
+// the same name in different directories do not replace each other or the media of other notes.
+// Remote images and images that are already in the media folder are not changed.
+func (c *compiler) storeImage(src string) (string, error) {
+	if u, err := url.Parse(src); err != nil || u.Scheme != "" || u.Host != "" {
+		return src, nil
+	}
+	name, err := url.PathUnescape(src)
+	if err != nil {
+		name = src
+	}
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(c.opts.BaseDir, path)
+	}
+	if stored, ok := c.media[path]; ok {
+		return stored, nil
+	}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !strings.ContainsRune(name, '/') {
+		// a plain file name refers to a file in the media folder
+		return name, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	stored, err := c.client.Media.StoreMediaFile(medianame.Unique(path, content), base64.StdEncoding.EncodeToString(content))
+	if err != nil {
+		return "", err
+	}
+	c.media[path] = *stored
+	return *stored, nil
+}
+
+// modelFields returns the fields of the model, they are fetched once per model.
+func (c *compiler) modelFields(model string) ([]string, error) {
+	if fields, ok := c.fields[model]; ok {
+		return fields, nil
+	}
+	fields, err := c.client.Models.GetFields(model)
+	if err != nil {
+		return nil, err
+	}
+	if len(*fields) < 2 {
+		return nil, fmt.Errorf(fewFieldsErrMsg, model)
+	}
+	c.fields[model] = *fields
+	return *fields, nil
+}
+
+// lookup finds the notes carrying the id tags of the notes with a single multi request and marks
+// the notes whose fields are unchanged.
+func (c *compiler) lookup(notes []*compiled) error {
+	var lookups []*compiled
+	var actions []ankiconnect.MultiAction
+	for _, cn := range notes {
+		if cn.result.Status != "" {
+			continue
+		}
+		lookups = append(lookups, cn)
+		actions = append(actions, ankiconnect.MultiAction{
+			Action: ankiconnect.ActionFindNotes,
+			Params: ankiconnect.ParamsFindNotes{Query: query.Tag(IDTagPrefix + cn.result.ID).String()},
+		})
+	}
+	if len(actions) == 0 {
+		return nil
+	}
+	results, err := c.client.Multi(actions...)
+	if err != nil {
+		return err
+	}
+
+	var ids []int64
+	for i, result := range *results {
+		if i >= len(lookups) {
+			break
+		}
+		if result.Error != "" {
+			return fmt.Errorf("markdown: %s: %s", ankiconnect.ActionFindNotes, result.Error)
+		}
+		var found []int64
+		if err := json.Unmarshal(result.Result, &found); err != nil {
+			return err
+		}
+		switch len(found) {
+		case 0:
+		case 1:
+			lookups[i].existing = found[0]
+			ids = append(ids, found[0])
+		default:
+			lookups[i].fail(fmt.Sprintf(duplicateIDErrMsg, len(found)))
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	infos, err := c.client.Notes.Get(query.NoteIDs(ids...).String())
+	if err != nil {
+		return err
+	}
+	existing := map[int64]ankiconnect.ResultNotesInfo{}
+	for _, info := range *infos {
+		existing[info.NoteId] = info
+	}
+	for _, cn := range lookups {
+		info, ok := existing[cn.existing]
+		if cn.existing == 0 || !ok {
+			continue
+		}
+		cn.result.NoteId = cn.existing
+		switch {
+		case info.ModelName != cn.note.ModelName:
+			cn.fail(fmt.Sprintf(modelErrMsg, cn.existing, info.ModelName, cn.note.ModelName))
+		case equalFields(info, cn.note.Fields):
+			cn.result.Status = StatusUnchanged
+		}
+	}
+	return nil
+}
+
+// ensureDeck creates the deck if notes are added to it and it does not exist.
+func (c *compiler) ensureDeck(notes []*compiled) error {
+	if len(notes) == 0 {
+		return nil
+	}
+	decks, err := c.client.Decks.GetAll()
+	if err != nil {
+		return err
+	}
+	for _, deck := range *decks {
+		if deck == c.opts.Deck {
+			return nil
+		}
+	}
+	return c.client.Decks.Create(c.opts.Deck)
+}
+
+// create adds the new notes with a single addNotes request.
+func (c *compiler) create(notes []*compiled) error {
+	if len(notes) == 0 {
+		return nil
+	}
+	list := make([]ankiconnect.Note, len(notes))
+	for i, cn := range notes {
+		list[i] = cn.note
+	}
+	ids, err := c.client.Notes.AddMany(list)
+	if err != nil {
+		return err
+	}
+	for i, cn := range notes {
+		if i >= len(*ids) || (*ids)[i] == 0 {
+			cn.fail(notAddedErrMsg)
+			continue
+		}
+		cn.result.Status, cn.result.NoteId = StatusCreated, (*ids)[i]
+	}
+	return nil
+}
+
+// update updates the fields of the changed notes with a single multi request.
+func (c *compiler) update(notes []*compiled) error {
+	if len(notes) == 0 {
+		return nil
+	}
+	actions := make([]ankiconnect.MultiAction, len(notes))
+	for i, cn := range notes {
+		actions[i] = ankiconnect.MultiAction{
+			Action: ankiconnect.ActionUpdateNoteFields,
+			Params: ankiconnect.ParamsUpdateNote{
+				Note: &ankiconnect.UpdateNote{Id: cn.existing, Fields: cn.note.Fields},
+			},
+		}
+	}
+	results, err := c.client.Multi(actions...)
+	if err != nil {
+		return err
+	}
+	for i, cn := range notes {
+		if i < len(*results) && (*results)[i].Error != "" {
+			cn.fail((*results)[i].Error)
+			continue
+		}
+		cn.result.Status = StatusUpdated
+	}
+	return nil
+}
+
+func (cn *compiled) fail(msg string) {
+	cn.result.Status, cn.result.Error = StatusFailed, msg
+}
+
+// add adds the result of a note to the report.
+func (rp *Report) add(result NoteResult) {
+	switch result.Status {
+	case StatusCreated:
+		rp.Created++
+	case StatusUpdated:
+		rp.Updated++
+	case StatusUnchanged:
+		rp.Unchanged++
+	case StatusFailed:
+		rp.Failed++
+	}
+	rp.Notes = append(rp.Notes, result)
+}
+
+// equalFields reports whether the fields of the note have the values of fields.
+func equalFields(info ankiconnect.ResultNotesInfo, fields ankiconnect.Fields) bool {
+	for name, value := range fields {
+		if info.Fields[name].Value != value {
+			return false
+		}
+	}
+	return true
+}
+
+// first returns the first non empty value.
+func first(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
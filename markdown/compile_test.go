@@ -0,0 +1,154 @@
+package markdown
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/atselvan/ankiconnect/ankiconnecttest"
+	"github.com/atselvan/ankiconnect/internal/medianame"
+	"github.com/atselvan/ankiconnect/query"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileFile(t *testing.T) {
+	server := ankiconnecttest.NewServer()
+	defer server.Close()
+
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "gopher.png"), []byte("png"), 0o644))
+	path := filepath.Join(dir, "go.md")
+	write := func(src string) {
+		assert.NoError(t, os.WriteFile(path, []byte(src), 0o644))
+	}
+	write("---\n" +
+		"deck: Go\n" +
+		"tags: [go]\n" +
+		"---\n" +
+		"## What does a nil channel do? <!-- id: nil-channel -->\n" +
+		"Blocks **forever**.\n" +
+		"\n" +
+		"![gopher](gopher.png)\n" +
+		"\n" +
+		"Q: A {{c1::buffered}} channel blocks when it is full.\n" +
+		"\n" +
+		"<!-- id: select -->\n" +
+		"Q: When does `default` run?\n" +
+		"A: When no case is ready.\n")
+
+	client := server.Client()
+	report, err := CompileFile(client, path, Options{Tags: []string{"markdown"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, report.Created)
+	if !assert.Len(t, report.Notes, 3) {
+		return
+	}
+
+	note, ok := server.Note(report.Notes[0].NoteId)
+	assert.True(t, ok)
+	assert.Equal(t, "Basic", note.Model)
+	assert.Equal(t, "What does a nil channel do?", note.Fields["Front"])
+	gopher := medianame.Unique("gopher.png", []byte("png"))
+	assert.Equal(t, "<p>Blocks <strong>forever</strong>.</p>\n<p><img src=\""+gopher+"\" alt=\"gopher\"></p>", note.Fields["Back"])
+	assert.ElementsMatch(t, []string{"markdown", "go", "mdid::nil-channel"}, note.Tags)
+	media, ok := server.Media(gopher)
+	assert.True(t, ok)
+	assert.Equal(t, "png", string(media))
+
+	cloze, ok := server.Note(report.Notes[1].NoteId)
+	assert.True(t, ok)
+	assert.Equal(t, "Cloze", cloze.Model)
+	assert.Equal(t, "A {{c1::buffered}} channel blocks when it is full.", cloze.Fields["Text"])
+
+	cards, err := client.Cards.Get(query.NoteIDs(report.Notes[2].NoteId).String())
+	assert.NoError(t, err)
+	if assert.Len(t, *cards, 1) {
+		assert.Equal(t, "Go", (*cards)[0].DeckName)
+	}
+
+	// compiling again only updates the changed notes
+	write("---\n" +
+		"deck: Go\n" +
+		"---\n" +
+		"## What does a nil channel do? <!-- id: nil-channel -->\n" +
+		"Blocks **forever**.\n" +
+		"\n" +
+		"![gopher](gopher.png)\n" +
+		"\n" +
+		"Q: A {{c1::buffered}} channel blocks when it is full.\n" +
+		"\n" +
+		"<!-- id: select -->\n" +
+		"Q: When does `default` run?\n" +
+		"A: When no other case is ready.\n")
+	again, err := CompileFile(client, path, Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, again.Created)
+	assert.Equal(t, 2, again.Unchanged)
+	assert.Equal(t, 1, again.Updated)
+	assert.Equal(t, NoteResult{ID: "select", Line: 12, Status: StatusUpdated, NoteId: report.Notes[2].NoteId}, again.Notes[2])
+	updated, _ := server.Note(report.Notes[2].NoteId)
+	assert.Equal(t, "<p>When no other case is ready.</p>", updated.Fields["Back"])
+	assert.Equal(t, "When does <code>default</code> run?", updated.Fields["Front"])
+}
+
+func TestCompile(t *testing.T) {
+	t.Run("no deck", func(t *testing.T) {
+		server := ankiconnecttest.NewServer()
+		defer server.Close()
+		_, err := Compile(server.Client(), &Document{}, Options{})
+		assert.EqualError(t, err, noDeckErrMsg)
+	})
+
+	t.Run("model changed", func(t *testing.T) {
+		server := ankiconnecttest.NewServer()
+		defer server.Close()
+		doc, err := Parse([]byte("## Front <!-- id: a -->\nBack\n"))
+		assert.NoError(t, err)
+		report, err := Compile(server.Client(), doc, Options{Deck: "Default"})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, report.Created)
+
+		report, err = Compile(server.Client(), doc, Options{Deck: "Default", Model: "Basic (and reversed card)"})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, report.Failed)
+		assert.Contains(t, report.Notes[0].Error, "has the model Basic instead of Basic (and reversed card)")
+	})
+
+	t.Run("same image names", func(t *testing.T) {
+		server := ankiconnecttest.NewServer()
+		defer server.Close()
+		dir := t.TempDir()
+		for _, sub := range []string{"a", "b"} {
+			assert.NoError(t, os.Mkdir(filepath.Join(dir, sub), 0o755))
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, sub, "diagram.png"), []byte(sub), 0o644))
+		}
+		doc, err := Parse([]byte("## A\n![a](a/diagram.png)\n\n## B\n![b](b/diagram.png)\n"))
+		assert.NoError(t, err)
+		report, err := Compile(server.Client(), doc, Options{Deck: "Default", BaseDir: dir})
+		assert.NoError(t, err)
+		if !assert.Equal(t, 2, report.Created) {
+			return
+		}
+		for i, sub := range []string{"a", "b"} {
+			name := medianame.Unique("diagram.png", []byte(sub))
+			note, ok := server.Note(report.Notes[i].NoteId)
+			assert.True(t, ok)
+			assert.Contains(t, note.Fields["Back"], `src="`+name+`"`)
+			media, ok := server.Media(name)
+			assert.True(t, ok)
+			assert.Equal(t, sub, string(media))
+		}
+	})
+
+	t.Run("missing image", func(t *testing.T) {
+		server := ankiconnecttest.NewServer()
+		defer server.Close()
+		doc, err := Parse([]byte("## Front\n![x](img/missing.png)\n\n## Remote\n![x](https://example.com/a.png)\n"))
+		assert.NoError(t, err)
+		report, err := Compile(server.Client(), doc, Options{Deck: "Default", BaseDir: t.TempDir()})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, report.Failed)
+		assert.Equal(t, 1, report.Created)
+		assert.Equal(t, StatusFailed, report.Notes[0].Status)
+	})
+}
@@ -0,0 +1,290 @@
+// Package markdown compiles Markdown study material into Anki notes.
+//
+// A document may start with a YAML front matter that sets the deck, the model and the tags of its notes:
+//
+//	---
+//	deck: Go::Concurrency
+//	model: Basic
+//	tags: [go, concurrency]
+//	---
+//
+// Notes are written as headings or as Q:/A: blocks. A heading of the note level (## by default)
+// is the front of a note and the content up to the next heading of the same or a higher level is
+// the back. A Q: line starts a note and an A: line starts its answer, the answer ends at the next
+// note, heading or thematic break (---). A note whose front contains a cloze deletion such as
+// {{c1::goroutine}} is a cloze note, its answer if any is the back extra.
+//
+//	## What does a nil channel do?
+//	Blocks forever on send and receive.
+//
+//	<!-- id: select-default -->
+//	Q: When does the default case of a select run?
+//	A: When no other case is ready.
+//
+//	Q: A {{c1::buffered}} channel only blocks when it is full.
+//
+// Every note has a stable id that is stored as a tag in Anki, so that compiling a document again
+// updates the notes instead of adding duplicates. The id is set with an <!-- id: ... --> comment on
+// the line before the note or at the end of its first line. Without comment the id is derived from
+// the front, so changing the front of such a note creates a new note.
+//
+// The bodies are converted to html: fenced code blocks keep their language as a language-* class,
+// MathJax \(...\) and \[...\] spans are preserved and local images are uploaded to the media folder.
+package markdown
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const defaultHeadingLevel = 2
+
+var (
+	idCommentPattern = regexp.MustCompile(`^\s*<!--\s*id:\s*([^\s]+?)\s*-->\s*$`)
+	idSuffixPattern  = regexp.MustCompile(`\s*<!--\s*id:\s*([^\s]+?)\s*-->\s*$`)
+	headingPattern   = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	fencePattern     = regexp.MustCompile("^\\s{0,3}(```|~~~)")
+	breakPattern     = regexp.MustCompile(`^\s{0,3}((-\s*){3,}|(\*\s*){3,}|(_\s*){3,})$`)
+	clozePattern     = regexp.MustCompile(`\{\{c\d+::`)
+)
+
+type (
+	// Document is a parsed Markdown file.
+	Document struct {
+		// Path is the path of the file, it is used to resolve local images.
+		Path string
+		// FrontMatter are the settings of the front matter of the document.
+		FrontMatter FrontMatter
+		Notes       []Note
+	}
+
+	// FrontMatter are the settings of a document.
+	FrontMatter struct {
+		Deck  string `yaml:"deck"`
+		Model string `yaml:"model"`
+		// ClozeModel is the model of the cloze notes.
+		ClozeModel string   `yaml:"cloze-model"`
+		Tags       []string `yaml:"tags"`
+		// Heading is the level of the headings that start notes, 2 if not set.
+		Heading int `yaml:"heading"`
+	}
+
+	// Note is a note of a document, the front and the back are Markdown.
+	Note struct {
+		ID    string
+		Front string
+		Back  string
+		Cloze bool
+		// Line is the 1-based line of the note in the document.
+		Line int
+	}
+
+	// parser holds the state of parsing a document.
+	parser struct {
+		doc       *Document
+		current   *Note
+		qa        bool
+		back      bool
+		pendingID string
+		lines     []string
+	}
+)
+
+// ParseFile parses the Markdown file at path.
+func ParseFile(path string) (*Document, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	doc.Path = path
+	return doc, nil
+}
+
+// Parse parses a Markdown document.
+// It returns an error if the front matter is invalid, a note has no front, a Q: block has no answer
+// and no cloze deletion, or two notes have the same id.
+func Parse(src []byte) (*Document, error) {
+	src = bytes.ReplaceAll(src, []byte("\r\n"), []byte("\n"))
+	doc := &Document{}
+	body, offset, err := splitFrontMatter(src, &doc.FrontMatter)
+	if err != nil {
+		return nil, err
+	}
+	if doc.FrontMatter.Heading <= 0 {
+		doc.FrontMatter.Heading = defaultHeadingLevel
+	}
+
+	p := &parser{doc: doc}
+	inFence := ""
+	for i, line := range strings.Split(body, "\n") {
+		number := offset + i + 1
+		if m := fencePattern.FindStringSubmatch(line); m != nil {
+			switch {
+			case inFence == "":
+				inFence = m[1]
+			case inFence == m[1]:
+				inFence = ""
+			}
+			p.add(line)
+			continue
+		}
+		if inFence != "" {
+			p.add(line)
+			continue
+		}
+
+		if m := idCommentPattern.FindStringSubmatch(line); m != nil {
+			p.pendingID = m[1]
+			continue
+		}
+		if m := headingPattern.FindStringSubmatch(line); m != nil {
+			level := len(m[1])
+			switch {
+			case level == doc.FrontMatter.Heading:
+				if err := p.start(m[2], number, true); err != nil {
+					return nil, err
+				}
+				continue
+			case level < doc.FrontMatter.Heading:
+				if err := p.end(); err != nil {
+					return nil, err
+				}
+				continue
+			case p.current != nil && !p.back:
+				// a sub heading of a Q: block ends the note
+				if err := p.end(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "Q:"):
+			if err := p.start(strings.TrimSpace(trimmed[2:]), number, false); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(trimmed, "A:") && p.current != nil && !p.back:
+			p.back = true
+			p.current.Front = strings.TrimSpace(strings.Join(p.lines, "\n"))
+			p.lines = []string{strings.TrimSpace(trimmed[2:])}
+		case breakPattern.MatchString(line) && p.isQA():
+			if err := p.end(); err != nil {
+				return nil, err
+			}
+		default:
+			p.add(line)
+		}
+	}
+	if err := p.end(); err != nil {
+		return nil, err
+	}
+
+	ids := map[string]int{}
+	for _, n := range doc.Notes {
+		if line, ok := ids[n.ID]; ok {
+			return nil, fmt.Errorf("line %d: duplicate note id %s, already used on line %d", n.Line, n.ID, line)
+		}
+		ids[n.ID] = n.Line
+	}
+	return doc, nil
+}
+
+// splitFrontMatter decodes the front matter and returns the body and the number of lines before it.
+func splitFrontMatter(src []byte, fm *FrontMatter) (string, int, error) {
+	s := string(src)
+	if !strings.HasPrefix(s, "---\n") {
+		return s, 0, nil
+	}
+	end := strings.Index(s[4:], "\n---")
+	if end < 0 {
+		return s, 0, nil
+	}
+	header := s[4 : 4+end]
+	rest := s[4+end+4:]
+	if i := strings.IndexByte(rest, '\n'); i >= 0 {
+		rest = rest[i+1:]
+	} else {
+		rest = ""
+	}
+	if err := yaml.Unmarshal([]byte(header), fm); err != nil {
+		return "", 0, fmt.Errorf("invalid front matter: %w", err)
+	}
+	return rest, strings.Count(s[:len(s)-len(rest)], "\n"), nil
+}
+
+// isQA reports whether the current note is a Q: block.
+func (p *parser) isQA() bool {
+	return p.current != nil && p.qa
+}
+
+func (p *parser) add(line string) {
+	if p.current != nil {
+		p.lines = append(p.lines, line)
+	}
+}
+
+// start ends the current note and starts a new one with the first line of its front.
+// Heading notes have the heading as front and their content as back.
+func (p *parser) start(first string, line int, heading bool) error {
+	if err := p.end(); err != nil {
+		return err
+	}
+	id := p.pendingID
+	if m := idSuffixPattern.FindStringSubmatchIndex(first); m != nil {
+		id = first[m[2]:m[3]]
+		first = first[:m[0]]
+	}
+	p.pendingID = ""
+	p.current = &Note{ID: id, Line: line}
+	p.qa = !heading
+	if heading {
+		p.current.Front = first
+		p.back = true
+		p.lines = nil
+		return nil
+	}
+	p.back = false
+	p.lines = []string{first}
+	return nil
+}
+
+// end completes the current note and adds it to the document.
+func (p *parser) end() error {
+	n := p.current
+	if n == nil {
+		return nil
+	}
+	p.current = nil
+	content := strings.TrimSpace(strings.Join(p.lines, "\n"))
+	p.lines = nil
+	if p.back {
+		n.Back = content
+	} else {
+		n.Front = content
+	}
+	if n.Front == "" {
+		return fmt.Errorf("line %d: the note has no front", n.Line)
+	}
+	n.Cloze = clozePattern.MatchString(n.Front)
+	if p.qa && !p.back && !n.Cloze {
+		return fmt.Errorf("line %d: the question has no answer", n.Line)
+	}
+	if n.ID == "" {
+		sum := sha1.Sum([]byte(n.Front))
+		n.ID = hex.EncodeToString(sum[:6])
+	}
+	p.doc.Notes = append(p.doc.Notes, *n)
+	return nil
+}
@@ -0,0 +1,123 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("headings and questions", func(t *testing.T) {
+		src := "---\n" +
+			"deck: Go::Channels\n" +
+			"model: Basic\n" +
+			"tags: [go, channels]\n" +
+			"---\n" +
+			"# Channels\n" +
+			"\n" +
+			"Introduction that is not a note.\n" +
+			"\n" +
+			"## What does a nil channel do? <!-- id: nil-channel -->\n" +
+			"\n" +
+			"Blocks forever.\n" +
+			"\n" +
+			"```go\n" +
+			"## not a heading\n" +
+			"Q: not a question\n" +
+			"```\n" +
+			"\n" +
+			"<!-- id: select-default -->\n" +
+			"Q: When does the default case\n" +
+			"of a select run?\n" +
+			"A: When no other case\n" +
+			"is ready.\n" +
+			"\n" +
+			"---\n" +
+			"\n" +
+			"Text after the break.\n" +
+			"\n" +
+			"Q: A {{c1::buffered}} channel blocks when it is full.\n"
+		doc, err := Parse([]byte(src))
+		assert.NoError(t, err)
+		assert.Equal(t, FrontMatter{
+			Deck:    "Go::Channels",
+			Model:   "Basic",
+			Tags:    []string{"go", "channels"},
+			Heading: 2,
+		}, doc.FrontMatter)
+		if !assert.Len(t, doc.Notes, 3) {
+			return
+		}
+		assert.Equal(t, Note{
+			ID:    "nil-channel",
+			Front: "What does a nil channel do?",
+			Back:  "Blocks forever.\n\n```go\n## not a heading\nQ: not a question\n```",
+			Line:  10,
+		}, doc.Notes[0])
+		assert.Equal(t, Note{
+			ID:    "select-default",
+			Front: "When does the default case\nof a select run?",
+			Back:  "When no other case\nis ready.",
+			Line:  20,
+		}, doc.Notes[1])
+		assert.True(t, doc.Notes[2].Cloze)
+		assert.Equal(t, "A {{c1::buffered}} channel blocks when it is full.", doc.Notes[2].Front)
+		assert.Len(t, doc.Notes[2].ID, 12)
+		assert.Equal(t, 29, doc.Notes[2].Line)
+	})
+
+	t.Run("heading level", func(t *testing.T) {
+		doc, err := Parse([]byte("---\nheading: 3\n---\n## Section\n### Front\nBack\n#### Detail\nMore\n## Other\n"))
+		assert.NoError(t, err)
+		if assert.Len(t, doc.Notes, 1) {
+			assert.Equal(t, "Front", doc.Notes[0].Front)
+			assert.Equal(t, "Back\n#### Detail\nMore", doc.Notes[0].Back)
+		}
+	})
+
+	t.Run("stable ids", func(t *testing.T) {
+		a, err := Parse([]byte("## Front\nBack\n"))
+		assert.NoError(t, err)
+		b, err := Parse([]byte("Intro\n\n## Front\nOther back\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, a.Notes[0].ID, b.Notes[0].ID)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			src string
+			err string
+		}{
+			{"---\ndeck: [\n---\n", "invalid front matter"},
+			{"Q: no answer\n\nQ: other\nA: answer\n", "line 1: the question has no answer"},
+			{"## \n", "line 1: the note has no front"},
+			{"## A <!-- id: x -->\n## B <!-- id: x -->\n", "line 2: duplicate note id x, already used on line 1"},
+		}
+		for _, tt := range tests {
+			_, err := Parse([]byte(tt.src))
+			if assert.Error(t, err, tt.src) {
+				assert.Contains(t, err.Error(), tt.err)
+			}
+		}
+	})
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		src    string
+		inline bool
+		want   string
+	}{
+		{"What is *bold*?", true, "What is <em>bold</em>?"},
+		{"First\n\nSecond", true, "<p>First</p>\n<p>Second</p>"},
+		{"Text", false, "<p>Text</p>"},
+		{"```go\nx := 1 < 2\n```", false, "<pre><code class=\"language-go\">x := 1 &lt; 2\n</code></pre>"},
+		{`Euler: \(e^{i\pi} + 1 = 0\) and \[a_1 * b_2 < c\]`, true, `Euler: \(e^{i\pi} + 1 = 0\) and \[a_1 * b_2 &lt; c\]`},
+		{"| a | b |\n|---|---|\n| 1 | 2 |", false, "<table>\n<thead>\n<tr>\n<th>a</th>\n<th>b</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td>1</td>\n<td>2</td>\n</tr>\n</tbody>\n</table>"},
+	}
+	for _, tt := range tests {
+		got, err := render(tt.src, tt.inline)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, got, tt.src)
+	}
+}
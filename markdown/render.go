@@ -0,0 +1,73 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	gmhtml "github.com/yuin/goldmark/renderer/html"
+)
+
+var (
+	mathPattern = regexp.MustCompile(`(?s)\\\((.+?)\\\)|\\\[(.+?)\\\]`)
+	// placeholderPattern matches the placeholders of the math spans, they contain no Markdown syntax.
+	placeholderPattern = regexp.MustCompile(`ANKIMATH(\d+)X`)
+	imagePattern       = regexp.MustCompile(`(<img\s[^>]*?src=")([^"]+)(")`)
+	paragraphPattern   = regexp.MustCompile(`(?s)^<p>(.*)</p>$`)
+
+	renderer = goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithRendererOptions(gmhtml.WithUnsafe()),
+	)
+)
+
+// render converts Markdown to html.
+// The MathJax spans are replaced by placeholders before the conversion, so that Markdown does not
+// interpret their backslashes and underscores, and restored afterwards. If inline is set the
+// paragraph of content consisting of a single paragraph is removed.
+func render(src string, inline bool) (string, error) {
+	var spans []string
+	src = mathPattern.ReplaceAllStringFunc(src, func(s string) string {
+		spans = append(spans, s)
+		return fmt.Sprintf("ANKIMATH%dX", len(spans)-1)
+	})
+
+	var buf bytes.Buffer
+	if err := renderer.Convert([]byte(src), &buf); err != nil {
+		return "", err
+	}
+	out := strings.TrimSpace(buf.String())
+	if inline {
+		if m := paragraphPattern.FindStringSubmatch(out); m != nil && !strings.Contains(m[1], "<p>") {
+			out = m[1]
+		}
+	}
+	return placeholderPattern.ReplaceAllStringFunc(out, func(s string) string {
+		var i int
+		fmt.Sscanf(s, "ANKIMATH%dX", &i)
+		return html.EscapeString(spans[i])
+	}), nil
+}
+
+// rewriteImages replaces the src of the img elements of the html with the result of replace.
+// The src is passed unescaped, an error stops the rewriting.
+func rewriteImages(s string, replace func(src string) (string, error)) (string, error) {
+	var err error
+	out := imagePattern.ReplaceAllStringFunc(s, func(tag string) string {
+		if err != nil {
+			return tag
+		}
+		m := imagePattern.FindStringSubmatch(tag)
+		var src string
+		src, err = replace(html.UnescapeString(m[2]))
+		if err != nil {
+			return tag
+		}
+		return m[1] + html.EscapeString(src) + m[3]
+	})
+	return out, err
+}
@@ -1,7 +1,10 @@
 package ankiconnect
 
 import (
-	"github.com/privatesquare/bkst-go-utils/utils/errors"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
 )
 
 const (
@@ -9,16 +12,18 @@ const (
 	ActionStoreMedia    = "storeMediaFile"
 	ActionGetMediaNames = "getMediaFileNames"
 	ActionDeleteMedia   = "deleteMediaFile"
+
+	mediaNotFoundErrMsg = "media file was not found: %s"
 )
 
 type (
 	// Media describes the interface that can be used to perform operations stored media.
 	MediaManager interface {
 		// Returns the contents of the file encoded in base64
-		RetrieveMediaFile(filename string) (*string, *errors.RestErr)
-		StoreMediaFile(filename string, encodedMediaContent string) (*string, *errors.RestErr)
-		GetMediaFileNames(pattern string) (*[]string, *errors.RestErr)
-		DeleteMediaFile(filename string) (*string, *errors.RestErr)
+		RetrieveMediaFile(filename string) (*string, error)
+		StoreMediaFile(filename string, encodedMediaContent string) (*string, error)
+		GetMediaFileNames(pattern string) (*[]string, error)
+		DeleteMediaFile(filename string) (*string, error)
 	}
 
 	ParamsRetrieveMediaFile struct {
@@ -48,16 +53,30 @@ type (
 // The result is a string with the base64-encoded contents.
 // The method returns an error if:
 //   - the api request to ankiconnect fails.
-//   - the api returns a http error.
-func (mm *mediaManager) RetrieveMediaFile(filename string) (*string, *errors.RestErr) {
+//   - the api returns an error.
+//   - the file does not exist, the error wraps ErrMediaNotFound.
+func (mm *mediaManager) RetrieveMediaFile(filename string) (*string, error) {
 	params := ParamsRetrieveMediaFile{
 		Filename: filename,
 	}
-	result, restErr := post[string](mm.Client, ActionRetrieveMedia, &params)
-	if restErr != nil {
-		return nil, restErr
+	result, err := post[json.RawMessage](mm.Client, ActionRetrieveMedia, &params)
+	if err != nil {
+		return nil, err
+	}
+	// ankiconnect returns false instead of an error for missing files
+	if bytes.Equal(bytes.TrimSpace(*result), []byte("false")) {
+		return nil, &Error{
+			Action:     ActionRetrieveMedia,
+			Message:    fmt.Sprintf(mediaNotFoundErrMsg, filename),
+			StatusCode: http.StatusNotFound,
+			Kind:       ErrMediaNotFound,
+		}
+	}
+	var content string
+	if err := json.Unmarshal(*result, &content); err != nil {
+		return nil, newTransportError(ActionRetrieveMedia, err)
 	}
-	return result, nil
+	return &content, nil
 }
 
 // StoreMediaFile store media file to Anki storage.
@@ -65,29 +84,29 @@ func (mm *mediaManager) RetrieveMediaFile(filename string) (*string, *errors.Res
 // The result is a name already stored media file.
 // The method returns an error if:
 //   - the api request to ankiconnect fails.
-//   - the api returns a http error.
-func (mm *mediaManager) StoreMediaFile(filename string, encodedMediaContent string) (*string, *errors.RestErr) {
+//   - the api returns an error.
+func (mm *mediaManager) StoreMediaFile(filename string, encodedMediaContent string) (*string, error) {
 	params := ParamsStoreMediaFile{
 		Filename: filename,
 		Data:     encodedMediaContent,
 	}
 
-	savedFileName, restErr := post[string](mm.Client, ActionStoreMedia, &params)
-	return savedFileName, restErr
+	savedFileName, err := post[string](mm.Client, ActionStoreMedia, &params)
+	return savedFileName, err
 }
 
 // GetMediaFileNames get array of media file names which match by pattern from Anki storage
 // The result is array of media file names
 // The method returns an error if:
 //   - the api request to ankiconnect fails.
-//   - the api returns a http error.
-func (mm *mediaManager) GetMediaFileNames(pattern string) (*[]string, *errors.RestErr) {
+//   - the api returns an error.
+func (mm *mediaManager) GetMediaFileNames(pattern string) (*[]string, error) {
 	params := ParamsGetMediaFileNames{
 		Pattern: pattern,
 	}
-	foundFileNames, restErr := post[[]string](mm.Client, ActionGetMediaNames, &params)
-	if restErr != nil {
-		return nil, restErr
+	foundFileNames, err := post[[]string](mm.Client, ActionGetMediaNames, &params)
+	if err != nil {
+		return nil, err
 	}
 	return foundFileNames, nil
 }
@@ -96,13 +115,13 @@ func (mm *mediaManager) GetMediaFileNames(pattern string) (*[]string, *errors.Re
 // The result is deleted media file name
 // The method returns an error if:
 //   - the api request to ankiconnect fails.
-func (mm *mediaManager) DeleteMediaFile(filename string) (*string, *errors.RestErr) {
+func (mm *mediaManager) DeleteMediaFile(filename string) (*string, error) {
 	params := ParamsDeleteMediaFile{
 		Filename: filename,
 	}
-	deletedFilename, restErr := post[string](mm.Client, ActionDeleteMedia, &params)
-	if restErr != nil {
-		return nil, restErr
+	deletedFilename, err := post[string](mm.Client, ActionDeleteMedia, &params)
+	if err != nil {
+		return nil, err
 	}
 	return deletedFilename, nil
 }
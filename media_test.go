@@ -29,9 +29,22 @@ func TestMediaManager_Retrieve(t *testing.T) {
 			retrieveMediaRequest,
 			retrieveMediaResult)
 
-		data, restErr := client.Media.RetrieveMediaFile("_hello.txt")
+		data, err := client.Media.RetrieveMediaFile("_hello.txt")
 		assert.Equal(t, "SGVsbG8sIHdvcmxkIQ==", *data)
-		assert.Nil(t, restErr)
+		assert.Nil(t, err)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t,
+			retrieveMediaRequest,
+			[]byte(`{"result": false, "error": null}`))
+
+		data, err := client.Media.RetrieveMediaFile("_hello.txt")
+		assert.Nil(t, data)
+		assert.ErrorIs(t, err, ErrMediaNotFound)
+		assertRestErr(t, err, http.StatusNotFound, "media file was not found: _hello.txt")
 	})
 
 	t.Run("error", func(t *testing.T) {
@@ -39,11 +52,10 @@ func TestMediaManager_Retrieve(t *testing.T) {
 
 		registerErrorResponse(t)
 
-		data, restErr := client.Media.RetrieveMediaFile("_hello.txt")
+		data, err := client.Media.RetrieveMediaFile("_hello.txt")
 		assert.Nil(t, data)
-		assert.NotNil(t, restErr)
-		assert.Equal(t, http.StatusBadRequest, restErr.StatusCode)
-		assert.Equal(t, "some error message", restErr.Message)
+		assert.NotNil(t, err)
+		assertRestErr(t, err, http.StatusBadRequest, "some error message")
 	})
 }
 
@@ -79,11 +91,10 @@ func TestMediaManager_Store(t *testing.T) {
 
 		registerErrorResponse(t)
 
-		savedFileName, restErr := client.Media.StoreMediaFile("_test_store.txt", "some media content")
-		assert.NotNil(t, restErr)
+		savedFileName, err := client.Media.StoreMediaFile("_test_store.txt", "some media content")
+		assert.NotNil(t, err)
 		assert.Nil(t, savedFileName)
-		assert.Equal(t, http.StatusBadRequest, restErr.StatusCode)
-		assert.Equal(t, "some error message", restErr.Message)
+		assertRestErr(t, err, http.StatusBadRequest, "some error message")
 	})
 }
 
@@ -106,8 +117,8 @@ func TestManager_Get(t *testing.T) {
 
 		registerVerifiedPayload(t, request, response)
 
-		filenames, restErr := client.Media.GetMediaFileNames("*")
-		assert.Nil(t, restErr)
+		filenames, err := client.Media.GetMediaFileNames("*")
+		assert.Nil(t, err)
 		assert.Equal(t, []string{"_test_file_1.txt", "_test_file_2.txt"}, *filenames)
 	})
 
@@ -116,11 +127,10 @@ func TestManager_Get(t *testing.T) {
 
 		registerErrorResponse(t)
 
-		filenames, restErr := client.Media.GetMediaFileNames("*")
-		assert.NotNil(t, restErr)
+		filenames, err := client.Media.GetMediaFileNames("*")
+		assert.NotNil(t, err)
 		assert.Nil(t, filenames)
-		assert.Equal(t, http.StatusBadRequest, restErr.StatusCode)
-		assert.Equal(t, "some error message", restErr.Message)
+		assertRestErr(t, err, http.StatusBadRequest, "some error message")
 	})
 }
 
@@ -142,8 +152,8 @@ func TestManager_Delete(t *testing.T) {
 
 		registerVerifiedPayload(t, request, response)
 
-		deletedFileName, restErr := client.Media.DeleteMediaFile("_delete_file_name.txt")
-		assert.Nil(t, restErr)
+		deletedFileName, err := client.Media.DeleteMediaFile("_delete_file_name.txt")
+		assert.Nil(t, err)
 		assert.Equal(t, "_delete_file_name.txt", *deletedFileName)
 	})
 
@@ -152,10 +162,9 @@ func TestManager_Delete(t *testing.T) {
 
 		registerErrorResponse(t)
 
-		deletedFileName, restErr := client.Media.DeleteMediaFile("_delete_file_name.txt")
-		assert.NotNil(t, restErr)
+		deletedFileName, err := client.Media.DeleteMediaFile("_delete_file_name.txt")
+		assert.NotNil(t, err)
 		assert.Nil(t, deletedFileName)
-		assert.Equal(t, http.StatusBadRequest, restErr.StatusCode)
-		assert.Equal(t, "some error message", restErr.Message)
+		assertRestErr(t, err, http.StatusBadRequest, "some error message")
 	})
 }
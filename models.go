@@ -2,21 +2,26 @@ package ankiconnect
 
 import (
 	"encoding/json"
-	"github.com/privatesquare/bkst-go-utils/utils/errors"
+	"fmt"
+	"net/http"
 )
 
 const (
-	ActionModelNames      = "modelNames"
-	ActionModelFieldNames = "modelFieldNames"
-	ActionCreateModel     = "createModel"
+	ActionModelNames       = "modelNames"
+	ActionModelFieldNames  = "modelFieldNames"
+	ActionCreateModel      = "createModel"
+	ActionFindModelsByName = "findModelsByName"
+
+	modelNotFoundErrMsg = "model was not found: %s"
 )
 
 type (
 	// Models Manager is used for creating various card and note types within Anki
 	ModelsManager interface {
-		Create(model Model) *errors.RestErr
-		GetAll() (*[]string, *errors.RestErr)
-		GetFields(model string) (*[]string, *errors.RestErr)
+		Create(model Model) error
+		GetAll() (*[]string, error)
+		GetFields(model string) (*[]string, error)
+		GetSortField(model string) (*string, error)
 	}
 
 	// ParamsCreateModel is used for creating a new Note type to add a new card to an
@@ -49,6 +54,11 @@ type (
 		ModelName string `json:"modelName"`
 	}
 
+	// ParamsFindModelsByName represents the ankiconnect API params for getting the models with the names.
+	ParamsFindModelsByName struct {
+		ModelNames *[]string `json:"modelNames,omitempty"`
+	}
+
 	// ResultCreateModel represents the ankiconnect API result from
 	// creating a new model (Note type)
 	//
@@ -96,31 +106,60 @@ type (
 
 // The method returns an error if:
 //   - the api request to ankiconnect fails.
-//   - the api returns a http error.
-func (mm *modelsManager) Create(model Model) *errors.RestErr {
+//   - the api returns an error.
+func (mm *modelsManager) Create(model Model) error {
 	// This thing returns a large complicated struct back, ignore it for now
-	_, restErr := post[ResultCreateModel](mm.Client, ActionCreateModel, &model)
-	if restErr != nil {
-		return restErr
+	_, err := post[ResultCreateModel](mm.Client, ActionCreateModel, &model)
+	if err != nil {
+		return err
 	}
 	return nil
 }
 
-func (mm *modelsManager) GetAll() (*[]string, *errors.RestErr) {
-	modelNames, restErr := post[[]string, ParamsDefault](mm.Client, ActionModelNames, nil)
-	if restErr != nil {
-		return nil, restErr
+func (mm *modelsManager) GetAll() (*[]string, error) {
+	modelNames, err := post[[]string, ParamsDefault](mm.Client, ActionModelNames, nil)
+	if err != nil {
+		return nil, err
 	}
 	return modelNames, nil
 }
-func (mm *modelsManager) GetFields(model string) (*[]string, *errors.RestErr) {
+func (mm *modelsManager) GetFields(model string) (*[]string, error) {
 	modelName := ParamsModelNames{
 		ModelName: model,
 	}
-	modelFields, restErr := post[[]string](mm.Client, ActionModelFieldNames, &modelName)
-	if restErr != nil {
-		return nil, restErr
+	modelFields, err := post[[]string](mm.Client, ActionModelFieldNames, &modelName)
+	if err != nil {
+		return nil, err
 	}
 	return modelFields, nil
 
 }
+
+// GetSortField returns the name of the sort field of the model, the field shown in the sort field
+// column of the browser. It is the first field unless it was changed in the field settings of the model.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+//   - the model does not exist, the error wraps ErrModelNotFound.
+func (mm *modelsManager) GetSortField(model string) (*string, error) {
+	params := ParamsFindModelsByName{
+		ModelNames: &[]string{model},
+	}
+	models, err := post[[]ResultCreateModel](mm.Client, ActionFindModelsByName, &params)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range *models {
+		for _, field := range m.Flds {
+			if m.Name == model && field.Ord == m.Sortf {
+				return &field.Name, nil
+			}
+		}
+	}
+	return nil, &Error{
+		Action:     ActionFindModelsByName,
+		Message:    fmt.Sprintf(modelNotFoundErrMsg, model),
+		StatusCode: http.StatusNotFound,
+		Kind:       ErrModelNotFound,
+	}
+}
@@ -30,8 +30,8 @@ func TestModelsManager_Create(t *testing.T) {
 			loadTestPayload(t, ActionCreateModel),
 			loadTestResult(t, ActionCreateModel))
 
-		restErr := client.Models.Create(newModel)
-		assert.Nil(t, restErr)
+		err := client.Models.Create(newModel)
+		assert.Nil(t, err)
 	})
 
 	t.Run("realData", func(t *testing.T) {
@@ -44,8 +44,8 @@ func TestModelsManager_Create(t *testing.T) {
 			loadTestPayload(t, ActionCreateModel),
 			loadTestResult(t, ActionCreateModel+"Extra"))
 
-		restErr := client.Models.Create(newModel)
-		assert.Nil(t, restErr)
+		err := client.Models.Create(newModel)
+		assert.Nil(t, err)
 	})
 
 	t.Run("error", func(t *testing.T) {
@@ -53,10 +53,9 @@ func TestModelsManager_Create(t *testing.T) {
 
 		registerErrorResponse(t)
 
-		restErr := client.Models.Create(newModel)
-		assert.NotNil(t, restErr)
-		assert.Equal(t, http.StatusBadRequest, restErr.StatusCode)
-		assert.Equal(t, "some error message", restErr.Message)
+		err := client.Models.Create(newModel)
+		assert.NotNil(t, err)
+		assertRestErr(t, err, http.StatusBadRequest, "some error message")
 	})
 }
 
@@ -77,8 +76,8 @@ func TestModelsManager_GetAll(t *testing.T) {
 			modelNamesPayload,
 			modelNamesResult)
 
-		names, restErr := client.Models.GetAll()
-		assert.Nil(t, restErr)
+		names, err := client.Models.GetAll()
+		assert.Nil(t, err)
 		assert.Len(t, *names, 2)
 	})
 
@@ -87,11 +86,10 @@ func TestModelsManager_GetAll(t *testing.T) {
 
 		registerErrorResponse(t)
 
-		names, restErr := client.Models.GetAll()
+		names, err := client.Models.GetAll()
 		assert.Nil(t, names)
-		assert.NotNil(t, restErr)
-		assert.Equal(t, http.StatusBadRequest, restErr.StatusCode)
-		assert.Equal(t, "some error message", restErr.Message)
+		assert.NotNil(t, err)
+		assertRestErr(t, err, http.StatusBadRequest, "some error message")
 	})
 
 }
@@ -116,8 +114,8 @@ func TestModelsManager_GetFields(t *testing.T) {
 			modelFieldsPayload,
 			modelFieldsResult)
 
-		fields, restErr := client.Models.GetFields("Basic")
-		assert.Nil(t, restErr)
+		fields, err := client.Models.GetFields("Basic")
+		assert.Nil(t, err)
 		assert.Len(t, *fields, 2)
 	})
 
@@ -126,11 +124,58 @@ func TestModelsManager_GetFields(t *testing.T) {
 
 		registerErrorResponse(t)
 
-		fields, restErr := client.Models.GetFields("Basic")
+		fields, err := client.Models.GetFields("Basic")
 		assert.Nil(t, fields)
-		assert.NotNil(t, restErr)
-		assert.Equal(t, http.StatusBadRequest, restErr.StatusCode)
-		assert.Equal(t, "some error message", restErr.Message)
+		assert.NotNil(t, err)
+		assertRestErr(t, err, http.StatusBadRequest, "some error message")
 	})
 
 }
+
+func TestModelsManager_GetSortField(t *testing.T) {
+	findModelsPayload := []byte(`{
+    "action": "findModelsByName",
+    "version": 6,
+    "params": {
+        "modelNames": ["Vocab"]
+    }
+  }`)
+
+	t.Run("success", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t,
+			findModelsPayload,
+			[]byte(`{
+    "result": [{
+        "name": "Vocab",
+        "sortf": 1,
+        "flds": [{"name": "Word", "ord": 0}, {"name": "Reading", "ord": 1}]
+    }],
+    "error": null
+  }`))
+
+		field, err := client.Models.GetSortField("Vocab")
+		assert.NoError(t, err)
+		assert.Equal(t, "Reading", *field)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t, findModelsPayload, []byte(`{"result": [], "error": null}`))
+
+		field, err := client.Models.GetSortField("Vocab")
+		assert.Nil(t, field)
+		assert.ErrorIs(t, err, ErrModelNotFound)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerErrorResponse(t)
+
+		_, err := client.Models.GetSortField("Vocab")
+		assertRestErr(t, err, http.StatusBadRequest, "some error message")
+	})
+}
@@ -0,0 +1,54 @@
+package ankiconnect
+
+import "encoding/json"
+
+const (
+	ActionMulti = "multi"
+)
+
+type (
+	// MultiAction represents a single action executed as part of a multi request.
+	// The Version and Key are set by the client if they are not set explicitly.
+	MultiAction struct {
+		Action  string      `json:"action,omitempty"`
+		Version int         `json:"version,omitempty"`
+		Params  interface{} `json:"params,omitempty"`
+		Key     string      `json:"key,omitempty"`
+	}
+
+	// ParamsMulti represents the ankiconnect API params for executing multiple actions in one request.
+	ParamsMulti struct {
+		Actions []MultiAction `json:"actions"`
+	}
+)
+
+// Multi executes multiple actions in a single request.
+// The result contains the result or the error of every action in the order of the actions.
+// The raw json result of each action can be decoded with json.Unmarshal.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error for the multi action itself.
+func (c *Client) Multi(actions ...MultiAction) (*[]Result[json.RawMessage], error) {
+	params := ParamsMulti{
+		Actions: actions,
+	}
+	return post[[]Result[json.RawMessage]](c, ActionMulti, &params)
+}
+
+// prepareMulti returns a copy of the multi params in which the version and the api key
+// of the client are set on every nested action.
+func prepareMulti(params *ParamsMulti, version int, key string) *ParamsMulti {
+	prepared := &ParamsMulti{
+		Actions: make([]MultiAction, len(params.Actions)),
+	}
+	for i, action := range params.Actions {
+		if action.Version == 0 {
+			action.Version = version
+		}
+		if action.Key == "" {
+			action.Key = key
+		}
+		prepared.Actions[i] = action
+	}
+	return prepared
+}
@@ -0,0 +1,75 @@
+package ankiconnect
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Multi(t *testing.T) {
+	multiPayload := []byte(`{
+    "action": "multi",
+    "version": 6,
+    "key": "secret",
+    "params": {
+        "actions": [
+            {
+                "action": "deckNames",
+                "version": 6,
+                "key": "secret"
+            },
+            {
+                "action": "findNotes",
+                "version": 6,
+                "key": "secret",
+                "params": {
+                    "query": "deck:current"
+                }
+            }
+        ]
+    }
+}`)
+	multiResult := []byte(`{
+    "result": [
+        {"result": ["Default"], "error": null},
+        {"result": null, "error": "some error message"}
+    ],
+    "error": null
+}`)
+
+	t.Run("success", func(t *testing.T) {
+		defer httpmock.Reset()
+		defer client.SetAPIKey("")
+
+		registerVerifiedPayload(t, multiPayload, multiResult)
+
+		actions := []MultiAction{
+			{Action: ActionDeckNames},
+			{Action: ActionFindNotes, Params: ParamsFindNotes{Query: "deck:current"}},
+		}
+		results, err := client.SetAPIKey("secret").Multi(actions...)
+		assert.NoError(t, err)
+		assert.Len(t, *results, 2)
+
+		var decks []string
+		assert.NoError(t, json.Unmarshal((*results)[0].Result, &decks))
+		assert.Equal(t, []string{"Default"}, decks)
+		assert.Equal(t, "some error message", (*results)[1].Error)
+
+		// the actions of the caller are not modified
+		assert.Empty(t, actions[0].Key)
+		assert.Zero(t, actions[0].Version)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerErrorResponse(t)
+
+		_, err := client.Multi(MultiAction{Action: ActionDeckNames})
+		assertRestErr(t, err, http.StatusBadRequest, "some error message")
+	})
+}
@@ -1,6 +1,9 @@
 package ankiconnect
 
-import "github.com/privatesquare/bkst-go-utils/utils/errors"
+import (
+	"iter"
+	"strings"
+)
 
 const (
 	ActionFindNotes        = "findNotes"
@@ -9,15 +12,21 @@ const (
 	ActionAddNotes         = "addNotes"
 	ActionDeleteNotes      = "deleteNotes"
 	ActionUpdateNoteFields = "updateNoteFields"
+	ActionAddTags          = "addTags"
 )
 
 type (
 	// Notes manager describes the interface that can be used to perform operation on the notes in a deck.
 	NotesManager interface {
-		Add(note Note) *errors.RestErr
-		Search(query string) (*[]int64, *errors.RestErr)
-		Get(query string) (*[]ResultNotesInfo, *errors.RestErr)
-		Update(note UpdateNote) *errors.RestErr
+		Add(note Note) error
+		AddMany(notes []Note) (*[]int64, error)
+		Search(query string) (*[]int64, error)
+		Get(query string) (*[]ResultNotesInfo, error)
+		Iterate(query string, opts *IterateOptions) iter.Seq2[ResultNotesInfo, error]
+		Update(note UpdateNote) error
+		Delete(ids ...int64) error
+		AddTags(ids []int64, tags ...string) error
+		Replace(opts ReplaceOptions) (*ReplaceReport, error)
 	}
 
 	// notesManager implements NotesManager.
@@ -30,11 +39,28 @@ type (
 		Note *Note `json:"note,omitempty"`
 	}
 
+	// ParamsCreateNotes represents the ankiconnect API params for creating multiple notes.
+	ParamsCreateNotes struct {
+		Notes *[]Note `json:"notes,omitempty"`
+	}
+
 	// ParamsCreateNote represents the ankiconnect API params for updating a note.
 	ParamsUpdateNote struct {
 		Note *UpdateNote `json:"note,omitempty"`
 	}
 
+	// ParamsDeleteNotes represents the ankiconnect API params for deleting notes.
+	ParamsDeleteNotes struct {
+		Notes *[]int64 `json:"notes,omitempty"`
+	}
+
+	// ParamsAddTags represents the ankiconnect API params for adding tags to notes.
+	// Tags is a space separated list of tags.
+	ParamsAddTags struct {
+		Notes *[]int64 `json:"notes,omitempty"`
+		Tags  string   `json:"tags,omitempty"`
+	}
+
 	// ParamsGetNotes represents the ankiconnect API params for querying notes.
 	ParamsFindNotes struct {
 		Query string `json:"query,omitempty"`
@@ -133,29 +159,41 @@ type (
 // Add adds a new note in Anki.
 // The method returns an error if:
 //   - the api request to ankiconnect fails.
-//   - the api returns a http error.
-func (nm *notesManager) Add(note Note) *errors.RestErr {
+//   - the api returns an error.
+func (nm *notesManager) Add(note Note) error {
 	params := ParamsCreateNote{
 		Note: &note,
 	}
-	_, restErr := post[int64](nm.Client, ActionAddNote, &params)
-	if restErr != nil {
-		return restErr
+	_, err := post[int64](nm.Client, ActionAddNote, &params)
+	if err != nil {
+		return err
 	}
 	return nil
 }
 
-func (nm *notesManager) Search(query string) (*[]int64, *errors.RestErr) {
+// AddMany adds multiple notes in a single request and returns the ids of the new notes
+// in the order of the notes. The id is 0 for notes that could not be added, e.g. duplicates.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+func (nm *notesManager) AddMany(notes []Note) (*[]int64, error) {
+	params := ParamsCreateNotes{
+		Notes: &notes,
+	}
+	return post[[]int64](nm.Client, ActionAddNotes, &params)
+}
+
+func (nm *notesManager) Search(query string) (*[]int64, error) {
 	findParams := ParamsFindNotes{
 		Query: query,
 	}
 	return post[[]int64](nm.Client, ActionFindNotes, &findParams)
 }
 
-func (nm *notesManager) Get(query string) (*[]ResultNotesInfo, *errors.RestErr) {
-	noteIds, restErr := nm.Search(query)
-	if restErr != nil {
-		return nil, restErr
+func (nm *notesManager) Get(query string) (*[]ResultNotesInfo, error) {
+	noteIds, err := nm.Search(query)
+	if err != nil {
+		return nil, err
 	}
 	infoParams := ParamsNotesInfo{
 		Notes: noteIds,
@@ -163,12 +201,52 @@ func (nm *notesManager) Get(query string) (*[]ResultNotesInfo, *errors.RestErr)
 	return post[[]ResultNotesInfo](nm.Client, ActionNotesInfo, &infoParams)
 }
 
-func (nm *notesManager) Update(note UpdateNote) *errors.RestErr {
+// Iterate searches the notes matching the query and yields their info one by one,
+// fetching the info in pages so that large collections can be processed with bounded memory.
+// A nil opts uses the default IterateOptions. Breaking out of the loop stops fetching further pages.
+// The iteration yields an error and stops if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+func (nm *notesManager) Iterate(query string, opts *IterateOptions) iter.Seq2[ResultNotesInfo, error] {
+	return iterate(opts,
+		func() (*[]int64, error) { return nm.Search(query) },
+		func(ids []int64) (*[]ResultNotesInfo, error) {
+			return post[[]ResultNotesInfo](nm.Client, ActionNotesInfo, &ParamsNotesInfo{Notes: &ids})
+		},
+	)
+}
+
+func (nm *notesManager) Update(note UpdateNote) error {
 	params := ParamsUpdateNote{
 		Note: &note,
 	}
 	// The return of this should always be 'null' int64 may not be the best
 	// type here
-	_, restErr := post[int64](nm.Client, ActionUpdateNoteFields, &params)
-	return restErr
+	_, err := post[int64](nm.Client, ActionUpdateNoteFields, &params)
+	return err
+}
+
+// Delete deletes the notes with the ids and their cards.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+func (nm *notesManager) Delete(ids ...int64) error {
+	params := ParamsDeleteNotes{
+		Notes: &ids,
+	}
+	_, err := post[string](nm.Client, ActionDeleteNotes, &params)
+	return err
+}
+
+// AddTags adds the tags to the notes with the ids. Tags the notes already have are ignored.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+func (nm *notesManager) AddTags(ids []int64, tags ...string) error {
+	params := ParamsAddTags{
+		Notes: &ids,
+		Tags:  strings.Join(tags, " "),
+	}
+	_, err := post[interface{}](nm.Client, ActionAddTags, &params)
+	return err
 }
@@ -73,8 +73,8 @@ func TestNotesManager_Add(t *testing.T) {
 			addNoteResult)
 
 		note := createNoteStruct
-		restErr := client.Notes.Add(note)
-		assert.Nil(t, restErr)
+		err := client.Notes.Add(note)
+		assert.Nil(t, err)
 	})
 
 	t.Run("error", func(t *testing.T) {
@@ -83,10 +83,9 @@ func TestNotesManager_Add(t *testing.T) {
 		registerErrorResponse(t)
 
 		note := createNoteStruct
-		restErr := client.Notes.Add(note)
-		assert.NotNil(t, restErr)
-		assert.Equal(t, http.StatusBadRequest, restErr.StatusCode)
-		assert.Equal(t, "some error message", restErr.Message)
+		err := client.Notes.Add(note)
+		assert.NotNil(t, err)
+		assertRestErr(t, err, http.StatusBadRequest, "some error message")
 	})
 }
 
@@ -116,8 +115,8 @@ func TestNotesManager_Get(t *testing.T) {
 			})
 
 		payload := "deck:current"
-		notes, restErr := client.Notes.Get(payload)
-		assert.Nil(t, restErr)
+		notes, err := client.Notes.Get(payload)
+		assert.Nil(t, err)
 		assert.Equal(t, len(*notes), 1)
 
 	})
@@ -127,10 +126,9 @@ func TestNotesManager_Get(t *testing.T) {
 
 		registerErrorResponse(t)
 
-		_, restErr := client.Notes.Get("deck:current")
-		assert.NotNil(t, restErr)
-		assert.Equal(t, http.StatusBadRequest, restErr.StatusCode)
-		assert.Equal(t, "some error message", restErr.Message)
+		_, err := client.Notes.Get("deck:current")
+		assert.NotNil(t, err)
+		assertRestErr(t, err, http.StatusBadRequest, "some error message")
 	})
 }
 
@@ -160,9 +158,115 @@ func TestNotesManager_Update(t *testing.T) {
 			loadTestPayload(t, ActionUpdateNoteFields),
 			genericSuccessJson)
 
-		restErr := client.Notes.Update(updateNoteStruct)
-		assert.Nil(t, restErr)
+		err := client.Notes.Update(updateNoteStruct)
+		assert.Nil(t, err)
 
 	})
 
 }
+
+func TestNotesManager_Delete(t *testing.T) {
+	deleteNotesPayload := []byte(`{
+    "action": "deleteNotes",
+    "version": 6,
+    "params": {
+        "notes": [1502298033753, 1502298033754]
+    }
+  }`)
+
+	t.Run("success", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t,
+			deleteNotesPayload,
+			genericSuccessJson)
+
+		err := client.Notes.Delete(1502298033753, 1502298033754)
+		assert.Nil(t, err)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerErrorResponse(t)
+
+		err := client.Notes.Delete(1502298033753)
+		assert.NotNil(t, err)
+		assertRestErr(t, err, http.StatusBadRequest, "some error message")
+	})
+}
+
+func TestNotesManager_AddTags(t *testing.T) {
+	addTagsPayload := []byte(`{
+    "action": "addTags",
+    "version": 6,
+    "params": {
+        "notes": [1483959289817, 1483959291695],
+        "tags": "european-languages spanish"
+    }
+}`)
+
+	t.Run("success", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t,
+			addTagsPayload,
+			genericSuccessJson)
+
+		err := client.Notes.AddTags([]int64{1483959289817, 1483959291695}, "european-languages", "spanish")
+		assert.Nil(t, err)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerErrorResponse(t)
+
+		err := client.Notes.AddTags([]int64{1483959289817}, "spanish")
+		assert.NotNil(t, err)
+		assertRestErr(t, err, http.StatusBadRequest, "some error message")
+	})
+}
+
+func TestNotesManager_AddMany(t *testing.T) {
+	addNotesPayload := []byte(`{
+    "action": "addNotes",
+    "version": 6,
+    "params": {
+        "notes": [
+            {"deckName": "Default", "modelName": "Basic", "fields": {"Front": "front 1", "Back": "back 1"}},
+            {"deckName": "Default", "modelName": "Basic", "fields": {"Front": "front 2", "Back": "back 2"}}
+        ]
+    }
+  }`)
+	addNotesResult := []byte(`{
+    "result": [1659294247478, null],
+    "error": null
+  }`)
+	notes := []Note{
+		{DeckName: "Default", ModelName: "Basic", Fields: Fields{"Front": "front 1", "Back": "back 1"}},
+		{DeckName: "Default", ModelName: "Basic", Fields: Fields{"Front": "front 2", "Back": "back 2"}},
+	}
+
+	t.Run("success", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t,
+			addNotesPayload,
+			addNotesResult)
+
+		ids, err := client.Notes.AddMany(notes)
+		assert.Nil(t, err)
+		assert.Equal(t, []int64{1659294247478, 0}, *ids)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerErrorResponse(t)
+
+		ids, err := client.Notes.AddMany(notes)
+		assert.Nil(t, ids)
+		assertRestErr(t, err, http.StatusBadRequest, "some error message")
+	})
+}
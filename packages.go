@@ -0,0 +1,207 @@
+package ankiconnect
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/atselvan/ankiconnect/internal/search"
+)
+
+const (
+	ActionExportPackage = "exportPackage"
+	ActionImportPackage = "importPackage"
+
+	relativePathErrMsg  = "ankiconnect: the package path %s is not absolute"
+	directoryPathErrMsg = "ankiconnect: the package path %s is a directory"
+	exportFailedErrMsg  = "deck was not found: %s"
+	importFailedErrMsg  = "the package %s was not imported"
+	countNotesFailedMsg = "ankiconnect: %s: %s"
+)
+
+type (
+	// PackagesManager describes the interface that can be used to import and export Anki packages (.apkg files).
+	// The paths are paths on the machine running Anki.
+	PackagesManager interface {
+		Export(deck, path string, includeScheduling bool) error
+		Import(path string) (*ImportReport, error)
+	}
+
+	// ParamsExportPackage represents the ankiconnect API params for exporting a deck to a package.
+	ParamsExportPackage struct {
+		Deck         string `json:"deck,omitempty"`
+		Path         string `json:"path,omitempty"`
+		IncludeSched bool   `json:"includeSched,omitempty"`
+	}
+
+	// ParamsImportPackage represents the ankiconnect API params for importing a package.
+	ParamsImportPackage struct {
+		Path string `json:"path,omitempty"`
+	}
+
+	// ImportReport describes the changes of the collection made by importing a package.
+	// It is computed by comparing the decks and notes before and after the import,
+	// so changes made at the same time by other clients are included.
+	ImportReport struct {
+		// NewDecks are the decks that did not exist before the import, sorted.
+		NewDecks []string `json:"newDecks"`
+		// DeckNotes are the number of notes of each new deck, including its subdecks.
+		DeckNotes map[string]int `json:"deckNotes"`
+		// NotesBefore and NotesAfter are the number of notes of the collection before and after the import.
+		NotesBefore int `json:"notesBefore"`
+		NotesAfter  int `json:"notesAfter"`
+	}
+
+	// packagesManager implements PackagesManager.
+	packagesManager struct {
+		Client *Client
+	}
+)
+
+// NewNotes returns the number of notes added by the import.
+// Notes that were updated by the import are not counted.
+func (r *ImportReport) NewNotes() int {
+	return r.NotesAfter - r.NotesBefore
+}
+
+// Export exports the deck and its subdecks to a package at path, which must be an absolute path.
+// The scheduling information of the cards is included if includeScheduling is set.
+// The method returns an error if:
+//   - the path is not absolute.
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+//   - the deck does not exist, the error wraps ErrDeckNotFound.
+func (pm *packagesManager) Export(deck, path string, includeScheduling bool) error {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf(relativePathErrMsg, path)
+	}
+	params := ParamsExportPackage{
+		Deck:         deck,
+		Path:         path,
+		IncludeSched: includeScheduling,
+	}
+	exported, err := post[bool](pm.Client, ActionExportPackage, &params)
+	if err != nil {
+		return err
+	}
+	if !*exported {
+		// ankiconnect returns false instead of an error if the deck does not exist
+		return &Error{
+			Action:     ActionExportPackage,
+			Message:    fmt.Sprintf(exportFailedErrMsg, deck),
+			StatusCode: http.StatusNotFound,
+			Kind:       ErrDeckNotFound,
+		}
+	}
+	return nil
+}
+
+// Import imports the package at path, which must be an absolute path to an existing file.
+// The path is checked on the local machine, so Import can only be used with Anki running locally.
+// It returns a report of the decks and notes added by the import.
+// The method returns an error if:
+//   - the path is not absolute, does not exist or is a directory.
+//   - the api request to ankiconnect fails.
+//   - the api returns an error, e.g. the file is not a valid package.
+func (pm *packagesManager) Import(path string) (*ImportReport, error) {
+	if !filepath.IsAbs(path) {
+		return nil, fmt.Errorf(relativePathErrMsg, path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf(directoryPathErrMsg, path)
+	}
+
+	decksBefore, err := pm.Client.Decks.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	report := &ImportReport{DeckNotes: map[string]int{}}
+	if report.NotesBefore, err = pm.countNotes(); err != nil {
+		return nil, err
+	}
+
+	params := ParamsImportPackage{
+		Path: path,
+	}
+	imported, err := post[bool](pm.Client, ActionImportPackage, &params)
+	if err != nil {
+		return nil, err
+	}
+	if !*imported {
+		return nil, &Error{
+			Action:     ActionImportPackage,
+			Message:    fmt.Sprintf(importFailedErrMsg, path),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	decksAfter, err := pm.Client.Decks.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if report.NotesAfter, err = pm.countNotes(); err != nil {
+		return nil, err
+	}
+	existing := map[string]bool{}
+	for _, deck := range *decksBefore {
+		existing[deck] = true
+	}
+	for _, deck := range *decksAfter {
+		if !existing[deck] {
+			report.NewDecks = append(report.NewDecks, deck)
+		}
+	}
+	sort.Strings(report.NewDecks)
+	if err := pm.countDeckNotes(report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// countNotes returns the number of notes of the collection.
+func (pm *packagesManager) countNotes() (int, error) {
+	ids, err := pm.Client.Notes.Search(search.Term("deck", "*"))
+	if err != nil {
+		return 0, err
+	}
+	return len(*ids), nil
+}
+
+// countDeckNotes counts the notes of the new decks with a single multi request.
+func (pm *packagesManager) countDeckNotes(report *ImportReport) error {
+	if len(report.NewDecks) == 0 {
+		return nil
+	}
+	actions := make([]MultiAction, len(report.NewDecks))
+	for i, deck := range report.NewDecks {
+		actions[i] = MultiAction{
+			Action: ActionFindNotes,
+			Params: ParamsFindNotes{Query: search.Term("deck", search.EscapeValue(deck))},
+		}
+	}
+	results, err := pm.Client.Multi(actions...)
+	if err != nil {
+		return err
+	}
+	for i, result := range *results {
+		if i >= len(report.NewDecks) {
+			break
+		}
+		if result.Error != "" {
+			return fmt.Errorf(countNotesFailedMsg, ActionFindNotes, result.Error)
+		}
+		var ids []int64
+		if err := json.Unmarshal(result.Result, &ids); err != nil {
+			return err
+		}
+		report.DeckNotes[report.NewDecks[i]] = len(ids)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package ankiconnect
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackagesManager_Export(t *testing.T) {
+	path := filepath.Join(os.TempDir(), "deck.apkg")
+	exportRequest := []byte(`{
+    "action": "exportPackage",
+    "version": 6,
+    "params": {
+        "deck": "Japanese",
+        "path": "` + path + `",
+        "includeSched": true
+    }
+}`)
+
+	t.Run("success", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t, exportRequest, []byte(`{"result": true, "error": null}`))
+
+		err := client.Packages.Export("Japanese", path, true)
+		assert.Nil(t, err)
+	})
+
+	t.Run("deck not found", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t, exportRequest, []byte(`{"result": false, "error": null}`))
+
+		err := client.Packages.Export("Japanese", path, true)
+		assertRestErr(t, err, http.StatusNotFound, "deck was not found: Japanese")
+		assert.True(t, errors.Is(err, ErrDeckNotFound))
+	})
+
+	t.Run("relative path", func(t *testing.T) {
+		err := client.Packages.Export("Japanese", "deck.apkg", false)
+		assert.EqualError(t, err, "ankiconnect: the package path deck.apkg is not absolute")
+	})
+
+	t.Run("error", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerErrorResponse(t)
+
+		err := client.Packages.Export("Japanese", path, false)
+		assertRestErr(t, err, http.StatusBadRequest, "some error message")
+	})
+}
+
+func TestPackagesManager_Import(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deck.apkg")
+	assert.NoError(t, os.WriteFile(path, []byte("apkg"), 0o600))
+
+	deckNamesRequest := []byte(`{"action": "deckNames", "version": 6}`)
+	countRequest := []byte(`{"action": "findNotes", "version": 6, "params": {"query": "deck:*"}}`)
+	importRequest := []byte(`{"action": "importPackage", "version": 6, "params": {"path": "` + path + `"}}`)
+
+	t.Run("success", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerMultipleVerifiedPayloads(t, [][2][]byte{
+			{deckNamesRequest, []byte(`{"result": ["Default", "Japanese"], "error": null}`)},
+			{countRequest, []byte(`{"result": [1, 2], "error": null}`)},
+			{importRequest, []byte(`{"result": true, "error": null}`)},
+			{deckNamesRequest, []byte(`{"result": ["Default", "Japanese", "Spanish", "Spanish::Verbs"], "error": null}`)},
+			{countRequest, []byte(`{"result": [1, 2, 3, 4, 5], "error": null}`)},
+			{
+				[]byte(`{
+    "action": "multi",
+    "version": 6,
+    "params": {
+        "actions": [
+            {"action": "findNotes", "version": 6, "params": {"query": "deck:Spanish"}},
+            {"action": "findNotes", "version": 6, "params": {"query": "deck:Spanish::Verbs"}}
+        ]
+    }
+}`),
+				[]byte(`{"result": [{"result": [3, 4, 5], "error": null}, {"result": [5], "error": null}], "error": null}`),
+			},
+		})
+
+		report, err := client.Packages.Import(path)
+		assert.Nil(t, err)
+		assert.Equal(t, &ImportReport{
+			NewDecks:    []string{"Spanish", "Spanish::Verbs"},
+			DeckNotes:   map[string]int{"Spanish": 3, "Spanish::Verbs": 1},
+			NotesBefore: 2,
+			NotesAfter:  5,
+		}, report)
+		assert.Equal(t, 3, report.NewNotes())
+	})
+
+	t.Run("invalid path", func(t *testing.T) {
+		_, err := client.Packages.Import("deck.apkg")
+		assert.EqualError(t, err, "ankiconnect: the package path deck.apkg is not absolute")
+
+		_, err = client.Packages.Import(filepath.Join(filepath.Dir(path), "missing.apkg"))
+		assert.True(t, errors.Is(err, os.ErrNotExist))
+
+		_, err = client.Packages.Import(filepath.Dir(path))
+		assert.EqualError(t, err, "ankiconnect: the package path "+filepath.Dir(path)+" is a directory")
+	})
+
+	t.Run("error", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerMultipleVerifiedPayloads(t, [][2][]byte{
+			{deckNamesRequest, []byte(`{"result": ["Default"], "error": null}`)},
+			{countRequest, []byte(`{"result": [], "error": null}`)},
+			{importRequest, []byte(`{"result": null, "error": "invalid package"}`)},
+		})
+
+		report, err := client.Packages.Import(path)
+		assert.Nil(t, report)
+		assertRestErr(t, err, http.StatusBadRequest, "invalid package")
+	})
+}
@@ -0,0 +1,118 @@
+package ankiconnect
+
+import (
+	"iter"
+	"slices"
+)
+
+const (
+	// SortAsReturned keeps the ids in the order returned by ankiconnect.
+	SortAsReturned SortOrder = iota
+	// SortAscending sorts the ids in ascending order, which is the order in which they were created.
+	SortAscending
+	// SortDescending sorts the ids in descending order, newest first.
+	SortDescending
+
+	defaultPageSize    = 500
+	defaultConcurrency = 2
+)
+
+type (
+	// SortOrder describes the order in which the results of an iteration are returned.
+	SortOrder int
+
+	// IterateOptions configures the paginated retrieval of notes and cards.
+	IterateOptions struct {
+		// PageSize is the number of ids sent in a single notesInfo or cardsInfo request, 500 if <= 0.
+		PageSize int
+		// Concurrency is the number of pages that are fetched at the same time, 2 if <= 0.
+		// The results are always returned in order.
+		Concurrency int
+		// Order is the order of the results, the ids are sorted before the pages are fetched.
+		Order SortOrder
+	}
+
+	// page is the result of fetching the info of a chunk of ids.
+	page[R any] struct {
+		results *[]R
+		err     error
+	}
+)
+
+// pages returns the ids split into chunks of the page size, sorted in the requested order.
+func (o *IterateOptions) pages(ids []int64) [][]int64 {
+	ids = slices.Clone(ids)
+	switch o.Order {
+	case SortAscending:
+		slices.Sort(ids)
+	case SortDescending:
+		slices.Sort(ids)
+		slices.Reverse(ids)
+	}
+	size := o.PageSize
+	if size <= 0 {
+		size = defaultPageSize
+	}
+	return slices.Collect(slices.Chunk(ids, size))
+}
+
+func (o *IterateOptions) concurrency() int {
+	if o.Concurrency <= 0 {
+		return defaultConcurrency
+	}
+	return o.Concurrency
+}
+
+// iterate searches the ids with search and yields the results returned by fetch for chunks of the ids.
+// Up to the configured concurrency pages are fetched ahead of the consumer. When the consumer stops
+// the iteration no further pages are requested, the results of pages in flight are discarded.
+// An error stops the iteration after it has been yielded.
+func iterate[R any](opts *IterateOptions, search func() (*[]int64, error), fetch func(ids []int64) (*[]R, error)) iter.Seq2[R, error] {
+	if opts == nil {
+		opts = &IterateOptions{}
+	}
+	return func(yield func(R, error) bool) {
+		var zero R
+		ids, err := search()
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		if ids == nil {
+			return
+		}
+
+		pages := opts.pages(*ids)
+		pending := make([]chan page[R], len(pages))
+		start := func(i int) {
+			// the channel is buffered so that the goroutine finishes even if the page is never read.
+			pending[i] = make(chan page[R], 1)
+			go func(ids []int64, ch chan<- page[R]) {
+				results, err := fetch(ids)
+				ch <- page[R]{results: results, err: err}
+			}(pages[i], pending[i])
+		}
+		for i := 0; i < len(pages) && i < opts.concurrency(); i++ {
+			start(i)
+		}
+
+		for i := range pages {
+			p := <-pending[i]
+			if next := i + opts.concurrency(); next < len(pages) {
+				start(next)
+			}
+			if p.err != nil {
+				yield(zero, p.err)
+				return
+			}
+			if p.results == nil {
+				continue
+			}
+			for _, r := range *p.results {
+				if !yield(r, nil) {
+					return
+				}
+			}
+		}
+	}
+}
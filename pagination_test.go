@@ -0,0 +1,128 @@
+package ankiconnect_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/atselvan/ankiconnect"
+	"github.com/atselvan/ankiconnect/ankiconnecttest"
+	"github.com/stretchr/testify/assert"
+)
+
+// newPaginationServer returns a fake server with n Basic notes in the Default deck and their ids.
+func newPaginationServer(t *testing.T, n int) (*ankiconnecttest.Server, []int64) {
+	server := ankiconnecttest.NewServer()
+	var ids []int64
+	for i := 0; i < n; i++ {
+		id, err := server.AddNote(ankiconnect.Note{
+			DeckName:  "Default",
+			ModelName: "Basic",
+			Fields:    ankiconnect.Fields{"Front": fmt.Sprintf("front %d", i), "Back": "back"},
+		})
+		assert.NoError(t, err)
+		ids = append(ids, id)
+	}
+	return server, ids
+}
+
+func countActions(server *ankiconnecttest.Server, action string) int {
+	n := 0
+	for _, a := range server.Actions() {
+		if a == action {
+			n++
+		}
+	}
+	return n
+}
+
+func TestNotesManager_Iterate(t *testing.T) {
+	t.Run("fetches pages in order", func(t *testing.T) {
+		server, ids := newPaginationServer(t, 7)
+		defer server.Close()
+
+		var got []int64
+		for note, err := range server.Client().Notes.Iterate("deck:Default", &ankiconnect.IterateOptions{
+			PageSize:    2,
+			Concurrency: 3,
+			Order:       ankiconnect.SortAscending,
+		}) {
+			assert.NoError(t, err)
+			got = append(got, note.NoteId)
+		}
+		assert.Equal(t, ids, got)
+		assert.Equal(t, 4, countActions(server, ankiconnect.ActionNotesInfo))
+	})
+
+	t.Run("descending order", func(t *testing.T) {
+		server, ids := newPaginationServer(t, 3)
+		defer server.Close()
+
+		var got []int64
+		for note, err := range server.Client().Notes.Iterate("deck:Default", &ankiconnect.IterateOptions{
+			Order: ankiconnect.SortDescending,
+		}) {
+			assert.NoError(t, err)
+			got = append(got, note.NoteId)
+		}
+		assert.Equal(t, []int64{ids[2], ids[1], ids[0]}, got)
+		assert.Equal(t, 1, countActions(server, ankiconnect.ActionNotesInfo))
+	})
+
+	t.Run("stops fetching on break", func(t *testing.T) {
+		server, _ := newPaginationServer(t, 10)
+		defer server.Close()
+
+		n := 0
+		for _, err := range server.Client().Notes.Iterate("deck:Default", &ankiconnect.IterateOptions{
+			PageSize:    1,
+			Concurrency: 1,
+		}) {
+			assert.NoError(t, err)
+			if n++; n == 3 {
+				break
+			}
+		}
+		assert.Equal(t, 3, n)
+		assert.LessOrEqual(t, countActions(server, ankiconnect.ActionNotesInfo), 4)
+	})
+
+	t.Run("no results", func(t *testing.T) {
+		server, _ := newPaginationServer(t, 0)
+		defer server.Close()
+
+		for range server.Client().Notes.Iterate("deck:Default", nil) {
+			t.Fatal("unexpected result")
+		}
+		assert.Equal(t, 0, countActions(server, ankiconnect.ActionNotesInfo))
+	})
+
+	t.Run("yields errors", func(t *testing.T) {
+		server, _ := newPaginationServer(t, 3)
+		defer server.Close()
+		server.FailAction(ankiconnect.ActionNotesInfo, "collection is not available")
+
+		var errs []error
+		for _, err := range server.Client().Notes.Iterate("deck:Default", &ankiconnect.IterateOptions{PageSize: 1}) {
+			errs = append(errs, err)
+		}
+		if assert.Len(t, errs, 1) {
+			assert.EqualError(t, errs[0], "ankiconnect: notesInfo: collection is not available")
+		}
+	})
+}
+
+func TestCardsManager_Iterate(t *testing.T) {
+	server, ids := newPaginationServer(t, 5)
+	defer server.Close()
+
+	var notes []int64
+	for card, err := range server.Client().Cards.Iterate("deck:Default", &ankiconnect.IterateOptions{
+		PageSize: 2,
+		Order:    ankiconnect.SortAscending,
+	}) {
+		assert.NoError(t, err)
+		notes = append(notes, card.Note)
+	}
+	assert.Equal(t, ids, notes)
+	assert.Equal(t, 3, countActions(server, ankiconnect.ActionCardsInfo))
+}
@@ -0,0 +1,172 @@
+// Package prommetrics provides a ankiconnect.Metrics implementation that exposes the metrics of a
+// ankiconnect client in the Prometheus text exposition format, without depending on the Prometheus
+// client library.
+//
+//	metrics := prommetrics.New()
+//	client := ankiconnect.NewClient().SetMetrics(metrics)
+//	http.Handle("/metrics", metrics)
+package prommetrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultNamespace is the prefix of the metric names.
+	DefaultNamespace = "ankiconnect"
+
+	contentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// DefaultBuckets are the upper bounds in seconds of the latency histogram buckets.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+type (
+	// Collector collects the metrics of ankiconnect calls.
+	// It implements ankiconnect.Metrics and http.Handler.
+	Collector struct {
+		namespace string
+		buckets   []float64
+
+		mu        sync.Mutex
+		requests  map[string]uint64
+		errors    map[errorKey]uint64
+		latencies map[string]*histogram
+	}
+
+	errorKey struct {
+		action string
+		kind   string
+	}
+
+	histogram struct {
+		counts []uint64
+		count  uint64
+		sum    float64
+	}
+)
+
+// New returns a Collector with the DefaultNamespace and the DefaultBuckets.
+func New() *Collector {
+	return NewWithOptions(DefaultNamespace, DefaultBuckets)
+}
+
+// NewWithOptions returns a Collector that prefixes the metric names with namespace and uses
+// buckets as the upper bounds in seconds of the latency histogram.
+func NewWithOptions(namespace string, buckets []float64) *Collector {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	return &Collector{
+		namespace: namespace,
+		buckets:   sorted,
+		requests:  map[string]uint64{},
+		errors:    map[errorKey]uint64{},
+		latencies: map[string]*histogram{},
+	}
+}
+
+// ObserveCall implements ankiconnect.Metrics.
+func (c *Collector) ObserveCall(action string, duration time.Duration, errorKind string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.requests[action]++
+	if errorKind != "" {
+		c.errors[errorKey{action: action, kind: errorKind}]++
+	}
+	h, ok := c.latencies[action]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(c.buckets))}
+		c.latencies[action] = h
+	}
+	seconds := duration.Seconds()
+	for i, bound := range c.buckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	_, _ = c.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format to w.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var b strings.Builder
+	requests := c.namespace + "_requests_total"
+	fmt.Fprintf(&b, "# HELP %s Number of ankiconnect calls by action.\n# TYPE %s counter\n", requests, requests)
+	for _, action := range sortedKeys(c.requests) {
+		fmt.Fprintf(&b, "%s{action=%s} %d\n", requests, quote(action), c.requests[action])
+	}
+
+	errs := c.namespace + "_errors_total"
+	fmt.Fprintf(&b, "# HELP %s Number of failed ankiconnect calls by action and error kind.\n# TYPE %s counter\n", errs, errs)
+	keys := make([]errorKey, 0, len(c.errors))
+	for k := range c.errors {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].action != keys[j].action {
+			return keys[i].action < keys[j].action
+		}
+		return keys[i].kind < keys[j].kind
+	})
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s{action=%s,kind=%s} %d\n", errs, quote(k.action), quote(k.kind), c.errors[k])
+	}
+
+	latency := c.namespace + "_request_duration_seconds"
+	fmt.Fprintf(&b, "# HELP %s Latency of ankiconnect calls by action.\n# TYPE %s histogram\n", latency, latency)
+	for _, action := range sortedKeys(c.latencies) {
+		h := c.latencies[action]
+		for i, bound := range c.buckets {
+			fmt.Fprintf(&b, "%s_bucket{action=%s,le=%s} %d\n", latency, quote(action), quote(formatFloat(bound)), h.counts[i])
+		}
+		fmt.Fprintf(&b, "%s_bucket{action=%s,le=\"+Inf\"} %d\n", latency, quote(action), h.count)
+		fmt.Fprintf(&b, "%s_sum{action=%s} %s\n", latency, quote(action), formatFloat(h.sum))
+		fmt.Fprintf(&b, "%s_count{action=%s} %d\n", latency, quote(action), h.count)
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// quote quotes a label value as required by the exposition format.
+func quote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return `"` + value + `"`
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,46 @@
+package prommetrics
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollector(t *testing.T) {
+	c := NewWithOptions("anki", []float64{1, 0.1})
+	c.ObserveCall("deckNames", 50*time.Millisecond, "")
+	c.ObserveCall("deckNames", 500*time.Millisecond, "unreachable")
+	c.ObserveCall("addNote", 2*time.Second, "duplicate_note")
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, contentType, rec.Header().Get("Content-Type"))
+	assert.Equal(t, `# HELP anki_requests_total Number of ankiconnect calls by action.
+# TYPE anki_requests_total counter
+anki_requests_total{action="addNote"} 1
+anki_requests_total{action="deckNames"} 2
+# HELP anki_errors_total Number of failed ankiconnect calls by action and error kind.
+# TYPE anki_errors_total counter
+anki_errors_total{action="addNote",kind="duplicate_note"} 1
+anki_errors_total{action="deckNames",kind="unreachable"} 1
+# HELP anki_request_duration_seconds Latency of ankiconnect calls by action.
+# TYPE anki_request_duration_seconds histogram
+anki_request_duration_seconds_bucket{action="addNote",le="0.1"} 0
+anki_request_duration_seconds_bucket{action="addNote",le="1"} 0
+anki_request_duration_seconds_bucket{action="addNote",le="+Inf"} 1
+anki_request_duration_seconds_sum{action="addNote"} 2
+anki_request_duration_seconds_count{action="addNote"} 1
+anki_request_duration_seconds_bucket{action="deckNames",le="0.1"} 1
+anki_request_duration_seconds_bucket{action="deckNames",le="1"} 2
+anki_request_duration_seconds_bucket{action="deckNames",le="+Inf"} 2
+anki_request_duration_seconds_sum{action="deckNames"} 0.55
+anki_request_duration_seconds_count{action="deckNames"} 2
+`, rec.Body.String())
+}
+
+func TestQuote(t *testing.T) {
+	assert.Equal(t, `"a\"b\\c\nd"`, quote("a\"b\\c\nd"))
+}
@@ -0,0 +1,303 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	propPattern  = regexp.MustCompile(`^[a-z]+(<=|>=|!=|=|<|>)-?\d+(\.\d+)?$`)
+	idsPattern   = regexp.MustCompile(`^\d+(,\d+)*$`)
+	ratedPattern = regexp.MustCompile(`^\d+(:[1-4])?$`)
+
+	states = map[State]bool{
+		StateDue:            true,
+		StateNew:            true,
+		StateLearn:          true,
+		StateReview:         true,
+		StateSuspended:      true,
+		StateBuried:         true,
+		StateBuriedManually: true,
+		StateBuriedSibling:  true,
+	}
+)
+
+type (
+	// SyntaxError describes why a search query could not be parsed.
+	SyntaxError struct {
+		// Offset is the byte offset in the query at which the error was detected.
+		Offset int
+		Msg    string
+	}
+
+	token struct {
+		text   string
+		offset int
+		// quoted is set if the token contains quotes, a quoted "or" is a text term.
+		quoted bool
+		// operator is set for the "(", ")" and "-" tokens.
+		operator bool
+	}
+
+	parser struct {
+		tokens []token
+		pos    int
+		end    int
+	}
+)
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("query: %s at offset %d", e.Msg, e.Offset)
+}
+
+// Parse parses an Anki search query into a tree of nodes.
+// Groups with a single node are replaced by the node and an empty query returns an empty And.
+// The values of the is:, flag:, prop:, rated:, added:, edited:, introduced:, nid: and cid: searches
+// are validated, other keys are accepted as field names.
+// The function returns a *SyntaxError if the query is invalid.
+func Parse(query string) (Node, error) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, end: len(query)}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok, ok := p.peek(); ok {
+		return nil, &SyntaxError{Offset: tok.offset, Msg: fmt.Sprintf("unexpected %q", tok.text)}
+	}
+	if node == nil {
+		return And{}, nil
+	}
+	return node, nil
+}
+
+// MustParse is like Parse but panics if the query is invalid.
+func MustParse(query string) Node {
+	node, err := Parse(query)
+	if err != nil {
+		panic(err)
+	}
+	return node
+}
+
+// tokenize splits a query into terms and operators. The quotes are removed from the terms,
+// escaped quotes are unescaped and all other escapes are kept.
+func tokenize(query string) ([]token, error) {
+	var tokens []token
+	var current strings.Builder
+	start, inQuotes, quoted, quoteStart := -1, false, false, 0
+
+	flush := func() {
+		if start >= 0 {
+			tokens = append(tokens, token{text: current.String(), offset: start, quoted: quoted})
+			current.Reset()
+			start, quoted = -1, false
+		}
+	}
+	begin := func(i int) {
+		if start < 0 {
+			start = i
+		}
+	}
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\\':
+			begin(i)
+			if i+1 == len(query) {
+				return nil, &SyntaxError{Offset: i, Msg: "trailing backslash"}
+			}
+			i++
+			if query[i] != '"' {
+				current.WriteByte('\\')
+			}
+			current.WriteByte(query[i])
+		case c == '"':
+			begin(i)
+			inQuotes, quoted, quoteStart = !inQuotes, true, i
+		case inQuotes:
+			current.WriteByte(c)
+		case unicode.IsSpace(rune(c)):
+			flush()
+		case c == '(' || c == ')':
+			flush()
+			tokens = append(tokens, token{text: string(c), offset: i, operator: true})
+		case c == '-' && start < 0:
+			tokens = append(tokens, token{text: "-", offset: i, operator: true})
+		default:
+			begin(i)
+			current.WriteByte(c)
+		}
+	}
+	if inQuotes {
+		return nil, &SyntaxError{Offset: quoteStart, Msg: "unterminated quote"}
+	}
+	flush()
+	return tokens, nil
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos], true
+	}
+	return token{}, false
+}
+
+// offset returns the offset of the current token, or the end of the query.
+func (p *parser) offset() int {
+	if tok, ok := p.peek(); ok {
+		return tok.offset
+	}
+	return p.end
+}
+
+func (p *parser) isKeyword(keyword string) bool {
+	tok, ok := p.peek()
+	return ok && !tok.quoted && !tok.operator && strings.EqualFold(tok.text, keyword)
+}
+
+func (p *parser) parseOr() (Node, error) {
+	var nodes Or
+	for {
+		offset := p.offset()
+		node, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if node == nil && (len(nodes) > 0 || p.isKeyword("or")) {
+			return nil, &SyntaxError{Offset: offset, Msg: "missing search term around or"}
+		}
+		if node != nil {
+			nodes = append(nodes, node)
+		}
+		if !p.isKeyword("or") {
+			break
+		}
+		p.pos++
+	}
+	switch len(nodes) {
+	case 0:
+		return nil, nil
+	case 1:
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	var nodes And
+	for {
+		tok, ok := p.peek()
+		if !ok || (tok.operator && tok.text == ")") || p.isKeyword("or") {
+			break
+		}
+		if p.isKeyword("and") {
+			if len(nodes) == 0 {
+				return nil, &SyntaxError{Offset: tok.offset, Msg: "missing search term before and"}
+			}
+			p.pos++
+			continue
+		}
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	switch len(nodes) {
+	case 0:
+		return nil, nil
+	case 1:
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	tok, _ := p.peek()
+	p.pos++
+	if !tok.operator {
+		return parseTerm(tok)
+	}
+	switch tok.text {
+	case "-":
+		if next, ok := p.peek(); !ok || (next.operator && next.text == ")") || p.isKeyword("or") || p.isKeyword("and") {
+			return nil, &SyntaxError{Offset: tok.offset, Msg: "missing search term after -"}
+		}
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return Not(node), nil
+	case "(":
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if next, ok := p.peek(); !ok || !next.operator || next.text != ")" {
+			return nil, &SyntaxError{Offset: p.offset(), Msg: "missing )"}
+		}
+		p.pos++
+		if node == nil {
+			return nil, &SyntaxError{Offset: tok.offset, Msg: "empty group"}
+		}
+		return node, nil
+	}
+	return nil, &SyntaxError{Offset: tok.offset, Msg: fmt.Sprintf("unexpected %q", tok.text)}
+}
+
+// parseTerm splits a term at its first unescaped colon and validates the values of the known keys.
+func parseTerm(tok token) (Node, error) {
+	key, value, ok := cutUnescaped(tok.text, ':')
+	if !ok {
+		return Term{Value: tok.text}, nil
+	}
+	term := Term{Key: key, Value: value}
+	if key == "" {
+		return nil, &SyntaxError{Offset: tok.offset, Msg: fmt.Sprintf("missing key in %q", tok.text)}
+	}
+
+	valid := true
+	switch strings.ToLower(key) {
+	case "deck", "note", "tag", "card", "re", "nc":
+		valid = value != ""
+	case "is":
+		valid = states[State(strings.ToLower(value))]
+	case "flag":
+		flag, err := strconv.Atoi(value)
+		valid = err == nil && flag >= int(FlagNone) && flag <= int(FlagPurple)
+	case "prop":
+		valid = propPattern.MatchString(strings.ToLower(value))
+	case "rated":
+		valid = ratedPattern.MatchString(value) && !strings.HasPrefix(value, "0")
+	case "added", "edited", "introduced":
+		days, err := strconv.Atoi(value)
+		valid = err == nil && days > 0
+	case "nid", "cid":
+		valid = idsPattern.MatchString(value)
+	}
+	if !valid {
+		return nil, &SyntaxError{Offset: tok.offset, Msg: fmt.Sprintf("invalid %s search %q", strings.ToLower(key), value)}
+	}
+	return term, nil
+}
+
+// cutUnescaped slices s around the first occurrence of sep that is not escaped with a backslash.
+func cutUnescaped(s string, sep byte) (before, after string, found bool) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			return s[:i], s[i+1:], true
+		}
+	}
+	return s, "", false
+}
@@ -0,0 +1,104 @@
+package query
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		node  Node
+	}{
+		{"empty", "  ", And{}},
+		{"text", "dog", Term{Value: "dog"}},
+		{"quoted text", `"hot dog"`, Term{Value: "hot dog"}},
+		{"escaped colon", `a\:b`, Term{Value: `a\:b`}},
+		{"escaped quote", `"say \"hi\""`, Term{Value: `say "hi"`}},
+		{"key value", "deck:Japanese", Term{Key: "deck", Value: "Japanese"}},
+		{"quoted value", `deck:"JLPT N5"`, Term{Key: "deck", Value: "JLPT N5"}},
+		{"quoted term", `"deck:JLPT N5"`, Term{Key: "deck", Value: "JLPT N5"}},
+		{"field regex", `front:re:^a:b$`, Term{Key: "front", Value: "re:^a:b$"}},
+		{"and", "deck:a tag:b", And{Deck("a"), Tag("b")}},
+		{"explicit and", "deck:a AND tag:b", And{Deck("a"), Tag("b")}},
+		{"or", "tag:a or tag:b OR tag:c", Or{Tag("a"), Tag("b"), Tag("c")}},
+		{"precedence", "deck:a tag:b or tag:c", Or{And{Deck("a"), Tag("b")}, Tag("c")}},
+		{"group", "deck:a (tag:b or tag:c)", And{Deck("a"), Or{Tag("b"), Tag("c")}}},
+		{"negation", "-tag:a", Not(Tag("a"))},
+		{"negated group", "-(tag:a or tag:b)", Not(Or{Tag("a"), Tag("b")})},
+		{"dash inside term", "a-b", Term{Value: "a-b"}},
+		{"quoted keyword", `"or"`, Term{Value: "or"}},
+		{"prop", "prop:ivl>=10", Prop(PropInterval, GreaterOrEqual, 10)},
+		{"rated", "rated:3:1", RatedAs(3, 1)},
+		{"ids", "nid:1,2", NoteIDs(1, 2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := Parse(tt.query)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.node, node)
+		})
+	}
+}
+
+func TestParse_RoundTrip(t *testing.T) {
+	nodes := []Node{
+		Text("hot dog"),
+		Text(`a:b*c_d\e`),
+		Text("-x"),
+		Deck("Japanese::JLPT N5"),
+		Field("Back Extra", `say "hi"`),
+		And{Deck("a b"), Or{Tag("x"), Not(Tag("y"))}, Prop(PropEase, Less, 2.5)},
+		Or{And{Deck("a"), Tag("b")}, Not(And{Is(StateNew), HasFlag(FlagBlue)})},
+	}
+	for _, node := range nodes {
+		t.Run(node.String(), func(t *testing.T) {
+			parsed, err := Parse(node.String())
+			assert.NoError(t, err)
+			assert.Equal(t, node, parsed)
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		offset int
+	}{
+		{"unterminated quote", `deck:"a b`, 5},
+		{"trailing backslash", `a\`, 1},
+		{"missing paren", "(tag:a", 6},
+		{"unexpected paren", "tag:a)", 5},
+		{"empty group", "tag:a ()", 6},
+		{"leading or", "or tag:a", 0},
+		{"trailing or", "tag:a or", 8},
+		{"leading and", "and tag:a", 0},
+		{"dangling negation", "tag:a -", 6},
+		{"missing key", ":a", 0},
+		{"empty deck", "deck:", 0},
+		{"invalid state", "is:sleeping", 0},
+		{"invalid flag", "flag:9", 0},
+		{"invalid prop", "prop:ivl~3", 0},
+		{"invalid rated", "rated:2:5", 0},
+		{"invalid added", "added:0", 0},
+		{"invalid ids", "nid:1,a", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.query)
+			var syntaxErr *SyntaxError
+			if assert.True(t, errors.As(err, &syntaxErr), "error: %v", err) {
+				assert.Equal(t, tt.offset, syntaxErr.Offset)
+			}
+		})
+	}
+}
+
+func TestMustParse(t *testing.T) {
+	assert.Equal(t, Tag("a"), MustParse("tag:a"))
+	assert.Panics(t, func() { MustParse("(") })
+}
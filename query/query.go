@@ -0,0 +1,337 @@
+// Package query builds and parses Anki search queries, as accepted by the Search and Get methods
+// of the ankiconnect notes and cards managers.
+//
+// The constructors escape their arguments, so that deck names, tags and field values containing
+// spaces, colons, underscores or asterisks are matched literally:
+//
+//	q := query.And{
+//		query.Deck("Japanese::JLPT N5"),
+//		query.Or{query.Tag("verb"), query.Tag("adjective")},
+//		query.Not(query.Is(query.StateSuspended)),
+//		query.Prop(query.PropInterval, query.GreaterOrEqual, 21),
+//	}
+//	notes, err := client.Notes.Search(q.String())
+//
+// Parse converts an existing search string into the same tree, e.g. to validate user input.
+package query
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/atselvan/ankiconnect/internal/search"
+)
+
+const (
+	// StateDue matches review cards and learning cards that are due.
+	StateDue State = "due"
+	// StateNew matches new cards.
+	StateNew State = "new"
+	// StateLearn matches cards in learning.
+	StateLearn State = "learn"
+	// StateReview matches review cards, including cards in relearning.
+	StateReview State = "review"
+	// StateSuspended matches suspended cards.
+	StateSuspended State = "suspended"
+	// StateBuried matches buried cards.
+	StateBuried State = "buried"
+	// StateBuriedManually matches cards buried by the user.
+	StateBuriedManually State = "buried-manually"
+	// StateBuriedSibling matches cards buried because a sibling was answered.
+	StateBuriedSibling State = "buried-sibling"
+)
+
+// The flags that can be set on cards.
+const (
+	FlagNone   Flag = 0
+	FlagRed    Flag = 1
+	FlagOrange Flag = 2
+	FlagGreen  Flag = 3
+	FlagBlue   Flag = 4
+	FlagPink   Flag = 5
+	FlagTurq   Flag = 6
+	FlagPurple Flag = 7
+)
+
+const (
+	// PropInterval is the interval of a card in days.
+	PropInterval Property = "ivl"
+	// PropDue is the number of days until a review card is due, negative if it is overdue.
+	PropDue Property = "due"
+	// PropReps is the number of times a card was answered.
+	PropReps Property = "reps"
+	// PropLapses is the number of times a card was answered with again.
+	PropLapses Property = "lapses"
+	// PropEase is the ease factor of a card, e.g. 2.5.
+	PropEase Property = "ease"
+	// PropPosition is the position of a new card in the new queue.
+	PropPosition Property = "pos"
+)
+
+// The operators that can be used to compare card properties.
+const (
+	Less           Comparison = "<"
+	LessOrEqual    Comparison = "<="
+	Greater        Comparison = ">"
+	GreaterOrEqual Comparison = ">="
+	Equal          Comparison = "="
+	NotEqual       Comparison = "!="
+)
+
+type (
+	// Node is an element of a search query.
+	Node interface {
+		// String returns the node as an Anki search string.
+		String() string
+		node()
+	}
+
+	// Term is a single search term, a text search if Key is empty or a key:value search otherwise.
+	// The Value is an Anki search pattern, in which "*" and "_" are wildcards unless they are
+	// escaped with a backslash. Quoting is added by String when required.
+	Term struct {
+		Key   string
+		Value string
+	}
+
+	// And matches the cards matching all of its nodes.
+	And []Node
+
+	// Or matches the cards matching at least one of its nodes.
+	Or []Node
+
+	// Negation matches the cards that do not match its node.
+	Negation struct {
+		Node Node
+	}
+
+	// State is a value of the is: search.
+	State string
+
+	// Flag is the number of a card flag.
+	Flag int
+
+	// Property is a card property that can be compared with Prop.
+	Property string
+
+	// Comparison is the operator of a property comparison.
+	Comparison string
+)
+
+// Text matches notes with a field containing the text.
+func Text(text string) Term {
+	return Term{Value: escapeText(text)}
+}
+
+// Match returns a term for the key and a pattern that is used without escaping,
+// so that "*" and "_" act as wildcards. An empty key matches the pattern in any field.
+func Match(key, pattern string) Term {
+	return Term{Key: key, Value: pattern}
+}
+
+// Deck matches cards in the deck and its subdecks.
+func Deck(name string) Term {
+	return Term{Key: "deck", Value: escapeValue(name)}
+}
+
+// NoteType matches notes of the note type (model).
+func NoteType(name string) Term {
+	return Term{Key: "note", Value: escapeValue(name)}
+}
+
+// Tag matches notes with the tag or one of its child tags.
+func Tag(tag string) Term {
+	return Term{Key: "tag", Value: escapeValue(tag)}
+}
+
+// Field matches notes with a field whose whole content is the value.
+func Field(name, value string) Term {
+	return Term{Key: escapeValue(name), Value: escapeValue(value)}
+}
+
+// FieldRegex matches notes with a field matching the regular expression.
+func FieldRegex(name, expr string) Term {
+	return Term{Key: escapeValue(name), Value: "re:" + expr}
+}
+
+// Regex matches notes with a field matching the regular expression.
+func Regex(expr string) Term {
+	return Term{Key: "re", Value: expr}
+}
+
+// NoCombining matches notes with a field containing the text, ignoring combining characters
+// such as accents.
+func NoCombining(text string) Term {
+	return Term{Key: "nc", Value: escapeValue(text)}
+}
+
+// Is matches cards in the state.
+func Is(state State) Term {
+	return Term{Key: "is", Value: string(state)}
+}
+
+// HasFlag matches cards with the flag.
+func HasFlag(flag Flag) Term {
+	return Term{Key: "flag", Value: strconv.Itoa(int(flag))}
+}
+
+// Card matches cards generated by the card template with the name.
+func Card(template string) Term {
+	return Term{Key: "card", Value: escapeValue(template)}
+}
+
+// CardOrdinal matches cards generated by the n-th card template or cloze deletion, starting at 1.
+func CardOrdinal(n int) Term {
+	return Term{Key: "card", Value: strconv.Itoa(n)}
+}
+
+// NoteIDs matches the notes with the ids.
+func NoteIDs(ids ...int64) Term {
+	return Term{Key: "nid", Value: joinIDs(ids)}
+}
+
+// CardIDs matches the cards with the ids.
+func CardIDs(ids ...int64) Term {
+	return Term{Key: "cid", Value: joinIDs(ids)}
+}
+
+// Added matches cards added in the last days, 1 being today.
+func Added(days int) Term {
+	return Term{Key: "added", Value: strconv.Itoa(days)}
+}
+
+// Edited matches notes edited in the last days, 1 being today.
+func Edited(days int) Term {
+	return Term{Key: "edited", Value: strconv.Itoa(days)}
+}
+
+// Rated matches cards answered in the last days, 1 being today.
+func Rated(days int) Term {
+	return Term{Key: "rated", Value: strconv.Itoa(days)}
+}
+
+// RatedAs matches cards answered with the ease (1 for again to 4 for easy) in the last days.
+func RatedAs(days, ease int) Term {
+	return Term{Key: "rated", Value: strconv.Itoa(days) + ":" + strconv.Itoa(ease)}
+}
+
+// Prop matches cards whose property compares to the value, e.g. Prop(PropInterval, GreaterOrEqual, 10).
+func Prop(property Property, op Comparison, value float64) Term {
+	return Term{Key: "prop", Value: string(property) + string(op) + strconv.FormatFloat(value, 'f', -1, 64)}
+}
+
+// Not negates a node.
+func Not(node Node) Negation {
+	return Negation{Node: node}
+}
+
+// String returns the term as an Anki search string, quoted if required.
+func (t Term) String() string {
+	return search.Term(t.Key, t.Value)
+}
+
+// String returns the nodes joined by spaces, nested or groups are put in parentheses.
+func (a And) String() string {
+	parts := make([]string, 0, len(a))
+	for _, n := range a {
+		s := n.String()
+		if s == "" {
+			continue
+		}
+		if o, ok := n.(Or); ok && o.size() > 1 {
+			s = "(" + s + ")"
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, " ")
+}
+
+// String returns the nodes joined by "or", nested and groups are put in parentheses.
+func (o Or) String() string {
+	parts := make([]string, 0, len(o))
+	for _, n := range o {
+		s := n.String()
+		if s == "" {
+			continue
+		}
+		if a, ok := n.(And); ok && a.size() > 1 {
+			s = "(" + s + ")"
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, " or ")
+}
+
+// String returns the negated node prefixed with "-".
+func (n Negation) String() string {
+	if n.Node == nil {
+		return ""
+	}
+	s := n.Node.String()
+	switch node := n.Node.(type) {
+	case Term:
+		return "-" + s
+	case And:
+		if node.size() > 1 {
+			return "-(" + s + ")"
+		}
+	case Or:
+		if node.size() > 1 {
+			return "-(" + s + ")"
+		}
+	}
+	if s == "" {
+		return ""
+	}
+	return "-" + s
+}
+
+func (Term) node()     {}
+func (And) node()      {}
+func (Or) node()       {}
+func (Negation) node() {}
+
+// size returns the number of nodes that are not empty.
+func (a And) size() int {
+	return countNonEmpty(a)
+}
+
+func (o Or) size() int {
+	return countNonEmpty(o)
+}
+
+func countNonEmpty(nodes []Node) int {
+	n := 0
+	for _, node := range nodes {
+		if node != nil && node.String() != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// escapeValue escapes the wildcards and backslashes of a literal value.
+func escapeValue(s string) string {
+	return search.EscapeValue(s)
+}
+
+// escapeText escapes a literal text search, which additionally must not contain unescaped colons
+// and must not start with "-".
+func escapeText(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if r == '\\' || r == '*' || r == '_' || r == ':' || (i == 0 && r == '-') {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func joinIDs(ids []int64) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatInt(id, 10)
+	}
+	return strings.Join(parts, ",")
+}
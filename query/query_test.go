@@ -0,0 +1,104 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/atselvan/ankiconnect"
+	"github.com/atselvan/ankiconnect/ankiconnecttest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNode_String(t *testing.T) {
+	tests := []struct {
+		name  string
+		node  Node
+		query string
+	}{
+		{"text", Text("dog"), "dog"},
+		{"text with space", Text("hot dog"), `"hot dog"`},
+		{"text with wildcards", Text("a*b_c"), `a\*b\_c`},
+		{"text with colon", Text("a:b"), `a\:b`},
+		{"text with leading dash", Text("-dog"), `\-dog`},
+		{"text keyword", Text("or"), `"or"`},
+		{"text with quote", Text(`say "hi"`), `"say \"hi\""`},
+		{"match", Match("deck", "Japanese*"), "deck:Japanese*"},
+		{"deck", Deck("Japanese::JLPT N5"), `"deck:Japanese::JLPT N5"`},
+		{"deck with underscore", Deck("my_deck"), `deck:my\_deck`},
+		{"note type", NoteType("Basic (and reversed card)"), `"note:Basic (and reversed card)"`},
+		{"tag", Tag("verb"), "tag:verb"},
+		{"field", Field("Front", "a*"), `Front:a\*`},
+		{"field with space", Field("Back Extra", "x"), `"Back Extra:x"`},
+		{"field regex", FieldRegex("Front", `^\d+$`), `Front:re:^\d+$`},
+		{"regex", Regex("(a|b)"), `"re:(a|b)"`},
+		{"no combining", NoCombining("uber"), "nc:uber"},
+		{"is", Is(StateDue), "is:due"},
+		{"flag", HasFlag(FlagRed), "flag:1"},
+		{"card", Card("Card 1"), `"card:Card 1"`},
+		{"card ordinal", CardOrdinal(2), "card:2"},
+		{"note ids", NoteIDs(1, 2, 3), "nid:1,2,3"},
+		{"card ids", CardIDs(4), "cid:4"},
+		{"added", Added(7), "added:7"},
+		{"edited", Edited(2), "edited:2"},
+		{"rated", Rated(1), "rated:1"},
+		{"rated as", RatedAs(3, 1), "rated:3:1"},
+		{"prop", Prop(PropInterval, GreaterOrEqual, 10), "prop:ivl>=10"},
+		{"prop float", Prop(PropEase, Less, 2.5), "prop:ease<2.5"},
+		{"and", And{Deck("a"), Tag("b")}, "deck:a tag:b"},
+		{"or", Or{Tag("a"), Tag("b")}, "tag:a or tag:b"},
+		{"and with or", And{Deck("a"), Or{Tag("b"), Tag("c")}}, "deck:a (tag:b or tag:c)"},
+		{"or with and", Or{And{Deck("a"), Tag("b")}, Tag("c")}, "(deck:a tag:b) or tag:c"},
+		{"single node groups", Or{And{Tag("a")}}, "tag:a"},
+		{"not term", Not(Tag("a")), "-tag:a"},
+		{"not quoted term", Not(Deck("a b")), `-"deck:a b"`},
+		{"not group", Not(Or{Tag("a"), Tag("b")}), "-(tag:a or tag:b)"},
+		{"empty", And{}, ""},
+		{"empty nested", And{Or{}, Tag("a")}, "tag:a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.query, tt.node.String())
+		})
+	}
+}
+
+func TestNode_FakeServer(t *testing.T) {
+	server := ankiconnecttest.NewServer()
+	defer server.Close()
+
+	add := func(deck, front string, tags ...string) int64 {
+		server.AddDeck(deck)
+		id, err := server.AddNote(ankiconnect.Note{
+			DeckName:  deck,
+			ModelName: "Basic",
+			Fields:    ankiconnect.Fields{"Front": front, "Back": "back"},
+			Tags:      tags,
+		})
+		assert.NoError(t, err)
+		return id
+	}
+	spaced := add("JLPT N5", "a*b", "verb")
+	underscore := add("my_deck", "a_b", "noun")
+	other := add("myXdeck", "axb", "verb")
+
+	client := server.Client()
+	tests := []struct {
+		name  string
+		node  Node
+		notes []int64
+	}{
+		{"deck with space", Deck("JLPT N5"), []int64{spaced}},
+		{"deck with underscore", Deck("my_deck"), []int64{underscore}},
+		{"deck wildcard", Match("deck", "my_deck"), []int64{underscore, other}},
+		{"field with asterisk", Field("Front", "a*b"), []int64{spaced}},
+		{"text with underscore", Text("a_b"), []int64{underscore}},
+		{"or", Or{Deck("JLPT N5"), Deck("myXdeck")}, []int64{spaced, other}},
+		{"not", And{Tag("verb"), Not(Deck("JLPT N5"))}, []int64{other}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, err := client.Notes.Search(tt.node.String())
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, tt.notes, *ids)
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package ankiconnect
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	emptyQueryErrMsg    = "ankiconnect: the query must not be empty, use deck:* to search all notes"
+	emptyFindErrMsg     = "ankiconnect: the text to find must not be empty"
+	missingResultErrMsg = "ankiconnect did not return a result for the update of the note"
+)
+
+type (
+	// ReplaceOptions configures a find and replace in the fields of notes.
+	ReplaceOptions struct {
+		// Query restricts the replacement to the notes matching the search query. It is required,
+		// use deck:* to replace in all notes of the collection.
+		Query string
+		// Find is the text to find. It is a regular expression in the RE2 syntax if Regex is set.
+		Find string
+		// Replacement replaces every match. If Regex is set $1 or ${name} are expanded
+		// to the text of the submatch, use $$ for a literal $.
+		Replacement string
+		// Regex interprets Find as a regular expression instead of plain text.
+		Regex bool
+		// IgnoreCase matches Find case insensitively.
+		IgnoreCase bool
+		// Fields restricts the replacement to the fields with the names. All fields are searched if empty.
+		Fields []string
+		// Preview only returns the changes that would be made without updating the notes.
+		Preview bool
+	}
+
+	// FieldChange describes the change of a single field of a note.
+	FieldChange struct {
+		Field   string `json:"field"`
+		Old     string `json:"old"`
+		New     string `json:"new"`
+		Matches int    `json:"matches"`
+	}
+
+	// NoteChange describes the changes of the fields of a note.
+	// Error is set if the note could not be updated.
+	NoteChange struct {
+		NoteId    int64         `json:"noteId"`
+		ModelName string        `json:"modelName"`
+		Changes   []FieldChange `json:"changes"`
+		Error     string        `json:"error,omitempty"`
+	}
+
+	// ReplaceReport summarises a find and replace. Searched is the number of notes matching the query,
+	// Notes lists the notes with at least one match in the order of their ids.
+	// Updated and Failed are the number of notes that were and could not be updated, they are 0 for a preview.
+	ReplaceReport struct {
+		Searched int          `json:"searched"`
+		Notes    []NoteChange `json:"notes"`
+		Updated  int          `json:"updated"`
+		Failed   int          `json:"failed"`
+	}
+)
+
+// Replace finds the text or regular expression of the options in the fields of the notes matching
+// the query and replaces it. The notes are read page by page and the changed fields computed by the
+// client are written with a single multi request of updateNoteFields actions, unless the options
+// ask for a preview, so the notes are updated with exactly the values reported in Notes.
+// Notes that could not be updated are reported with the error instead of failing the whole replacement.
+// The method returns an error if:
+//   - the query or the text to find is empty or the regular expression is invalid.
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+func (nm *notesManager) Replace(opts ReplaceOptions) (*ReplaceReport, error) {
+	if strings.TrimSpace(opts.Query) == "" {
+		return nil, errors.New(emptyQueryErrMsg)
+	}
+	re, err := compileReplace(opts)
+	if err != nil {
+		return nil, err
+	}
+	restrict := map[string]bool{}
+	for _, field := range opts.Fields {
+		restrict[field] = true
+	}
+
+	report := &ReplaceReport{}
+	for note, err := range nm.Iterate(opts.Query, nil) {
+		if err != nil {
+			return nil, err
+		}
+		report.Searched++
+		change := NoteChange{NoteId: note.NoteId, ModelName: note.ModelName}
+		for name, field := range note.Fields {
+			if len(restrict) > 0 && !restrict[name] {
+				continue
+			}
+			matches := len(re.FindAllStringIndex(field.Value, -1))
+			if matches == 0 {
+				continue
+			}
+			replaced := re.ReplaceAllLiteralString(field.Value, opts.Replacement)
+			if opts.Regex {
+				replaced = re.ReplaceAllString(field.Value, opts.Replacement)
+			}
+			if replaced == field.Value {
+				continue
+			}
+			change.Changes = append(change.Changes, FieldChange{
+				Field:   name,
+				Old:     field.Value,
+				New:     replaced,
+				Matches: matches,
+			})
+		}
+		if len(change.Changes) == 0 {
+			continue
+		}
+		sort.Slice(change.Changes, func(i, j int) bool {
+			return note.Fields[change.Changes[i].Field].Order < note.Fields[change.Changes[j].Field].Order
+		})
+		report.Notes = append(report.Notes, change)
+	}
+	sort.Slice(report.Notes, func(i, j int) bool { return report.Notes[i].NoteId < report.Notes[j].NoteId })
+
+	if opts.Preview || len(report.Notes) == 0 {
+		return report, nil
+	}
+	actions := make([]MultiAction, len(report.Notes))
+	for i, change := range report.Notes {
+		fields := Fields{}
+		for _, fc := range change.Changes {
+			fields[fc.Field] = fc.New
+		}
+		actions[i] = MultiAction{
+			Action: ActionUpdateNoteFields,
+			Params: ParamsUpdateNote{Note: &UpdateNote{Id: change.NoteId, Fields: fields}},
+		}
+	}
+	results, err := nm.Client.Multi(actions...)
+	if err != nil {
+		return nil, err
+	}
+	for i := range report.Notes {
+		msg := missingResultErrMsg
+		if i < len(*results) {
+			msg = (*results)[i].Error
+		}
+		if msg == "" {
+			report.Updated++
+			continue
+		}
+		report.Notes[i].Error = msg
+		report.Failed++
+	}
+	return report, nil
+}
+
+// compileReplace returns the regular expression matching the text to find of the options.
+func compileReplace(opts ReplaceOptions) (*regexp.Regexp, error) {
+	if opts.Find == "" {
+		return nil, errors.New(emptyFindErrMsg)
+	}
+	expr := opts.Find
+	if !opts.Regex {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if opts.IgnoreCase {
+		expr = "(?i)" + expr
+	}
+	return regexp.Compile(expr)
+}
@@ -0,0 +1,122 @@
+package ankiconnect_test
+
+import (
+	"testing"
+
+	"github.com/atselvan/ankiconnect"
+	"github.com/atselvan/ankiconnect/ankiconnecttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newReplaceServer(t *testing.T) (*ankiconnecttest.Server, []int64) {
+	server := ankiconnecttest.NewServer()
+	server.AddDeck("Links")
+	var ids []int64
+	for _, fields := range []ankiconnect.Fields{
+		{"Front": "Go", "Back": `<a href="http://golang.org/doc">docs</a>`},
+		{"Front": "Go http://golang.org", "Back": "http://golang.org/pkg and http://GOLANG.org/ref"},
+		{"Front": "Rust", "Back": "https://rust-lang.org"},
+	} {
+		id, err := server.AddNote(ankiconnect.Note{DeckName: "Links", ModelName: "Basic", Fields: fields})
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+	return server, ids
+}
+
+func TestNotesManager_Replace(t *testing.T) {
+	t.Run("preview", func(t *testing.T) {
+		server, ids := newReplaceServer(t)
+		defer server.Close()
+
+		report, err := server.Client().Notes.Replace(ankiconnect.ReplaceOptions{
+			Query:       "deck:Links",
+			Find:        `http://golang\.org/(\w+)`,
+			Replacement: "https://go.dev/$1",
+			Regex:       true,
+			IgnoreCase:  true,
+			Preview:     true,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 3, report.Searched)
+		assert.Equal(t, 0, report.Updated)
+		assert.Equal(t, []ankiconnect.NoteChange{
+			{NoteId: ids[0], ModelName: "Basic", Changes: []ankiconnect.FieldChange{
+				{Field: "Back", Old: `<a href="http://golang.org/doc">docs</a>`, New: `<a href="https://go.dev/doc">docs</a>`, Matches: 1},
+			}},
+			{NoteId: ids[1], ModelName: "Basic", Changes: []ankiconnect.FieldChange{
+				{Field: "Back", Old: "http://golang.org/pkg and http://GOLANG.org/ref", New: "https://go.dev/pkg and https://go.dev/ref", Matches: 2},
+			}},
+		}, report.Notes)
+
+		note, ok := server.Note(ids[0])
+		require.True(t, ok)
+		assert.Equal(t, `<a href="http://golang.org/doc">docs</a>`, note.Fields["Back"])
+		assert.NotContains(t, server.Actions(), ankiconnect.ActionMulti)
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		server, ids := newReplaceServer(t)
+		defer server.Close()
+
+		report, err := server.Client().Notes.Replace(ankiconnect.ReplaceOptions{
+			Query:       "deck:*",
+			Find:        "http://golang.org",
+			Replacement: "https://go.dev$1",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 3, report.Searched)
+		assert.Equal(t, 2, report.Updated)
+		assert.Equal(t, 0, report.Failed)
+		require.Len(t, report.Notes, 2)
+		assert.Equal(t, []string{"Front", "Back"}, []string{report.Notes[1].Changes[0].Field, report.Notes[1].Changes[1].Field})
+
+		note, ok := server.Note(ids[1])
+		require.True(t, ok)
+		assert.Equal(t, "Go https://go.dev$1", note.Fields["Front"])
+		assert.Equal(t, "https://go.dev$1/pkg and http://GOLANG.org/ref", note.Fields["Back"])
+	})
+
+	t.Run("fields", func(t *testing.T) {
+		server, ids := newReplaceServer(t)
+		defer server.Close()
+
+		report, err := server.Client().Notes.Replace(ankiconnect.ReplaceOptions{
+			Query:       "deck:Links",
+			Find:        "golang.org",
+			Replacement: "go.dev",
+			Fields:      []string{"Front"},
+		})
+		require.NoError(t, err)
+		require.Len(t, report.Notes, 1)
+		assert.Equal(t, ids[1], report.Notes[0].NoteId)
+
+		note, ok := server.Note(ids[1])
+		require.True(t, ok)
+		assert.Equal(t, "Go http://go.dev", note.Fields["Front"])
+		assert.Equal(t, "http://golang.org/pkg and http://GOLANG.org/ref", note.Fields["Back"])
+	})
+
+	t.Run("failed update", func(t *testing.T) {
+		server, _ := newReplaceServer(t)
+		defer server.Close()
+		server.FailAction(ankiconnect.ActionUpdateNoteFields, "note was not found")
+
+		report, err := server.Client().Notes.Replace(ankiconnect.ReplaceOptions{Query: "deck:Links", Find: "Rust", Replacement: "Zig"})
+		require.NoError(t, err)
+		assert.Equal(t, 0, report.Updated)
+		assert.Equal(t, 1, report.Failed)
+		assert.Equal(t, "note was not found", report.Notes[0].Error)
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		client := ankiconnect.NewClient()
+		_, err := client.Notes.Replace(ankiconnect.ReplaceOptions{Find: "Go"})
+		assert.EqualError(t, err, "ankiconnect: the query must not be empty, use deck:* to search all notes")
+		_, err = client.Notes.Replace(ankiconnect.ReplaceOptions{Query: "deck:*"})
+		assert.Error(t, err)
+		_, err = client.Notes.Replace(ankiconnect.ReplaceOptions{Query: "deck:*", Find: "(", Regex: true})
+		assert.Error(t, err)
+	})
+}
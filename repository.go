@@ -0,0 +1,298 @@
+package ankiconnect
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/atselvan/ankiconnect/internal/search"
+)
+
+const (
+	noteNotFoundErrMsg = "no note with %s %q in deck %s"
+	noteNotAddedErrMsg = "the note could not be added, e.g. because it is a duplicate"
+)
+
+// Repository stores values of a struct type as notes of a model in a deck.
+// The struct is converted with MarshalNote and UnmarshalNote, the deck and the model of the
+// repository override the deck and the model set on the struct.
+//
+// The sort field of the model is used as the natural key of the notes, e.g. the word of a vocabulary note.
+// If the installed ankiconnect version cannot report the sort field, the first field of the model is used,
+// which is the sort field unless it was changed in the field settings of the model.
+// The fields of the struct are validated against the fields of the model before the first
+// note is written, a mismatch is reported as a *FieldMismatchError.
+//
+//	repo := ankiconnect.NewRepository[Vocab](client, "Japanese", "Basic")
+//	id, err := repo.Upsert(Vocab{Word: "taberu", Meaning: "to eat"})
+type Repository[T any] struct {
+	client *Client
+	deck   string
+	model  string
+
+	mu          sync.Mutex
+	modelFields []string
+	key         string
+}
+
+// NewRepository returns a Repository that stores values of type T as notes of the model in the deck.
+func NewRepository[T any](client *Client, deck, model string) *Repository[T] {
+	return &Repository[T]{
+		client: client,
+		deck:   deck,
+		model:  model,
+	}
+}
+
+// Create adds the value as a new note and returns the id of the note.
+// The method returns an error if:
+//   - the value cannot be converted to a note.
+//   - the fields of the value do not match the fields of the model.
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+//   - the note was not added, the error wraps ErrDuplicateNote as ankiconnect reports rejected
+//     duplicates without a reason.
+func (r *Repository[T]) Create(v T) (int64, error) {
+	note, err := r.note(v)
+	if err != nil {
+		return 0, err
+	}
+	ids, err := r.client.Notes.AddMany([]Note{note})
+	if err != nil {
+		return 0, err
+	}
+	if len(*ids) == 0 || (*ids)[0] == 0 {
+		return 0, &Error{
+			Action:     ActionAddNotes,
+			Message:    noteNotAddedErrMsg,
+			StatusCode: http.StatusBadRequest,
+			Kind:       ErrDuplicateNote,
+		}
+	}
+	return (*ids)[0], nil
+}
+
+// FindByKey returns the note whose sort field equals key.
+// If several notes have the same key the oldest one is returned.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+//   - there is no such note, the error wraps ErrNoteNotFound.
+func (r *Repository[T]) FindByKey(key string) (T, error) {
+	var zero T
+	field, err := r.keyField()
+	if err != nil {
+		return zero, err
+	}
+	values, err := r.FindByField(field, key)
+	if err != nil {
+		return zero, err
+	}
+	if len(values) == 0 {
+		return zero, &Error{
+			Action:     ActionFindNotes,
+			Message:    fmt.Sprintf(noteNotFoundErrMsg, field, key, r.deck),
+			StatusCode: http.StatusNotFound,
+			Kind:       ErrNoteNotFound,
+		}
+	}
+	return values[0], nil
+}
+
+// FindByField returns the notes whose field equals value, oldest first.
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+//   - a note cannot be converted to the value type.
+func (r *Repository[T]) FindByField(field, value string) ([]T, error) {
+	return r.list(fieldTerm(field, value))
+}
+
+// List returns the notes of the repository matching all filters, oldest first.
+// The filters are search queries, typically built with the query package, e.g. query.Tag("verb").
+// The method returns an error if:
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+//   - a note cannot be converted to the value type.
+func (r *Repository[T]) List(filters ...fmt.Stringer) ([]T, error) {
+	terms := make([]string, 0, len(filters))
+	for _, f := range filters {
+		terms = append(terms, f.String())
+	}
+	return r.list(terms...)
+}
+
+// list returns the notes of the repository matching all search terms, oldest first.
+func (r *Repository[T]) list(terms ...string) ([]T, error) {
+	var values []T
+	for info, err := range r.client.Notes.Iterate(r.query(terms...), &IterateOptions{Order: SortAscending}) {
+		if err != nil {
+			return nil, err
+		}
+		v, err := UnmarshalNote[T](info)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// Update updates the fields of the note with the id of the value, which must have a field tagged
+// with `anki:",id"`.
+// The method returns an error if:
+//   - the value has no note id.
+//   - the value cannot be converted to a note.
+//   - the fields of the value do not match the fields of the model.
+//   - the api request to ankiconnect fails.
+//   - the api returns an error, e.g. the note does not exist.
+func (r *Repository[T]) Update(v T) error {
+	id := NoteID(v)
+	if id == 0 {
+		return fmt.Errorf("ankiconnect: cannot update a %T without note id", v)
+	}
+	note, err := r.note(v)
+	if err != nil {
+		return err
+	}
+	return r.client.Notes.Update(UpdateNote{
+		Id:     id,
+		Fields: note.Fields,
+	})
+}
+
+// Upsert updates the fields of the note with the same key as the value, or adds the value as a new
+// note if there is no such note. It returns the id of the updated or added note.
+// The method returns an error if:
+//   - the value cannot be converted to a note.
+//   - the fields of the value do not match the fields of the model.
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+func (r *Repository[T]) Upsert(v T) (int64, error) {
+	note, err := r.note(v)
+	if err != nil {
+		return 0, err
+	}
+	field, err := r.keyField()
+	if err != nil {
+		return 0, err
+	}
+	ids, err := r.client.Notes.Search(r.query(fieldTerm(field, note.Fields[field])))
+	if err != nil {
+		return 0, err
+	}
+	if len(*ids) == 0 {
+		return r.Create(v)
+	}
+
+	id := (*ids)[0]
+	for _, other := range *ids {
+		id = min(id, other)
+	}
+	return id, r.client.Notes.Update(UpdateNote{
+		Id:     id,
+		Fields: note.Fields,
+	})
+}
+
+// Delete deletes the note with the id of the value, which must have a field tagged with `anki:",id"`.
+// The method returns an error if:
+//   - the value has no note id.
+//   - the api request to ankiconnect fails.
+//   - the api returns an error.
+func (r *Repository[T]) Delete(v T) error {
+	id := NoteID(v)
+	if id == 0 {
+		return fmt.Errorf("ankiconnect: cannot delete a %T without note id", v)
+	}
+	return r.client.Notes.Delete(id)
+}
+
+// note converts the value to a note of the repository and validates its fields.
+func (r *Repository[T]) note(v T) (Note, error) {
+	note, err := MarshalNote(v)
+	if err != nil {
+		return Note{}, err
+	}
+	note.DeckName, note.ModelName = r.deck, r.model
+	fields, err := r.fields()
+	if err != nil {
+		return Note{}, err
+	}
+	return note, checkFields(r.model, fields, note.Fields)
+}
+
+// query returns the search query matching the notes of the repository and all terms.
+// Terms consisting of several parts are put in parentheses.
+func (r *Repository[T]) query(terms ...string) string {
+	parts := []string{
+		search.Term("deck", search.EscapeValue(r.deck)),
+		search.Term("note", search.EscapeValue(r.model)),
+	}
+	for _, t := range terms {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		if strings.ContainsAny(t, " \t\r\n") {
+			t = "(" + t + ")"
+		}
+		parts = append(parts, t)
+	}
+	return strings.Join(parts, " ")
+}
+
+// keyField returns the name of the sort field of the model, it is fetched once.
+// The first field is used if ankiconnect does not support the findModelsByName action.
+func (r *Repository[T]) keyField() (string, error) {
+	r.mu.Lock()
+	key := r.key
+	r.mu.Unlock()
+	if key != "" {
+		return key, nil
+	}
+
+	field, err := r.client.Models.GetSortField(r.model)
+	switch {
+	case err == nil:
+		key = *field
+	case errors.Is(err, ErrUnsupportedAction):
+		fields, err := r.fields()
+		if err != nil {
+			return "", err
+		}
+		if len(fields) == 0 {
+			return "", fmt.Errorf("ankiconnect: model %s has no fields", r.model)
+		}
+		key = fields[0]
+	default:
+		return "", err
+	}
+
+	r.mu.Lock()
+	r.key = key
+	r.mu.Unlock()
+	return key, nil
+}
+
+// fieldTerm returns the search term matching notes whose field equals value.
+func fieldTerm(field, value string) string {
+	return search.Term(search.EscapeValue(field), search.EscapeValue(value))
+}
+
+// fields returns the fields of the model, they are fetched once.
+func (r *Repository[T]) fields() ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.modelFields != nil {
+		return r.modelFields, nil
+	}
+	fields, err := r.client.Models.GetFields(r.model)
+	if err != nil {
+		return nil, err
+	}
+	r.modelFields = *fields
+	return r.modelFields, nil
+}
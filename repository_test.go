@@ -0,0 +1,183 @@
+package ankiconnect_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/atselvan/ankiconnect"
+	"github.com/atselvan/ankiconnect/ankiconnecttest"
+	"github.com/atselvan/ankiconnect/query"
+	"github.com/stretchr/testify/assert"
+)
+
+type vocab struct {
+	ID      int64    `anki:",id"`
+	Word    string   `anki:"Front"`
+	Meaning string   `anki:"Back"`
+	Tags    []string `anki:",tags"`
+}
+
+func newVocabRepository(t *testing.T) (*ankiconnecttest.Server, *ankiconnect.Repository[vocab]) {
+	server := ankiconnecttest.NewServer()
+	server.AddDeck("Japanese")
+	return server, ankiconnect.NewRepository[vocab](server.Client(), "Japanese", "Basic")
+}
+
+func TestRepository(t *testing.T) {
+	server, repo := newVocabRepository(t)
+	defer server.Close()
+
+	id, err := repo.Create(vocab{Word: "taberu", Meaning: "to eat", Tags: []string{"verb"}})
+	assert.NoError(t, err)
+	note, ok := server.Note(id)
+	assert.True(t, ok)
+	assert.Equal(t, "to eat", note.Fields["Back"])
+
+	t.Run("find by key", func(t *testing.T) {
+		v, err := repo.FindByKey("taberu")
+		assert.NoError(t, err)
+		assert.Equal(t, vocab{ID: id, Word: "taberu", Meaning: "to eat", Tags: []string{"verb"}}, v)
+
+		_, err = repo.FindByKey("nomu")
+		assert.ErrorIs(t, err, ankiconnect.ErrNoteNotFound)
+	})
+
+	t.Run("upsert", func(t *testing.T) {
+		updated, err := repo.Upsert(vocab{Word: "taberu", Meaning: "to eat (food)"})
+		assert.NoError(t, err)
+		assert.Equal(t, id, updated)
+		note, _ := server.Note(id)
+		assert.Equal(t, "to eat (food)", note.Fields["Back"])
+
+		created, err := repo.Upsert(vocab{Word: "nomu", Meaning: "to drink"})
+		assert.NoError(t, err)
+		assert.NotEqual(t, id, created)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		all, err := repo.List()
+		assert.NoError(t, err)
+		assert.Len(t, all, 2)
+		assert.Equal(t, "taberu", all[0].Word)
+
+		verbs, err := repo.List(query.Tag("verb"))
+		assert.NoError(t, err)
+		assert.Len(t, verbs, 1)
+
+		byMeaning, err := repo.FindByField("Back", "to drink")
+		assert.NoError(t, err)
+		assert.Len(t, byMeaning, 1)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		v, err := repo.FindByKey("nomu")
+		assert.NoError(t, err)
+		v.Meaning = "to drink (liquids)"
+		assert.NoError(t, repo.Update(v))
+		note, _ := server.Note(v.ID)
+		assert.Equal(t, "to drink (liquids)", note.Fields["Back"])
+
+		assert.Error(t, repo.Update(vocab{Word: "x"}))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		v, err := repo.FindByKey("nomu")
+		assert.NoError(t, err)
+		assert.NoError(t, repo.Delete(v))
+		_, ok := server.Note(v.ID)
+		assert.False(t, ok)
+
+		assert.Error(t, repo.Delete(vocab{}))
+	})
+}
+
+func TestRepository_FieldMismatch(t *testing.T) {
+	server := ankiconnecttest.NewServer()
+	defer server.Close()
+
+	type wrong struct {
+		Word string `anki:"Word"`
+	}
+	repo := ankiconnect.NewRepository[wrong](server.Client(), "Default", "Basic")
+	_, err := repo.Create(wrong{Word: "taberu"})
+
+	var mismatch *ankiconnect.FieldMismatchError
+	if assert.True(t, errors.As(err, &mismatch)) {
+		assert.Equal(t, []string{"Front", "Back"}, mismatch.Missing)
+		assert.Equal(t, []string{"Word"}, mismatch.Extra)
+	}
+	for _, action := range server.Actions() {
+		assert.NotEqual(t, ankiconnect.ActionAddNote, action)
+		assert.NotEqual(t, ankiconnect.ActionAddNotes, action)
+	}
+}
+
+func TestRepository_SortField(t *testing.T) {
+	server := ankiconnecttest.NewServer()
+	defer server.Close()
+	server.AddDeck("Japanese")
+	server.AddModel(ankiconnect.Model{
+		ModelName:     "Vocab",
+		InOrderFields: []string{"Reading", "Word"},
+		CardTemplates: []ankiconnect.CardTemplate{{Name: "Card 1", Front: "{{Word}}", Back: "{{Reading}}"}},
+	})
+	assert.NoError(t, server.SetSortField("Vocab", "Word"))
+
+	type word struct {
+		ID      int64  `anki:",id"`
+		Reading string `anki:"Reading"`
+		Word    string `anki:"Word"`
+	}
+	repo := ankiconnect.NewRepository[word](server.Client(), "Japanese", "Vocab")
+	id, err := repo.Create(word{Reading: "たべる", Word: "食べる"})
+	assert.NoError(t, err)
+
+	v, err := repo.FindByKey("食べる")
+	assert.NoError(t, err)
+	assert.Equal(t, id, v.ID)
+
+	updated, err := repo.Upsert(word{Reading: "たべる (v)", Word: "食べる"})
+	assert.NoError(t, err)
+	assert.Equal(t, id, updated)
+
+	t.Run("unsupported action", func(t *testing.T) {
+		server.FailAction(ankiconnect.ActionFindModelsByName, "unsupported action")
+		defer server.FailAction(ankiconnect.ActionFindModelsByName, "")
+
+		// without the sort field the first field is used as the key
+		repo := ankiconnect.NewRepository[word](server.Client(), "Japanese", "Vocab")
+		v, err := repo.FindByKey("たべる (v)")
+		assert.NoError(t, err)
+		assert.Equal(t, id, v.ID)
+	})
+}
+
+// countingNotesManager counts the notes added through it.
+type countingNotesManager struct {
+	ankiconnect.NotesManager
+	added int
+}
+
+func (m *countingNotesManager) AddMany(notes []ankiconnect.Note) (*[]int64, error) {
+	m.added += len(notes)
+	return m.NotesManager.AddMany(notes)
+}
+
+func TestRepository_NotesManager(t *testing.T) {
+	server := ankiconnecttest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	notes := &countingNotesManager{NotesManager: client.Notes}
+	client.SetNotesManager(notes)
+	repo := ankiconnect.NewRepository[vocab](client, "Default", "Basic")
+
+	_, err := repo.Create(vocab{Word: "taberu", Meaning: "to eat"})
+	assert.NoError(t, err)
+	_, err = repo.Upsert(vocab{Word: "nomu", Meaning: "to drink"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, notes.added)
+
+	_, err = repo.Create(vocab{Word: "taberu", Meaning: "to eat"})
+	assert.ErrorIs(t, err, ankiconnect.ErrDuplicateNote)
+}
@@ -0,0 +1,286 @@
+package ankiconnect
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRetryMaxAttempts    = 3
+	defaultRetryInitialBackoff = 200 * time.Millisecond
+	defaultRetryMaxBackoff     = 5 * time.Second
+	defaultRetryMultiplier     = 2
+	defaultRetryJitter         = 0.2
+
+	defaultCircuitBreakerThreshold = 5
+	defaultCircuitBreakerCooldown  = 30 * time.Second
+
+	circuitOpenErrMsg = "AnkiConnect api is not accessible, requests are rejected until the circuit breaker cooldown has passed"
+)
+
+// CircuitState describes the state of a CircuitBreaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets all requests through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects all requests until the cooldown has passed.
+	CircuitOpen
+	// CircuitHalfOpen lets a single trial request through to check if ankiconnect is reachable again.
+	CircuitHalfOpen
+)
+
+// DefaultIdempotentActions are the actions that are retried by a RetryPolicy without IdempotentActions.
+// They only read data or have the same effect when executed more than once.
+var DefaultIdempotentActions = map[string]bool{
+	ActionVersion:           true,
+	ActionAPIReflect:        true,
+	ActionRequestPermission: true,
+	ActionDeckNames:         true,
+	ActionCreateDeck:        true,
+	ActionGetDeckStats:      true,
+	ActionFindNotes:         true,
+	ActionNotesInfo:         true,
+	ActionUpdateNoteFields:  true,
+	ActionFindCards:         true,
+	ActionCardsInfo:         true,
+	ActionModelNames:        true,
+	ActionModelFieldNames:   true,
+	ActionFindModelsByName:  true,
+	ActionRetrieveMedia:     true,
+	ActionStoreMedia:        true,
+	ActionGetMediaNames:     true,
+	ActionExportPackage:     true,
+	ActionAddTags:           true,
+
+	ActionGetProfiles:              true,
+	ActionGetActiveProfile:         true,
+	ActionLoadProfile:              true,
+	ActionReloadCollection:         true,
+	ActionGetNumCardsReviewedToday: true,
+	ActionGetNumCardsReviewedByDay: true,
+	ActionGetCollectionStatsHTML:   true,
+}
+
+type (
+	// RetryPolicy describes how requests that failed because ankiconnect was not reachable are retried.
+	// Only requests for idempotent actions are retried. Notes added with addNote are also retried if
+	// duplicates are not allowed, because ankiconnect rejects the note as a duplicate if the failed
+	// attempt added it nevertheless.
+	RetryPolicy struct {
+		// MaxAttempts is the maximum number of attempts including the first one.
+		MaxAttempts int
+		// InitialBackoff is the time to wait before the first retry.
+		InitialBackoff time.Duration
+		// MaxBackoff is the maximum time to wait between two attempts.
+		MaxBackoff time.Duration
+		// Multiplier is the factor by which the backoff grows after every attempt.
+		Multiplier float64
+		// Jitter is the fraction of the backoff that is randomized, between 0 and 1.
+		Jitter float64
+		// IdempotentActions are the actions that are safe to retry. DefaultIdempotentActions is used if nil.
+		IdempotentActions map[string]bool
+	}
+
+	// CircuitBreaker rejects requests without sending them after ankiconnect was repeatedly unreachable.
+	// After the cooldown a single trial request is let through, if it succeeds the breaker closes again.
+	CircuitBreaker struct {
+		// FailureThreshold is the number of consecutive connection failures after which the breaker opens.
+		FailureThreshold int
+		// Cooldown is the time the breaker stays open before a trial request is let through.
+		Cooldown time.Duration
+
+		mu       sync.Mutex
+		state    CircuitState
+		failures int
+		openedAt time.Time
+	}
+)
+
+// DefaultRetryPolicy returns a RetryPolicy with three attempts and an exponential backoff with jitter.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    defaultRetryMaxAttempts,
+		InitialBackoff: defaultRetryInitialBackoff,
+		MaxBackoff:     defaultRetryMaxBackoff,
+		Multiplier:     defaultRetryMultiplier,
+		Jitter:         defaultRetryJitter,
+	}
+}
+
+// NewCircuitBreaker returns a CircuitBreaker that opens after threshold consecutive connection
+// failures and half-opens after cooldown.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		threshold = defaultCircuitBreakerThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = defaultCircuitBreakerCooldown
+	}
+	return &CircuitBreaker{
+		FailureThreshold: threshold,
+		Cooldown:         cooldown,
+	}
+}
+
+// SetRetryPolicy can be used to retry requests that failed because ankiconnect was not reachable.
+// A nil policy disables retries.
+func (c *Client) SetRetryPolicy(policy *RetryPolicy) *Client {
+	c.retryPolicy = policy
+	return c
+}
+
+// SetCircuitBreaker can be used to fail fast while ankiconnect is not reachable.
+// A nil circuit breaker disables the circuit breaker.
+func (c *Client) SetCircuitBreaker(cb *CircuitBreaker) *Client {
+	c.circuitBreaker = cb
+	return c
+}
+
+// Retryable reports whether a request for the action with the given params may be retried.
+func (rp *RetryPolicy) Retryable(action string, params interface{}) bool {
+	idempotent := rp.IdempotentActions
+	if idempotent == nil {
+		idempotent = DefaultIdempotentActions
+	}
+	if idempotent[action] {
+		return true
+	}
+	switch p := params.(type) {
+	case *ParamsCreateNote:
+		return p != nil && !allowsDuplicate(p.Note)
+	case *ParamsCreateNotes:
+		if p == nil || p.Notes == nil {
+			return false
+		}
+		for i := range *p.Notes {
+			if allowsDuplicate(&(*p.Notes)[i]) {
+				return false
+			}
+		}
+		return true
+	case *ParamsMulti:
+		if p == nil {
+			return false
+		}
+		for _, a := range p.Actions {
+			if !rp.Retryable(a.Action, a.Params) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// backoff returns the time to wait before the given retry, starting at 1.
+func (rp *RetryPolicy) backoff(retry int) time.Duration {
+	multiplier := rp.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	backoff := float64(rp.InitialBackoff)
+	for i := 1; i < retry; i++ {
+		backoff *= multiplier
+	}
+	if rp.MaxBackoff > 0 && backoff > float64(rp.MaxBackoff) {
+		backoff = float64(rp.MaxBackoff)
+	}
+	if rp.Jitter > 0 {
+		jitter := rp.Jitter
+		if jitter > 1 {
+			jitter = 1
+		}
+		backoff -= backoff * jitter * rand.Float64()
+	}
+	return time.Duration(backoff)
+}
+
+// State returns the current state of the circuit breaker.
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == CircuitOpen && time.Since(cb.openedAt) >= cb.Cooldown {
+		return CircuitHalfOpen
+	}
+	return cb.state
+}
+
+// allow reports whether a request may be sent.
+// Once the cooldown has passed, a single trial request is allowed while the breaker is half-open.
+func (cb *CircuitBreaker) allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.state {
+	case CircuitOpen:
+		if time.Since(cb.openedAt) < cb.Cooldown {
+			return false
+		}
+		cb.state = CircuitHalfOpen
+		return true
+	case CircuitHalfOpen:
+		return false
+	default:
+		return true
+	}
+}
+
+// record updates the circuit breaker with the outcome of a request.
+// Only connection failures count as failures, errors returned by the api show that ankiconnect is reachable.
+func (cb *CircuitBreaker) record(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if !errors.Is(err, ErrAnkiUnreachable) {
+		cb.state = CircuitClosed
+		cb.failures = 0
+		return
+	}
+	cb.failures++
+	if cb.state == CircuitHalfOpen || cb.failures >= cb.FailureThreshold {
+		cb.state = CircuitOpen
+		cb.openedAt = time.Now()
+	}
+}
+
+// do sends a request through the circuit breaker and retries it according to the retry policy.
+// send is called for every attempt and must return the error of the http request.
+func (c *Client) do(action string, params interface{}, send func() error) error {
+	attempts := 1
+	if c.retryPolicy != nil && c.retryPolicy.MaxAttempts > 1 && c.retryPolicy.Retryable(action, params) {
+		attempts = c.retryPolicy.MaxAttempts
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(c.retryPolicy.backoff(attempt - 1))
+		}
+		if c.circuitBreaker != nil && !c.circuitBreaker.allow() {
+			return &Error{
+				Action:     action,
+				Message:    circuitOpenErrMsg,
+				StatusCode: http.StatusServiceUnavailable,
+				Kind:       ErrCircuitOpen,
+				Err:        ErrAnkiUnreachable,
+			}
+		}
+		release := c.acquire()
+		err = send()
+		release()
+		if c.circuitBreaker != nil {
+			c.circuitBreaker.record(err)
+		}
+		if !errors.Is(err, ErrAnkiUnreachable) {
+			return err
+		}
+	}
+	return err
+}
+
+// allowsDuplicate reports whether ankiconnect adds the note even if it is a duplicate.
+func allowsDuplicate(note *Note) bool {
+	return note != nil && note.Options != nil && note.Options.AllowDuplicate
+}
@@ -0,0 +1,185 @@
+package ankiconnect
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// Registers a responder that fails the first failures requests with a connection error
+// and responds with responseJson afterwards. It returns a pointer to the number of requests.
+func registerFlakyResponder(failures int, responseJson []byte) *int {
+	calls := 0
+	httpmock.RegisterResponder(http.MethodPost, ankiConnectUrl,
+		func(req *http.Request) (*http.Response, error) {
+			calls++
+			if calls <= failures {
+				return nil, errors.New("connection refused")
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBuffer(responseJson)),
+				Header:     http.Header{"Content-Type": []string{"application/json"}},
+			}, nil
+		},
+	)
+	return &calls
+}
+
+func testRetryPolicy() *RetryPolicy {
+	policy := DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	policy.MaxBackoff = 2 * time.Millisecond
+	return policy
+}
+
+func TestClient_RetryPolicy(t *testing.T) {
+	deckNamesResult := []byte(`{"result": ["Default"], "error": null}`)
+
+	t.Run("retries idempotent actions", func(t *testing.T) {
+		defer httpmock.Reset()
+		defer client.SetRetryPolicy(nil)
+
+		calls := registerFlakyResponder(2, deckNamesResult)
+
+		decks, err := client.SetRetryPolicy(testRetryPolicy()).Decks.GetAll()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Default"}, *decks)
+		assert.Equal(t, 3, *calls)
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		defer httpmock.Reset()
+		defer client.SetRetryPolicy(nil)
+
+		calls := registerFlakyResponder(3, deckNamesResult)
+
+		_, err := client.SetRetryPolicy(testRetryPolicy()).Decks.GetAll()
+		assert.ErrorIs(t, err, ErrAnkiUnreachable)
+		assert.Equal(t, 3, *calls)
+	})
+
+	t.Run("does not retry api errors", func(t *testing.T) {
+		defer httpmock.Reset()
+		defer client.SetRetryPolicy(nil)
+
+		calls := registerFlakyResponder(0, genericErrorJson)
+
+		_, err := client.SetRetryPolicy(testRetryPolicy()).Decks.GetAll()
+		assertRestErr(t, err, http.StatusBadRequest, "some error message")
+		assert.Equal(t, 1, *calls)
+	})
+
+	t.Run("does not retry invalid responses", func(t *testing.T) {
+		defer httpmock.Reset()
+		defer client.SetRetryPolicy(nil)
+
+		defer client.SetCircuitBreaker(nil)
+
+		calls := registerFlakyResponder(0, []byte(`{"result": true, "error": null}`))
+		cb := NewCircuitBreaker(1, time.Minute)
+
+		_, err := client.SetRetryPolicy(testRetryPolicy()).SetCircuitBreaker(cb).Decks.GetAll()
+		assert.ErrorIs(t, err, ErrInvalidResponse)
+		assert.NotErrorIs(t, err, ErrAnkiUnreachable)
+		assert.Equal(t, 1, *calls)
+		assert.Equal(t, CircuitClosed, cb.State())
+	})
+
+	t.Run("does not retry notes that allow duplicates", func(t *testing.T) {
+		defer httpmock.Reset()
+		defer client.SetRetryPolicy(nil)
+
+		calls := registerFlakyResponder(1, []byte(`{"result": 1659294247478, "error": null}`))
+
+		err := client.SetRetryPolicy(testRetryPolicy()).Notes.Add(Note{Options: &Options{AllowDuplicate: true}})
+		assert.ErrorIs(t, err, ErrAnkiUnreachable)
+		assert.Equal(t, 1, *calls)
+	})
+
+	t.Run("retries notes that do not allow duplicates", func(t *testing.T) {
+		defer httpmock.Reset()
+		defer client.SetRetryPolicy(nil)
+
+		calls := registerFlakyResponder(1, []byte(`{"result": 1659294247478, "error": null}`))
+
+		err := client.SetRetryPolicy(testRetryPolicy()).Notes.Add(Note{})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, *calls)
+	})
+}
+
+func TestRetryPolicy_Retryable(t *testing.T) {
+	policy := DefaultRetryPolicy()
+	assert.True(t, policy.Retryable(ActionDeckNames, nil))
+	assert.False(t, policy.Retryable(ActionDeleteDecks, nil))
+	assert.True(t, policy.Retryable(ActionAddNote, &ParamsCreateNote{Note: &Note{}}))
+	assert.True(t, policy.Retryable(ActionAddNotes, &ParamsCreateNotes{Notes: &[]Note{{}, {}}}))
+	assert.False(t, policy.Retryable(ActionAddNotes, &ParamsCreateNotes{Notes: &[]Note{{}, {Options: &Options{AllowDuplicate: true}}}}))
+	assert.True(t, policy.Retryable(ActionMulti, &ParamsMulti{Actions: []MultiAction{{Action: ActionDeckNames}}}))
+	assert.False(t, policy.Retryable(ActionMulti, &ParamsMulti{Actions: []MultiAction{{Action: ActionDeleteDecks}}}))
+
+	policy.IdempotentActions = map[string]bool{ActionDeleteDecks: true}
+	assert.True(t, policy.Retryable(ActionDeleteDecks, nil))
+	assert.False(t, policy.Retryable(ActionDeckNames, nil))
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := &RetryPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     300 * time.Millisecond,
+		Multiplier:     2,
+	}
+	assert.Equal(t, 100*time.Millisecond, policy.backoff(1))
+	assert.Equal(t, 200*time.Millisecond, policy.backoff(2))
+	assert.Equal(t, 300*time.Millisecond, policy.backoff(3))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 10; i++ {
+		backoff := policy.backoff(1)
+		assert.GreaterOrEqual(t, backoff, 50*time.Millisecond)
+		assert.LessOrEqual(t, backoff, 100*time.Millisecond)
+	}
+}
+
+func TestClient_CircuitBreaker(t *testing.T) {
+	defer httpmock.Reset()
+	defer client.SetCircuitBreaker(nil)
+
+	cb := NewCircuitBreaker(2, 20*time.Millisecond)
+	client.SetCircuitBreaker(cb)
+	calls := registerFlakyResponder(3, []byte(`{"result": ["Default"], "error": null}`))
+
+	for i := 0; i < 2; i++ {
+		_, err := client.Decks.GetAll()
+		assert.ErrorIs(t, err, ErrAnkiUnreachable)
+	}
+	assert.Equal(t, CircuitOpen, cb.State())
+
+	// requests fail fast while the breaker is open
+	_, err := client.Decks.GetAll()
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.ErrorIs(t, err, ErrAnkiUnreachable)
+	assert.Equal(t, 2, *calls)
+
+	// the failed trial request opens the breaker again
+	time.Sleep(25 * time.Millisecond)
+	assert.Equal(t, CircuitHalfOpen, cb.State())
+	_, err = client.Decks.GetAll()
+	assert.ErrorIs(t, err, ErrAnkiUnreachable)
+	assert.NotErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, CircuitOpen, cb.State())
+
+	// the successful trial request closes the breaker
+	time.Sleep(25 * time.Millisecond)
+	_, err = client.Decks.GetAll()
+	assert.NoError(t, err)
+	assert.Equal(t, CircuitClosed, cb.State())
+	assert.Equal(t, 4, *calls)
+}
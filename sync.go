@@ -1,15 +1,74 @@
 package ankiconnect
 
-import "github.com/privatesquare/bkst-go-utils/utils/errors"
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
 
 const (
 	ActionSync = "sync"
+
+	// SyncErrLoginRequired is the Error of the RestErr returned by ToRestErr for errors matching
+	// ErrSyncLoginRequired, i.e. when Anki has no AnkiWeb credentials configured.
+	SyncErrLoginRequired = "sync login required"
+	// SyncErrFullSyncRequired is the Error of the RestErr returned by ToRestErr for errors matching
+	// ErrFullSyncRequired, i.e. when AnkiWeb requires a one-way full sync that has to be confirmed
+	// by the user in the Anki GUI.
+	SyncErrFullSyncRequired = "full sync required"
+	// SyncErrTimeout is the Error of the RestErr returned by ToRestErr for errors matching
+	// ErrSyncTimeout, i.e. when waiting for a sync to finish took too long.
+	SyncErrTimeout = "sync timeout"
+
+	syncTimeoutErrMsg = "Timed out waiting for the sync to complete"
+
+	defaultSyncPollInterval = time.Second
+	defaultSyncTimeout      = 5 * time.Minute
+	defaultSyncerInterval   = 10 * time.Minute
+	defaultSyncerMinBackoff = 30 * time.Second
+	defaultSyncerMaxBackoff = time.Hour
 )
 
+const (
+	// SyncNoChanges indicates that the collection was already in sync with AnkiWeb.
+	SyncNoChanges SyncRequired = iota
+	// SyncNormal indicates that a normal incremental sync can be performed.
+	SyncNormal
+	// SyncFull indicates that a full sync is required and the direction has to be chosen by the user.
+	SyncFull
+	// SyncFullDownload indicates that the collection has to be replaced with the one on AnkiWeb.
+	SyncFullDownload
+	// SyncFullUpload indicates that the collection on AnkiWeb has to be replaced with the local one.
+	SyncFullUpload
+)
+
+// syncStatusPattern matches the error returned by ankiconnect when the sync needs user interaction.
+var syncStatusPattern = regexp.MustCompile(`Sync status (\d+) not one of`)
+
 type (
 	// SyncManager describes the interface that can be used to perform sync operations on Anki.
 	SyncManager interface {
-		Trigger() *errors.RestErr
+		Trigger() error
+		TriggerAndWait(opts *SyncWaitOptions) (*SyncResult, error)
+	}
+
+	// SyncRequired mirrors the ChangesRequired values Anki reports when a sync cannot be completed silently.
+	SyncRequired int
+
+	// SyncWaitOptions controls how TriggerAndWait polls Anki for the completion of a sync.
+	// Zero values are replaced with sensible defaults.
+	SyncWaitOptions struct {
+		PollInterval time.Duration
+		Timeout      time.Duration
+	}
+
+	// SyncResult describes a sync that was completed by TriggerAndWait.
+	SyncResult struct {
+		Polls    int
+		Duration time.Duration
 	}
 
 	// syncManager implements SyncManager
@@ -18,14 +77,231 @@ type (
 	}
 )
 
+// String returns the name of the sync state as used by Anki.
+func (sr SyncRequired) String() string {
+	switch sr {
+	case SyncNoChanges:
+		return "NO_CHANGES"
+	case SyncNormal:
+		return "NORMAL_SYNC"
+	case SyncFull:
+		return "FULL_SYNC"
+	case SyncFullDownload:
+		return "FULL_DOWNLOAD"
+	case SyncFullUpload:
+		return "FULL_UPLOAD"
+	default:
+		return "UNKNOWN(" + strconv.Itoa(int(sr)) + ")"
+	}
+}
+
 // Trigger syncs local Anki data to Anki web.
 // The method returns an error if:
 //   - the api request to ankiconnect fails.
 //   - the api returns a http error.
-func (sm *syncManager) Trigger() *errors.RestErr {
-	_, restErr := post[string, ParamsDefault](sm.Client, ActionSync, nil)
-	if restErr != nil {
-		return restErr
+//   - Anki is not logged in to AnkiWeb (ErrSyncLoginRequired).
+//   - AnkiWeb requires a full sync (ErrFullSyncRequired).
+func (sm *syncManager) Trigger() error {
+	_, err := post[string, ParamsDefault](sm.Client, ActionSync, nil)
+	if err != nil {
+		return classifySyncError(err)
 	}
 	return nil
 }
+
+// TriggerAndWait syncs local Anki data to Anki web and blocks until the collection is usable again.
+// The sync action of ankiconnect blocks until the sync has finished, so the first poll of the
+// collection normally succeeds right away and Polls is 1. The polling only guards against servers
+// that start the sync in the background and return immediately while Anki closes the collection
+// for the duration of the sync: the collection is polled until it answers requests again or the
+// timeout expires.
+// The method returns an error if:
+//   - the sync could not be triggered, see Trigger.
+//   - the collection did not become available before the timeout (ErrSyncTimeout).
+func (sm *syncManager) TriggerAndWait(opts *SyncWaitOptions) (*SyncResult, error) {
+	pollInterval, timeout := defaultSyncPollInterval, defaultSyncTimeout
+	if opts != nil {
+		if opts.PollInterval > 0 {
+			pollInterval = opts.PollInterval
+		}
+		if opts.Timeout > 0 {
+			timeout = opts.Timeout
+		}
+	}
+
+	start := time.Now()
+	if err := sm.Trigger(); err != nil {
+		return nil, err
+	}
+
+	result := &SyncResult{}
+	for {
+		result.Polls++
+		_, err := post[[]string, ParamsDefault](sm.Client, ActionDeckNames, nil)
+		if err == nil {
+			result.Duration = time.Since(start)
+			return result, nil
+		}
+		if time.Since(start)+pollInterval > timeout {
+			return nil, &Error{
+				Action:     ActionSync,
+				Message:    syncTimeoutErrMsg,
+				StatusCode: http.StatusGatewayTimeout,
+				Kind:       ErrSyncTimeout,
+			}
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// classifySyncError identifies the reason of a failed sync from the error text returned by ankiconnect.
+// A classified error is returned as a new Error wrapping err, err itself is not modified.
+// The original ankiconnect error text is kept as the Message.
+func classifySyncError(err error) error {
+	var e *Error
+	if !errors.As(err, &e) || e.Err != nil {
+		return err
+	}
+	kind, statusCode := e.Kind, e.StatusCode
+	if m := syncStatusPattern.FindStringSubmatch(e.Message); m != nil {
+		status, _ := strconv.Atoi(m[1])
+		switch SyncRequired(status) {
+		case SyncFull, SyncFullDownload, SyncFullUpload:
+			kind, statusCode = ErrFullSyncRequired, http.StatusConflict
+		}
+	}
+	if kind == ErrSyncLoginRequired {
+		statusCode = http.StatusUnauthorized
+	}
+	if kind == e.Kind && statusCode == e.StatusCode {
+		return err
+	}
+	return &Error{
+		Action:     e.Action,
+		Message:    e.Message,
+		StatusCode: statusCode,
+		Kind:       kind,
+		Err:        err,
+	}
+}
+
+// Syncer periodically syncs the collection in the background.
+// Failed syncs are retried with an exponential backoff between MinBackoff and MaxBackoff,
+// after a successful sync the next one is scheduled after Interval. A zero Interval is replaced
+// with the default interval of NewSyncer.
+// A Syncer can be started and stopped multiple times but must not be copied after first use.
+type Syncer struct {
+	Sync       SyncManager
+	Interval   time.Duration
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// OnError is called with every failed sync. It is called from the background goroutine.
+	OnError func(err error)
+	// OnSuccess is called after every successful sync. It is called from the background goroutine.
+	OnSuccess func()
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewSyncer returns a Syncer that triggers a sync through sm every interval.
+func NewSyncer(sm SyncManager, interval time.Duration) *Syncer {
+	if interval <= 0 {
+		interval = defaultSyncerInterval
+	}
+	return &Syncer{
+		Sync:       sm,
+		Interval:   interval,
+		MinBackoff: defaultSyncerMinBackoff,
+		MaxBackoff: defaultSyncerMaxBackoff,
+	}
+}
+
+// Start starts syncing in a background goroutine. The first sync is triggered immediately.
+// Calling Start on a running Syncer has no effect.
+func (s *Syncer) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		return
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.run(s.stop, s.done)
+}
+
+// Stop stops the background goroutine and waits for a running sync to finish.
+// Calling Stop on a stopped Syncer has no effect.
+func (s *Syncer) Stop() {
+	s.mu.Lock()
+	stop, done := s.stop, s.done
+	s.stop, s.done = nil, nil
+	s.mu.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+// Running reports whether the Syncer has been started and not stopped yet.
+func (s *Syncer) Running() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stop != nil
+}
+
+func (s *Syncer) run(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	backoff := time.Duration(0)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+		}
+
+		next := s.interval()
+		if err := s.Sync.Trigger(); err != nil {
+			backoff = s.nextBackoff(backoff)
+			next = backoff
+			if s.OnError != nil {
+				s.OnError(err)
+			}
+		} else {
+			backoff = 0
+			if s.OnSuccess != nil {
+				s.OnSuccess()
+			}
+		}
+		timer.Reset(next)
+	}
+}
+
+// nextBackoff doubles the previous backoff, keeping it between MinBackoff and MaxBackoff.
+func (s *Syncer) nextBackoff(previous time.Duration) time.Duration {
+	next := previous * 2
+	if next < s.MinBackoff {
+		next = s.MinBackoff
+	}
+	if s.MaxBackoff > 0 && next > s.MaxBackoff {
+		next = s.MaxBackoff
+	}
+	if next <= 0 {
+		next = s.interval()
+	}
+	return next
+}
+
+// interval returns Interval, or the default interval if Interval is not positive.
+func (s *Syncer) interval() time.Duration {
+	if s.Interval <= 0 {
+		return defaultSyncerInterval
+	}
+	return s.Interval
+}
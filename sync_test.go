@@ -1,8 +1,14 @@
 package ankiconnect
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/jarcoal/httpmock"
 	"github.com/stretchr/testify/assert"
@@ -24,8 +30,8 @@ func TestSyncManager_Trigger(t *testing.T) {
 
 		registerVerifiedPayload(t, syncRequest, syncResult)
 
-		restErr := client.Sync.Trigger()
-		assert.Nil(t, restErr)
+		err := client.Sync.Trigger()
+		assert.Nil(t, err)
 	})
 
 	t.Run("error", func(t *testing.T) {
@@ -33,9 +39,214 @@ func TestSyncManager_Trigger(t *testing.T) {
 
 		registerErrorResponse(t)
 
-		restErr := client.Sync.Trigger()
-		assert.NotNil(t, restErr)
-		assert.Equal(t, http.StatusBadRequest, restErr.StatusCode)
-		assert.Equal(t, "some error message", restErr.Message)
+		err := client.Sync.Trigger()
+		assert.NotNil(t, err)
+		assertRestErr(t, err, http.StatusBadRequest, "some error message")
 	})
 }
+
+func TestSyncManager_Trigger_Classification(t *testing.T) {
+	t.Run("login required", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t, []byte(`{"action": "sync", "version": 6}`),
+			[]byte(`{"result": null, "error": "sync: auth not configured"}`))
+
+		err := client.Sync.Trigger()
+		assert.NotNil(t, err)
+		assert.ErrorIs(t, err, ErrSyncLoginRequired)
+		assertRestErr(t, err, http.StatusUnauthorized, "sync: auth not configured")
+	})
+
+	t.Run("full sync required", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t, []byte(`{"action": "sync", "version": 6}`),
+			[]byte(`{"result": null, "error": "Sync status 2 not one of [0, 1] - see SyncCollectionResponse.ChangesRequired for list of sync statuses"}`))
+
+		err := client.Sync.Trigger()
+		assert.NotNil(t, err)
+		assert.ErrorIs(t, err, ErrFullSyncRequired)
+		assert.Equal(t, http.StatusConflict, ToRestErr(err).StatusCode)
+		assert.Equal(t, SyncErrFullSyncRequired, ToRestErr(err).Error)
+	})
+}
+
+func TestClassifySyncError(t *testing.T) {
+	inner := newAPIError(ActionSync, "sync: auth not configured")
+	wrapped := fmt.Errorf("nightly sync: %w", inner)
+
+	err := classifySyncError(wrapped)
+	assert.ErrorIs(t, err, ErrSyncLoginRequired)
+	assert.Equal(t, SyncErrLoginRequired, ToRestErr(err).Error)
+	// the classified error wraps the original error, which is not modified
+	assert.True(t, errors.Is(err, wrapped))
+	assert.Equal(t, http.StatusBadRequest, inner.StatusCode)
+
+	other := newAPIError(ActionSync, "some error message")
+	assert.Same(t, other, classifySyncError(other))
+}
+
+func TestSyncManager_TriggerAndWait(t *testing.T) {
+	syncRequest := []byte(`{"action": "sync", "version": 6}`)
+	deckNamesRequest := []byte(`{"action": "deckNames", "version": 6}`)
+	opts := &SyncWaitOptions{PollInterval: time.Millisecond, Timeout: time.Second}
+
+	t.Run("success", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerMultipleVerifiedPayloads(t, [][2][]byte{
+			{syncRequest, genericSuccessJson},
+			{deckNamesRequest, []byte(`{"result": null, "error": "collection is not open"}`)},
+			{deckNamesRequest, []byte(`{"result": ["Default"], "error": null}`)},
+		})
+
+		result, err := client.Sync.TriggerAndWait(opts)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, result.Polls)
+	})
+
+	t.Run("trigger error", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		registerVerifiedPayload(t, syncRequest, []byte(`{"result": null, "error": "sync: auth not configured"}`))
+
+		result, err := client.Sync.TriggerAndWait(opts)
+		assert.Nil(t, result)
+		assert.ErrorIs(t, err, ErrSyncLoginRequired)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		defer httpmock.Reset()
+
+		// the sync succeeds but the collection never becomes available again
+		pairs := [][2][]byte{{syncRequest, genericSuccessJson}}
+		for i := 0; i < 100; i++ {
+			pairs = append(pairs, [2][]byte{deckNamesRequest, genericErrorJson})
+		}
+		registerMultipleVerifiedPayloads(t, pairs)
+
+		result, err := client.Sync.TriggerAndWait(&SyncWaitOptions{PollInterval: time.Millisecond, Timeout: 10 * time.Millisecond})
+		assert.Nil(t, result)
+		assert.ErrorIs(t, err, ErrSyncTimeout)
+		assert.Equal(t, http.StatusGatewayTimeout, ToRestErr(err).StatusCode)
+	})
+}
+
+// newDelayedSyncServer starts a server that takes delay to sync. A blocking server answers the sync
+// request when the sync has finished, a non-blocking server answers it right away and fails
+// deckNames requests until the sync has finished.
+func newDelayedSyncServer(t *testing.T, delay time.Duration, blocking bool) *Client {
+	var mu sync.Mutex
+	var busyUntil time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req RequestPayload[json.RawMessage]
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		w.Header().Set("Content-Type", "application/json")
+		switch req.Action {
+		case ActionSync:
+			if blocking {
+				time.Sleep(delay)
+			} else {
+				mu.Lock()
+				busyUntil = time.Now().Add(delay)
+				mu.Unlock()
+			}
+			_, _ = w.Write(genericSuccessJson)
+		case ActionDeckNames:
+			mu.Lock()
+			busy := time.Now().Before(busyUntil)
+			mu.Unlock()
+			if busy {
+				_, _ = w.Write([]byte(`{"result": null, "error": "collection is not open"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"result": ["Default"], "error": null}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return NewClient().SetURL(server.URL)
+}
+
+func TestSyncManager_TriggerAndWait_Delayed(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	opts := &SyncWaitOptions{PollInterval: 5 * time.Millisecond, Timeout: time.Second}
+
+	t.Run("blocking sync", func(t *testing.T) {
+		result, err := newDelayedSyncServer(t, delay, true).Sync.TriggerAndWait(opts)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, result.Polls)
+		assert.GreaterOrEqual(t, result.Duration, delay)
+	})
+
+	t.Run("non-blocking sync", func(t *testing.T) {
+		result, err := newDelayedSyncServer(t, delay, false).Sync.TriggerAndWait(opts)
+		assert.NoError(t, err)
+		assert.Greater(t, result.Polls, 1)
+		assert.GreaterOrEqual(t, result.Duration, delay)
+	})
+}
+
+// syncManagerStub records the number of triggered syncs and fails the first failures of them.
+type syncManagerStub struct {
+	mu       sync.Mutex
+	calls    int
+	failures int
+}
+
+func (s *syncManagerStub) Trigger() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.calls <= s.failures {
+		return newAPIError(ActionSync, "some error message")
+	}
+	return nil
+}
+
+func (s *syncManagerStub) TriggerAndWait(_ *SyncWaitOptions) (*SyncResult, error) {
+	return &SyncResult{}, s.Trigger()
+}
+
+func TestSyncer(t *testing.T) {
+	stub := &syncManagerStub{failures: 2}
+	syncer := NewSyncer(stub, time.Hour)
+	syncer.MinBackoff = time.Millisecond
+	syncer.MaxBackoff = 2 * time.Millisecond
+
+	failed := make(chan struct{}, 2)
+	succeeded := make(chan struct{}, 1)
+	syncer.OnError = func(err error) { failed <- struct{}{} }
+	syncer.OnSuccess = func() { succeeded <- struct{}{} }
+
+	syncer.Start()
+	assert.True(t, syncer.Running())
+
+	select {
+	case <-succeeded:
+	case <-time.After(time.Second):
+		t.Fatal("syncer did not recover from failed syncs")
+	}
+	syncer.Stop()
+	assert.False(t, syncer.Running())
+
+	assert.Len(t, failed, 2)
+	assert.Equal(t, 3, stub.calls)
+}
+
+func TestSyncer_ZeroInterval(t *testing.T) {
+	stub := &syncManagerStub{}
+	syncer := &Syncer{Sync: stub}
+	assert.Equal(t, defaultSyncerInterval, syncer.interval())
+	assert.Equal(t, defaultSyncerInterval, syncer.nextBackoff(0))
+
+	succeeded := make(chan struct{}, 10)
+	syncer.OnSuccess = func() { succeeded <- struct{}{} }
+	syncer.Start()
+	<-succeeded
+	// the next sync is scheduled after the default interval instead of immediately
+	time.Sleep(20 * time.Millisecond)
+	syncer.Stop()
+
+	assert.Equal(t, 1, stub.calls)
+}